@@ -5,7 +5,7 @@ import (
 	"github.com/goal-web/console/arguments"
 	"github.com/goal-web/contracts"
 	"github.com/goal-web/goal-cli/app/gen"
-	"github.com/goal-web/migration/migrate"
+	"github.com/goal-web/goal-cli/config"
 	"github.com/goal-web/supports/commands"
 	"github.com/goal-web/supports/logs"
 	utils2 "github.com/goal-web/supports/utils"
@@ -15,11 +15,13 @@ import (
 )
 
 func MakeModel() (contracts.Command, contracts.CommandHandlerProvider) {
-	return commands.Base("make:model {name} {path?} {table?} {m?}", "创建一个模型"),
+	return commands.Base("make:model {name?} {path?} {table?} {m?} {--all:读取数据库中全部的表生成模型，并根据外键推导关联关系} {--include:--all 时只处理匹配的表，多个规则用逗号分隔，例如 user*,orders} {--exclude:--all 时跳过匹配的表，多个规则用逗号分隔=migrations} {--force:覆盖已经存在的 proto 文件}", "创建一个模型"),
 		func(app contracts.Application) contracts.CommandHandler {
+			genConfig, _ := app.Get("config").(contracts.Config).Get("gen").(config.Gen)
 			return &makeModel{
 				connection: app.Get("db").(contracts.DBConnection),
 				app:        app,
+				config:     genConfig,
 			}
 		}
 }
@@ -28,38 +30,26 @@ type makeModel struct {
 	commands.Command
 	connection contracts.DBConnection
 	app        contracts.Application
+	config     config.Gen
 }
 
-func fieldTypeToGoType(fieldType string) string {
-	if strings.Contains(fieldType, "TEXT") || strings.HasPrefix(fieldType, "VARCHAR(") {
-		return "string"
+func (cmd makeModel) Handle() any {
+	if cmd.GetBool("all") {
+		return cmd.makeAll()
 	}
 
-	var SQLTypeMap = map[string]string{
-		"DOUBLE":          "double", //
-		"JSON":            "string", //
-		"FLOAT":           "float",  //
-		"TIMESTAMP":       "string", //
-		"INT":             "int32",  // 32
-		"BIGINT":          "int64",  // 64
-		"INT UNSIGNED":    "uint32", // 32
-		"BIGINT UNSIGNED": "uint64", // 64
-		"BOOLEAN":         "bool",   //
-		"VARCHAR(255)":    "string", //
-		"BLOB":            "bytes",  //
+	name := cmd.StringOptional("name", cmd.GetArg(0))
+	if name == "" {
+		logs.Default().Error("请指定模型名称，或者使用 --all 生成全部的表")
+		return nil
 	}
-	return SQLTypeMap[fieldType]
-}
-
-func (cmd makeModel) Handle() any {
-	name := cmd.GetString("name")
 	table := cmd.StringOptional("table", gen.ConvertCamelToSnake(name))
 	path := cmd.StringOptional("path", "pro")
 	pkg := filepath.Base(path)
 	m := cmd.GetBool("m")
 	path = fmt.Sprintf("%s/%s.proto", path, name)
 
-	if utils2.ExistsPath(path) {
+	if utils2.ExistsPath(path) && !cmd.GetBool("force") {
 		logs.Default().WithFields(contracts.Fields{
 			"path":  path,
 			"pkg":   pkg,
@@ -70,43 +60,45 @@ func (cmd makeModel) Handle() any {
 		return nil
 	}
 
-	var existsColumns = make([]migrate.ColumnInfo, 0)
-	exception := cmd.connection.Select(&existsColumns, fmt.Sprintf("describe %s", table))
-	if exception != nil {
-		logs.Default().WithError(exception).Error("table is not exists.")
-	}
-
-	var columns []string
-	for i, column := range existsColumns {
-		columns = append(columns, fmt.Sprintf("    %s %s = %d;", fieldTypeToGoType(strings.ToUpper(column.Type)), column.Field, i+1))
-	}
-
-	if len(columns) == 0 {
-		columns = append(columns, `
-		//@pk
-		uint64 id = 1;
-
-		string created_at = 100;
-		string updated_at = 101;`)
+	var content string
+	var warnings gen.Diagnostics
+	inspector, err := gen.NewInspector(cmd.connection)
+	if err == nil {
+		var info *gen.TableInfo
+		if info, err = inspector.Table(table); err == nil {
+			var diagnostics error
+			content, diagnostics = info.ModelProto(name, cmd.config.Types)
+			warnings.Add(diagnostics)
+		}
 	}
-
-	err := os.WriteFile(path, []byte(fmt.Sprintf(`syntax = "proto3";
+	exists := err == nil
+	if !exists {
+		logs.Default().WithError(err).Warn("读取表结构失败，将生成默认的模型")
+		content = fmt.Sprintf(`syntax = "proto3";
 
 option go_package = ".";
 
 //@timestamps
 //@table: %s
 message %sModel {
-%s
+    //@pk
+    uint64 id = 1;
+
+    string created_at = 100;
+    string updated_at = 101;
 }
-`, table, name, strings.Join(columns, "\n"))), os.ModePerm)
+`, table, name)
+	}
 
-	if err != nil {
+	if err = os.WriteFile(path, []byte(content), os.ModePerm); err != nil {
 		panic(err)
 	}
+	if len(warnings) > 0 {
+		warnings.Report(os.Stderr)
+	}
 
 	// create migration files
-	if m && len(existsColumns) == 0 {
+	if m && !exists {
 		cmd.app.Get("console").(contracts.Console).Call("make:migration", arguments.NewArguments([]string{
 			fmt.Sprintf("create_%s", table),
 		}, contracts.Fields{}))
@@ -122,3 +114,74 @@ message %sModel {
 
 	return nil
 }
+
+// makeAll 为数据库中的每张表生成一个模型，中间表生成为两边模型的 belongsToMany 关联
+func (cmd makeModel) makeAll() any {
+	dir := cmd.StringOptional("path", "pro")
+	inspector, err := gen.NewInspector(cmd.connection)
+	if err != nil {
+		logs.Default().WithError(err).Error("读取表结构失败")
+		return nil
+	}
+	names, err := inspector.Tables()
+	if err != nil {
+		logs.Default().WithError(err).Error("读取表结构失败")
+		return nil
+	}
+
+	include, exclude := cmd.StringArrayOption("include", nil), cmd.StringArrayOption("exclude", nil)
+	var tables []*gen.TableInfo
+	for _, name := range names {
+		if (len(include) > 0 && !matchTable(name, include)) || matchTable(name, exclude) {
+			continue
+		}
+		table, err := inspector.Table(name)
+		if err != nil {
+			logs.Default().WithError(err).WithField("table", name).Error("读取表结构失败")
+			return nil
+		}
+		tables = append(tables, table)
+	}
+	gen.LinkTables(tables)
+
+	if err = os.MkdirAll(dir, os.ModePerm); err != nil {
+		panic(err)
+	}
+	var warnings gen.Diagnostics
+	for _, table := range tables {
+		if table.Pivot {
+			logs.Default().WithField("table", table.Name).Info("中间表生成为 belongsToMany 关联，不生成模型")
+			continue
+		}
+		name := gen.ModelName(table.Name)
+		path := filepath.Join(dir, name+".proto")
+		if utils2.ExistsPath(path) && !cmd.GetBool("force") {
+			logs.Default().WithField("path", path).Warn("model file is already exists, use --force to overwrite.")
+			continue
+		}
+		content, err := table.ModelProto(name, cmd.config.Types)
+		warnings.Add(err)
+		if err = os.WriteFile(path, []byte(content), os.ModePerm); err != nil {
+			panic(err)
+		}
+		logs.Default().WithFields(contracts.Fields{
+			"path":  path,
+			"table": table.Name,
+			"name":  name,
+		}).Info(name)
+	}
+	if len(warnings) > 0 {
+		warnings.Report(os.Stderr)
+	}
+	return nil
+}
+
+// matchTable 表名是否匹配任意一个规则，规则支持 * 和 ? 通配符
+func matchTable(table string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(strings.TrimSpace(pattern), table); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/goal-web/contracts"
+	"github.com/goal-web/goal-cli/app/gen"
+	"github.com/goal-web/supports/commands"
+)
+
+func DbDiff() (contracts.Command, contracts.CommandHandlerProvider) {
+	return commands.Base("db:diff {--dir:Proto文件的路径=pro} {--format:输出格式，text 或者 json=text} {--proto_path:查找 import 的目录，多个目录用逗号分隔，默认为当前目录}", "对比模型与数据库中的表结构，报告缺失的表、字段、索引以及不一致的类型"),
+		func(app contracts.Application) contracts.CommandHandler {
+			return &dbDiff{connection: app.Get("db").(contracts.DBConnection)}
+		}
+}
+
+type dbDiff struct {
+	commands.Command
+	connection contracts.DBConnection
+}
+
+func (cmd dbDiff) Handle() any {
+	var diagnostics gen.Diagnostics
+	format := cmd.GetString("format")
+	if format != "text" && format != "json" {
+		fmt.Printf("不支持的输出格式 %s，只能是 text 或者 json\n", format)
+		os.Exit(1)
+	}
+
+	protoFiles, err := scanProtoFiles(cmd.GetString("dir"))
+	if err != nil {
+		fmt.Printf("扫描目录 %s 中的 proto 文件失败: %v\n", cmd.GetString("dir"), err)
+		os.Exit(1)
+	}
+
+	// 按照连接的数据库推导模型的表结构
+	generator, err := gen.NewGenerator(gen.Config{
+		OutputDir:  ".",
+		Mode:       gen.ModePro,
+		ProtoPaths: cmd.StringArrayOption("proto_path", nil),
+		Dialect:    gen.DialectOf(cmd.connection.DriverName()),
+	})
+	diagnostics.Add(err)
+
+	var diff *gen.DatabaseDiff
+	if generator != nil {
+		schema, err := generator.Schema(protoFiles)
+		diagnostics.Add(err)
+		inspector, err := gen.NewInspector(cmd.connection)
+		diagnostics.Add(err)
+		if !diagnostics.HasErrors() {
+			diff, err = gen.DiffDatabase(schema, inspector)
+			diagnostics.Add(err)
+		}
+	}
+	if diagnostics.HasErrors() {
+		diagnostics.Report(os.Stderr)
+		os.Exit(1)
+	}
+
+	if format == "json" {
+		content, _ := json.MarshalIndent(diff, "", "  ")
+		fmt.Println(string(content))
+	} else {
+		diff.Report(os.Stdout)
+	}
+	if len(diagnostics) > 0 {
+		diagnostics.Report(os.Stderr)
+	}
+	if diff.HasDrift() {
+		os.Exit(1)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package commands
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/goal-web/contracts"
+	"github.com/goal-web/supports/commands"
+	"github.com/goal-web/supports/logs"
+	utils2 "github.com/goal-web/supports/utils"
+)
+
+func DbSeed() (contracts.Command, contracts.CommandHandlerProvider) {
+	return commands.Base("db:seed {--class:只执行指定的 seeder} {--dir:seeder 所在的目录=database/seeders}", "执行数据填充，不指定 --class 时执行全部 seeder"),
+		func(application contracts.Application) contracts.CommandHandler {
+			return &dbSeed{}
+		}
+}
+
+type dbSeed struct {
+	commands.Command
+}
+
+func (cmd dbSeed) Handle() any {
+	dir := cmd.GetString("dir")
+	if !utils2.ExistsPath(filepath.Join(dir, "cmd", "main.go")) {
+		logs.Default().WithField("dir", dir).Error("没有找到 seeder，请先执行 make:seeder 创建")
+		os.Exit(1)
+	}
+
+	// seeder 依赖项目中的模型，需要在项目中编译执行
+	args := []string{"run", "./" + filepath.ToSlash(filepath.Join(dir, "cmd"))}
+	if class := cmd.GetString("class"); class != "" {
+		args = append(args, class)
+	}
+	command := exec.Command("go", args...)
+	command.Stdin = os.Stdin
+	command.Stdout = os.Stdout
+	command.Stderr = os.Stderr
+	if err := command.Run(); err != nil {
+		logs.Default().WithError(err).Error("数据填充失败")
+		os.Exit(1)
+	}
+	return nil
+}
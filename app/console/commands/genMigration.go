@@ -0,0 +1,111 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/goal-web/contracts"
+	"github.com/goal-web/goal-cli/app/gen"
+	"github.com/goal-web/goal-cli/config"
+	"github.com/goal-web/migration"
+	"github.com/goal-web/supports/commands"
+	"github.com/goal-web/supports/utils"
+)
+
+func GenMigration() (contracts.Command, contracts.CommandHandlerProvider) {
+	return commands.Base("gen:migration {--name:迁移文件的名称=update_schema} {--dir:Proto文件的路径=pro} {--path:迁移文件的目录，默认读取 migration.dir} {--proto_path:查找 import 的目录，多个目录用逗号分隔，默认为当前目录} {--dialect:db tag 和迁移语句的目标数据库：mysql、postgres、sqlite、clickhouse，默认读取 env.toml 中的 gen.dialect}", "对比模型与上一次的表结构快照，生成迁移文件"),
+		func(application contracts.Application) contracts.CommandHandler {
+			genConfig, _ := application.Get("config").(contracts.Config).Get("gen").(config.Gen)
+			dir := "migrations"
+			if migrationConfig, ok := application.Get("config").(contracts.Config).Get("migration").(*migration.Config); ok && migrationConfig.Dir != "" {
+				dir = migrationConfig.Dir
+			}
+			return &genMigration{config: genConfig, dir: dir}
+		}
+}
+
+type genMigration struct {
+	commands.Command
+	config config.Gen
+	dir    string // 与 migrate 命令读取同一个目录
+}
+
+func (cmd genMigration) Handle() any {
+	var diagnostics gen.Diagnostics
+	dir := utils.StringOr(cmd.GetString("path"), cmd.dir)
+
+	protoFiles, err := scanProtoFiles(cmd.GetString("dir"))
+	if err != nil {
+		fmt.Printf("扫描目录 %s 中的 proto 文件失败: %v\n", cmd.GetString("dir"), err)
+		os.Exit(1)
+	}
+
+	generator, err := gen.NewGenerator(gen.Config{
+		OutputDir:  ".",
+		Mode:       gen.ModePro,
+		ProtoPaths: cmd.StringArrayOption("proto_path", nil),
+		Dialect:    utils.StringOr(cmd.GetString("dialect"), cmd.config.Dialect),
+	})
+	diagnostics.Add(err)
+
+	var current, previous *gen.Schema
+	if generator != nil {
+		current, err = generator.Schema(protoFiles)
+		diagnostics.Add(err)
+		previous, err = gen.LoadSchema(dir)
+		diagnostics.Add(err)
+	}
+	if diagnostics.HasErrors() {
+		diagnostics.Report(os.Stderr)
+		os.Exit(1)
+	}
+
+	diff, err := gen.DiffSchema(previous, current)
+	diagnostics.Add(err)
+	diagnostics = append(diagnostics, diff.Warnings...)
+	if diagnostics.HasErrors() {
+		diagnostics.Report(os.Stderr)
+		os.Exit(1)
+	}
+	if diff.Empty() {
+		if len(diagnostics) > 0 {
+			diagnostics.Report(os.Stderr)
+		}
+		fmt.Println("表结构没有变化，不需要生成迁移。")
+		return nil
+	}
+
+	// 与 make:migration 的文件名保持一致，migrate 命令按文件名排序执行，每个文件只能包含一条语句
+	prefix := time.Now().Format("2006_01_02_150405")
+	width := max(2, len(strconv.Itoa(len(diff.Migrations))))
+	diagnostics.Add(os.MkdirAll(dir, os.ModePerm))
+	for i, item := range diff.Migrations {
+		name := fmt.Sprintf("%s_%0*d_%s", prefix, width, i+1, cmd.GetString("name"))
+		files := []struct {
+			path      string
+			statement string
+		}{
+			{filepath.Join(dir, name+".sql"), item.Up},
+			{filepath.Join(dir, name+".down.sql"), item.Down},
+		}
+		for _, file := range files {
+			if err = os.WriteFile(file.path, []byte(file.statement+";\n"), 0644); err != nil {
+				diagnostics.Add(err)
+				continue
+			}
+			fmt.Printf("生成文件：%s\n", file.path)
+		}
+	}
+	diagnostics.Add(current.Save(dir))
+
+	if len(diagnostics) > 0 {
+		diagnostics.Report(os.Stderr)
+	}
+	if diagnostics.HasErrors() {
+		os.Exit(1)
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/goal-web/contracts"
+	"github.com/goal-web/goal-cli/app/gen"
+	"github.com/goal-web/supports/commands"
+	"github.com/goal-web/supports/logs"
+	utils2 "github.com/goal-web/supports/utils"
+)
+
+func MakeSeeder() (contracts.Command, contracts.CommandHandlerProvider) {
+	return commands.Base("make:seeder {name} {--dir:seeder 所在的目录=database/seeders}", "创建一个数据填充"),
+		func(application contracts.Application) contracts.CommandHandler {
+			return &makeSeeder{}
+		}
+}
+
+type makeSeeder struct {
+	commands.Command
+}
+
+func (cmd makeSeeder) Handle() any {
+	name := gen.ToCamelCase(cmd.GetString("name"))
+	dir := cmd.GetString("dir")
+	path := filepath.Join(dir, name+".go")
+
+	if utils2.ExistsPath(path) {
+		logs.Default().WithField("path", path).Error("seeder file is already exists.")
+		return nil
+	}
+
+	module, root, err := gen.GetModuleNameAndDir(dir)
+	if err != nil {
+		logs.Default().WithError(err).Error("获取模块名失败")
+		return nil
+	}
+	absDir, _ := filepath.Abs(dir)
+	rel, err := filepath.Rel(root, absDir)
+	if err != nil {
+		logs.Default().WithError(err).Error("seeder 目录不在模块中")
+		return nil
+	}
+
+	files := []struct {
+		path    string
+		content string
+	}{
+		{filepath.Join(dir, "seeders.go"), seedersRegistry},
+		{filepath.Join(dir, "cmd", "main.go"), fmt.Sprintf(seedersMain, module, filepath.ToSlash(filepath.Join(module, rel)))},
+		{path, fmt.Sprintf(seederStub, name, name, name, name)},
+	}
+	for _, file := range files {
+		// seeders.go 以及 cmd/main.go 只在第一次创建 seeder 时生成
+		if utils2.ExistsPath(file.path) {
+			continue
+		}
+		if err = os.MkdirAll(filepath.Dir(file.path), os.ModePerm); err == nil {
+			err = os.WriteFile(file.path, []byte(file.content), 0644)
+		}
+		if err != nil {
+			logs.Default().WithError(err).WithField("path", file.path).Error("创建 seeder 失败")
+			return nil
+		}
+		fmt.Printf("生成文件：%s\n", file.path)
+	}
+
+	return nil
+}
+
+const seederStub = `package seeders
+
+import (
+	"github.com/goal-web/contracts"
+)
+
+func init() {
+	Register("%s", %s)
+}
+
+// %s 填充数据，例如 models.UserFactory().Count(10).Create()
+func %s() contracts.Exception {
+	return nil
+}
+`
+
+const seedersRegistry = `package seeders
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/goal-web/contracts"
+	"github.com/goal-web/supports/exceptions"
+)
+
+type Seeder func() contracts.Exception
+
+var seeders = map[string]Seeder{}
+
+// Register 注册 seeder，由 make:seeder 生成的文件在 init 中调用
+func Register(name string, seeder Seeder) {
+	seeders[name] = seeder
+}
+
+// Run 依次执行指定的 seeder，没有指定时按名称顺序执行全部 seeder
+func Run(names ...string) contracts.Exception {
+	if len(names) == 0 {
+		for name := range seeders {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	for _, name := range names {
+		seeder, exists := seeders[name]
+		if !exists {
+			return exceptions.New(fmt.Sprintf("seeder %s 不存在", name))
+		}
+		fmt.Printf("Seeding: %s\n", name)
+		if err := seeder(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+`
+
+const seedersMain = `package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/goal-web/application"
+	"github.com/goal-web/config"
+	"github.com/goal-web/contracts"
+	"github.com/goal-web/database"
+	"github.com/goal-web/encryption"
+	"github.com/goal-web/events"
+	"github.com/goal-web/hashing"
+	"github.com/goal-web/supports/exceptions"
+
+	config2 "%s/config"
+	"%s"
+)
+
+// 由 db:seed 命令通过 go run 执行，参数为需要执行的 seeder，为空时执行全部
+func main() {
+	env := config.NewToml(config.File("env.toml"))
+	// 模型通过 application.Get 读取服务，需要使用全局的实例
+	app := application.Singleton(env.GetBool("app.debug"))
+
+	// events 依赖异常处理器
+	app.Singleton("exceptions.handler", func() contracts.ExceptionHandler {
+		return exceptions.DefaultExceptionHandler{}
+	})
+
+	// @cast:encrypted 以及 @cast:hashed 的字段赋值时需要 encryption 和 hashing
+	app.RegisterServices(
+		config.NewService(env, config2.GetConfigProviders()),
+		hashing.NewService(),
+		encryption.NewService(),
+		events.NewService(),
+		database.NewService(true),
+	)
+	app.Start()
+
+	if err := seeders.Run(os.Args[1:]...); err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+}
+`
@@ -7,38 +7,77 @@ import (
 
 	"github.com/goal-web/contracts"
 	"github.com/goal-web/goal-cli/app/gen"
+	"github.com/goal-web/goal-cli/config"
 	"github.com/goal-web/supports/commands"
+	"github.com/goal-web/supports/utils"
 )
 
 func NewGen() (contracts.Command, contracts.CommandHandlerProvider) {
-	return commands.Base("gen {--dir:Proto文件的路径=pro} {--out:输出的基准目录=.} {--mode:生成模式=pro} {--tmpl:模板文件路径=template.tmpl}", "通过 proto 生成代码"),
+	return commands.Base("gen {--dir:Proto文件的路径=pro} {--out:输出的基准目录=.} {--mode:生成模式=pro} {--tmpl:模板文件路径=template.tmpl} {--check:只检查生成的代码是否与 proto 一致，不写入任何文件} {--force:忽略 .goal-gen.lock，全部重新生成} {--prune:删除不再生成的文件以及失效的路由注册} {--workers:并发处理 proto 文件的数量，默认为 CPU 核数=0} {--proto_path:查找 import 的目录，多个目录用逗号分隔，默认为当前目录} {--dialect:db tag 和迁移语句的目标数据库：mysql、postgres、sqlite、clickhouse，默认读取 env.toml 中的 gen.dialect}", "通过 proto 生成代码"),
 		func(application contracts.Application) contracts.CommandHandler {
-			return &Proto{}
+			genConfig, _ := application.Get("config").(contracts.Config).Get("gen").(config.Gen)
+			return &Proto{config: genConfig}
 		}
 }
 
 type Proto struct {
 	commands.Command
+	config config.Gen
 }
 
 func (proto Proto) Handle() any {
-	tmpl := proto.GetString("tmpl")
-	out := proto.GetString("out")
 	protoFiles, err := scanProtoFiles(proto.GetString("dir"))
 	if err != nil {
 		fmt.Printf("扫描目录 %s 中的 proto 文件失败: %v\n", proto.GetString("dir"), err)
 		os.Exit(1)
 	}
 
-	if proto.GetString("mode") == "pro" {
-		// 遍历所有找到的 proto 文件，依次调用 gen.Pro()
-		for _, protoFile := range protoFiles {
-			fmt.Printf("正在处理 proto 文件: %s\n", protoFile)
-			gen.Pro(protoFile, tmpl, out)
+	// 收集所有 proto 文件的诊断信息，全部处理完之后再统一输出
+	var diagnostics gen.Diagnostics
+
+	generator, err := gen.NewGenerator(gen.Config{
+		Template:   proto.GetString("tmpl"),
+		OutputDir:  proto.GetString("out"),
+		Mode:       proto.GetString("mode"),
+		ProtoPaths: proto.StringArrayOption("proto_path", nil),
+		Workers:    proto.GetInt("workers"),
+		Force:      proto.GetBool("force"),
+		Prune:      proto.GetBool("prune"),
+		Dialect:    utils.StringOr(proto.GetString("dialect"), proto.config.Dialect),
+	})
+	if err != nil {
+		diagnostics.Add(err)
+		diagnostics.Report(os.Stderr)
+		os.Exit(1)
+	}
+
+	if proto.GetBool("check") {
+		result, err := generator.Check(protoFiles)
+		diagnostics.Add(err)
+		if result != nil {
+			result.Report(os.Stdout)
+		}
+		if len(diagnostics) > 0 {
+			diagnostics.Report(os.Stderr)
+		}
+		if diagnostics.HasErrors() || result == nil || result.HasDrift() {
+			os.Exit(1)
 		}
+		return nil
+	}
+
+	if proto.GetString("mode") == gen.ModePro {
+		// 处理所有找到的 proto 文件，跳过没有变化的文件
+		diagnostics.Add(generator.Generate(protoFiles))
 	} else {
-		// 遍历所有找到的 proto 文件，依次调用 gen.Pro()
-		gen.SDK(protoFiles, tmpl, out)
+		diagnostics.Add(generator.SDK(protoFiles))
+	}
+
+	if len(diagnostics) > 0 {
+		diagnostics.Report(os.Stderr)
+	}
+	if diagnostics.HasErrors() {
+		os.Exit(1)
 	}
 
 	return nil
@@ -9,8 +9,12 @@ import (
 var Commands = []contracts.CommandProvider{
 	commands2.NewHello,
 	commands2.NewGen,
+	commands2.GenMigration,
 	commands2.NewUpgrade,
 	config.EncryptionCommand,
 	commands2.MakeCommand,
 	commands2.MakeModel,
+	commands2.MakeSeeder,
+	commands2.DbSeed,
+	commands2.DbDiff,
 }
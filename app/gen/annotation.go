@@ -0,0 +1,471 @@
+package gen
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/scanner"
+	"unicode"
+
+	"github.com/emicklei/proto"
+)
+
+// AnnotationTarget 注解可以出现的位置，可以通过 | 组合
+type AnnotationTarget int
+
+const (
+	TargetMessage AnnotationTarget = 1 << iota
+	TargetField
+	TargetService
+	TargetRPC
+	TargetEnum
+	TargetEnumValue
+)
+
+var annotationTargetNames = []struct {
+	Target AnnotationTarget
+	Name   string
+}{
+	{TargetMessage, "message"},
+	{TargetField, "field"},
+	{TargetService, "service"},
+	{TargetRPC, "rpc"},
+	{TargetEnum, "enum"},
+	{TargetEnumValue, "enum value"},
+}
+
+func (t AnnotationTarget) String() string {
+	var names []string
+	for _, item := range annotationTargetNames {
+		if t&item.Target != 0 {
+			names = append(names, item.Name)
+		}
+	}
+	return strings.Join(names, "、")
+}
+
+// ArgType 注解参数的类型
+type ArgType int
+
+const (
+	ArgString ArgType = iota
+	ArgInt
+	ArgBool
+	ArgRaw // 冒号后面的全部内容作为一个参数，不按逗号拆分，例如 @goType:map[string]any
+)
+
+// AnnotationArg 注解的参数，既可以按位置传入，也可以通过 name=value 传入
+type AnnotationArg struct {
+	Name     string
+	Type     ArgType
+	Required bool
+}
+
+// AnnotationSpec 注解的定义
+type AnnotationSpec struct {
+	Name        string
+	Targets     AnnotationTarget
+	Args        []AnnotationArg
+	Variadic    bool // 最后一个参数可以传入多个值，例如 @method:Get,Post
+	Repeatable  bool // 同一个位置可以出现多次，例如 @middleware
+	Description string
+}
+
+// Annotation 解析后的注解
+type Annotation struct {
+	Position scanner.Position
+	Name     string
+	Args     []string // 按照定义的顺序排列，没有传入的参数为空字符串
+	Spec     *AnnotationSpec
+}
+
+// Arg 按位置获取参数，没有传入时返回默认值
+func (annotation *Annotation) Arg(index int, defaultValue string) string {
+	if annotation == nil || index >= len(annotation.Args) || annotation.Args[index] == "" {
+		return defaultValue
+	}
+	return annotation.Args[index]
+}
+
+// Get 按名称获取参数，没有传入时返回默认值
+func (annotation *Annotation) Get(name, defaultValue string) string {
+	if annotation == nil || annotation.Spec == nil {
+		return defaultValue
+	}
+	for i, arg := range annotation.Spec.Args {
+		if arg.Name == name {
+			return annotation.Arg(i, defaultValue)
+		}
+	}
+	return defaultValue
+}
+
+// Int 按名称获取整数参数，参数的格式在解析时已经校验过
+func (annotation *Annotation) Int(name string, defaultValue int) int {
+	value, err := strconv.Atoi(annotation.Get(name, ""))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// Bool 按名称获取布尔参数
+func (annotation *Annotation) Bool(name string, defaultValue bool) bool {
+	value, err := strconv.ParseBool(annotation.Get(name, ""))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// Values 全部非空的参数，用于 @method、@middleware 这类可变参数的注解
+func (annotation *Annotation) Values() []string {
+	var values []string
+	if annotation != nil {
+		for _, arg := range annotation.Args {
+			if arg != "" {
+				values = append(values, arg)
+			}
+		}
+	}
+	return values
+}
+
+// Annotations 同一个位置上的全部注解，模板中通过 .Annotations.Has "belongsTo" 之类的方式使用
+type Annotations []*Annotation
+
+// Has 是否声明了指定的注解，名称可以带也可以不带 @
+func (list Annotations) Has(name string) bool {
+	return list.Get(name) != nil
+}
+
+// Get 第一个指定名称的注解，不存在时返回 nil
+func (list Annotations) Get(name string) *Annotation {
+	name = strings.TrimPrefix(name, "@")
+	for _, annotation := range list {
+		if annotation.Name == name {
+			return annotation
+		}
+	}
+	return nil
+}
+
+// All 全部指定名称的注解
+func (list Annotations) All(name string) Annotations {
+	var result Annotations
+	name = strings.TrimPrefix(name, "@")
+	for _, annotation := range list {
+		if annotation.Name == name {
+			result = append(result, annotation)
+		}
+	}
+	return result
+}
+
+// Arg 指定注解的第 index 个参数
+func (list Annotations) Arg(name string, index int, defaultValue string) string {
+	return list.Get(name).Arg(index, defaultValue)
+}
+
+// Value 指定注解的第一个参数，常用于只有一个参数的注解，例如 @table:users
+func (list Annotations) Value(name, defaultValue string) string {
+	return list.Arg(name, 0, defaultValue)
+}
+
+// Values 全部同名注解的参数，例如多行 @middleware
+func (list Annotations) Values(name string) []string {
+	var values []string
+	for _, annotation := range list.All(name) {
+		values = append(values, annotation.Values()...)
+	}
+	return values
+}
+
+// annotationLine 注释中的一行注解，例如 @belongsTo:id,user_id 或者 @belongsTo(id, user_id)
+type annotationLine struct {
+	Name      string
+	Value     string
+	Malformed bool
+}
+
+// parseAnnotationLine 解析一行注释，不是以 @ 开头的普通注释返回 false
+func parseAnnotationLine(line string) (annotationLine, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "@") {
+		return annotationLine{}, false
+	}
+	rest := line[1:]
+	end := strings.IndexFunc(rest, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+	if end < 0 {
+		end = len(rest)
+	}
+	if end == 0 {
+		return annotationLine{}, false
+	}
+
+	result := annotationLine{Name: rest[:end]}
+	rest = rest[end:]
+	switch {
+	case rest == "":
+	case rest[0] == ':':
+		result.Value = strings.TrimSpace(rest[1:])
+	case rest[0] == '(' && strings.HasSuffix(rest, ")"):
+		result.Value = strings.TrimSpace(rest[1 : len(rest)-1])
+	case rest[0] == ' ' || rest[0] == '\t':
+		// 注解后面的说明文字，例如：@pk 主键
+	default:
+		result.Value = rest
+		result.Malformed = true
+	}
+	return result, true
+}
+
+// AnnotationRegistry 已知注解的注册表，未注册的注解只会产生警告
+type AnnotationRegistry struct {
+	mu    sync.RWMutex
+	specs map[string]*AnnotationSpec
+}
+
+// NewAnnotationRegistry 创建包含内置注解的注册表
+func NewAnnotationRegistry() *AnnotationRegistry {
+	registry := &AnnotationRegistry{specs: make(map[string]*AnnotationSpec)}
+	for _, spec := range builtinAnnotations {
+		registry.Register(spec)
+	}
+	return registry
+}
+
+// Register 注册注解，同名的注解会被覆盖
+func (r *AnnotationRegistry) Register(spec *AnnotationSpec) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.specs[spec.Name] = spec
+}
+
+// Lookup 查找注解的定义
+func (r *AnnotationRegistry) Lookup(name string) *AnnotationSpec {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.specs[strings.TrimPrefix(name, "@")]
+}
+
+// suggest 找出与未知注解最接近的已知注解，用于提示拼写错误
+func (r *AnnotationRegistry) suggest(name string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var names []string
+	for known := range r.specs {
+		names = append(names, known)
+	}
+	sort.Strings(names)
+
+	best, bestDistance := "", 3
+	for _, known := range names {
+		if distance := editDistance(strings.ToLower(name), strings.ToLower(known)); distance < bestDistance {
+			best, bestDistance = known, distance
+		}
+	}
+	return best
+}
+
+// Parse 解析注释中的全部注解，误用会产生错误，未知的注解会产生警告
+func (r *AnnotationRegistry) Parse(comment *proto.Comment, target AnnotationTarget, subject string) (Annotations, error) {
+	if comment == nil {
+		return nil, nil
+	}
+	var diagnostics Diagnostics
+	var annotations Annotations
+	for i, text := range comment.Lines {
+		line, ok := parseAnnotationLine(text)
+		if !ok {
+			continue
+		}
+		pos := comment.Position
+		pos.Line += i
+
+		spec := r.Lookup(line.Name)
+		if spec == nil {
+			message := fmt.Sprintf("未知的注解 @%s", line.Name)
+			if suggestion := r.suggest(line.Name); suggestion != "" {
+				message += fmt.Sprintf("，是否想使用 @%s？", suggestion)
+			}
+			warning := NewDiagnostic(pos, subject, errors.New(message))
+			warning.Severity = SeverityWarning
+			diagnostics.Add(warning)
+			// 未知的注解仍然保留，自定义模板可以继续使用，通过 RegisterAnnotation 注册后就不会再有警告
+			annotations = append(annotations, &Annotation{Position: pos, Name: line.Name, Args: splitArgs(line.Value)})
+			continue
+		}
+
+		if line.Malformed {
+			diagnostics.Errorf(pos, subject, "无法解析注解 @%s%s，参数需要写成 @%s:a,b 或者 @%s(a, b)", line.Name, line.Value, line.Name, line.Name)
+			continue
+		}
+		if spec.Targets&target == 0 {
+			diagnostics.Errorf(pos, subject, "@%s 只能用于 %s", spec.Name, spec.Targets)
+			continue
+		}
+		if !spec.Repeatable && annotations.Has(spec.Name) {
+			diagnostics.Errorf(pos, subject, "@%s 重复声明", spec.Name)
+			continue
+		}
+
+		args, err := spec.parseArgs(line.Value)
+		if err != nil {
+			diagnostics.Add(NewDiagnostic(pos, subject, err))
+			continue
+		}
+		annotations = append(annotations, &Annotation{Position: pos, Name: spec.Name, Args: args, Spec: spec})
+	}
+	return annotations, diagnostics.Err()
+}
+
+// parseArgs 把注解的参数按照定义的顺序排列，并校验参数的个数和类型
+func (spec *AnnotationSpec) parseArgs(value string) ([]string, error) {
+	args := make([]string, len(spec.Args))
+	if value != "" {
+		items := splitArgs(value)
+		if len(spec.Args) == 1 && spec.Args[0].Type == ArgRaw {
+			items = []string{value}
+		}
+
+		for i, item := range items {
+			if key, named, ok := strings.Cut(item, "="); ok && spec.argIndex(strings.TrimSpace(key)) >= 0 {
+				args[spec.argIndex(strings.TrimSpace(key))] = strings.TrimSpace(named)
+				continue
+			}
+			switch {
+			case i < len(args):
+				args[i] = item
+			case spec.Variadic:
+				args = append(args, item)
+			default:
+				return nil, fmt.Errorf("@%s 最多接受 %d 个参数，实际传入了 %d 个", spec.Name, len(spec.Args), len(items))
+			}
+		}
+	}
+
+	for i, arg := range spec.Args {
+		value := args[i]
+		if value == "" {
+			if arg.Required {
+				return nil, fmt.Errorf("@%s 缺少参数 %s", spec.Name, arg.Name)
+			}
+			continue
+		}
+		switch arg.Type {
+		case ArgInt:
+			if _, err := strconv.Atoi(value); err != nil {
+				return nil, fmt.Errorf("@%s 的参数 %s 必须是整数，实际为 %q", spec.Name, arg.Name, value)
+			}
+		case ArgBool:
+			if _, err := strconv.ParseBool(value); err != nil {
+				return nil, fmt.Errorf("@%s 的参数 %s 必须是布尔值，实际为 %q", spec.Name, arg.Name, value)
+			}
+		}
+	}
+	return args, nil
+}
+
+// splitArgs 按逗号拆分参数
+func splitArgs(value string) []string {
+	if value == "" {
+		return nil
+	}
+	items := strings.Split(value, ",")
+	for i, item := range items {
+		items[i] = strings.TrimSpace(item)
+	}
+	return items
+}
+
+func (spec *AnnotationSpec) argIndex(name string) int {
+	for i, arg := range spec.Args {
+		if arg.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// editDistance 两个字符串的编辑距离
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(prev[j]+1, current[j-1]+1, prev[j-1]+cost)
+		}
+		prev = current
+	}
+	return prev[len(b)]
+}
+
+var throughArgs = []AnnotationArg{
+	{Name: "mid_table"},
+	{Name: "first_key"},
+	{Name: "second_key"},
+	{Name: "local_key"},
+	{Name: "second_local_key"},
+}
+
+var indexArgs = []AnnotationArg{
+	{Name: "name"},
+	{Name: "columns"}, // 多个列用分号分隔，例如：(name;email)
+}
+
+// builtinAnnotations 内置的注解
+var builtinAnnotations = []*AnnotationSpec{
+	// 消息
+	{Name: "authenticatable", Targets: TargetMessage, Description: "模型可用作登录"},
+	{Name: "table", Targets: TargetMessage, Args: []AnnotationArg{{Name: "name", Required: true}}, Description: "自定义表名"},
+	{Name: "renamedFrom", Targets: TargetMessage | TargetField, Args: []AnnotationArg{{Name: "name", Required: true}}, Description: "重命名之前的表名或者字段名，gen:migration 据此生成 RENAME 语句"},
+	{Name: "softDelete", Targets: TargetMessage, Args: []AnnotationArg{{Name: "column"}}, Description: "软删除"},
+	{Name: "timestamps", Targets: TargetMessage, Args: []AnnotationArg{{Name: "created_at"}, {Name: "updated_at"}}, Description: "自动维护创建时间和更新时间"},
+	{Name: "goType", Targets: TargetMessage | TargetField, Args: []AnnotationArg{{Name: "type", Type: ArgRaw, Required: true}}, Description: "自定义 go 类型"},
+
+	// 字段
+	{Name: "tsType", Targets: TargetField, Args: []AnnotationArg{{Name: "type", Type: ArgRaw, Required: true}}, Description: "自定义 ts 类型"},
+	{Name: "goTag", Targets: TargetField, Args: []AnnotationArg{{Name: "tag", Type: ArgRaw, Required: true}}, Description: "自定义 struct tag"},
+	{Name: "gotag", Targets: TargetField, Args: []AnnotationArg{{Name: "tag", Type: ArgRaw, Required: true}}, Description: "追加 struct tag"},
+	{Name: "pk", Targets: TargetMessage | TargetField, Args: []AnnotationArg{{Name: "strategy"}}, Variadic: true, Description: "主键，字段上可以指定生成策略 uuid、ulid、snowflake，消息上用于声明联合主键，例如 @pk:user_id,role_id"},
+	{Name: "ptr", Targets: TargetField, Description: "使用指针类型"},
+	{Name: "nullable", Targets: TargetField, Description: "可以为空"},
+	{Name: "carbon", Targets: TargetField, Description: "时间字段，生成 carbon 方法"},
+	{Name: "hidden", Targets: TargetField, Description: "序列化时隐藏"},
+	{Name: "with", Targets: TargetField, Description: "默认加载的关联关系"},
+	{Name: "version", Targets: TargetField, Description: "乐观锁版本号，模型更新时校验并自增"},
+	{Name: "cast", Targets: TargetField, Args: []AnnotationArg{{Name: "type", Required: true}}, Description: "存储转换，可选值：encrypted 加密存储、hashed 只保存哈希、json 保存为 json"},
+	{Name: "index", Targets: TargetField, Args: indexArgs, Description: "普通索引"},
+	{Name: "unique", Targets: TargetField, Args: indexArgs, Description: "唯一索引"},
+	{Name: "validate", Targets: TargetField, Args: []AnnotationArg{{Name: "rules", Type: ArgRaw, Required: true}}, Repeatable: true, Description: "校验规则，例如 @validate:required,min=1,max=20"},
+	{Name: "belongsTo", Targets: TargetField, Args: []AnnotationArg{{Name: "owner_key"}, {Name: "local_key"}}},
+	{Name: "hasOne", Targets: TargetField, Args: []AnnotationArg{{Name: "local_key"}, {Name: "foreign_key"}}},
+	{Name: "hasMany", Targets: TargetField, Args: []AnnotationArg{{Name: "foreign_key"}, {Name: "local_key"}}},
+	{Name: "hasOneThrough", Targets: TargetField, Args: throughArgs},
+	{Name: "hasManyThrough", Targets: TargetField, Args: throughArgs},
+	{Name: "belongsToMany", Targets: TargetField, Args: throughArgs},
+	{Name: "belongsToThrough", Targets: TargetField, Args: throughArgs},
+
+	// 服务
+	{Name: "controller", Targets: TargetService, Args: []AnnotationArg{{Name: "prefix", Type: ArgRaw}}, Description: "生成控制器以及路由"},
+	{Name: "middleware", Targets: TargetService | TargetRPC, Args: []AnnotationArg{{Name: "name", Required: true}}, Variadic: true, Repeatable: true},
+	{Name: "method", Targets: TargetRPC, Args: []AnnotationArg{{Name: "method", Required: true}}, Variadic: true, Description: "http 方法"},
+	{Name: "path", Targets: TargetRPC, Args: []AnnotationArg{{Name: "path", Type: ArgRaw, Required: true}}, Description: "http 路径"},
+
+	// 枚举
+	{Name: "msg", Targets: TargetEnumValue, Args: []AnnotationArg{{Name: "message", Type: ArgRaw, Required: true}}, Description: "枚举值的说明"},
+}
@@ -0,0 +1,47 @@
+package gen
+
+import (
+	"fmt"
+	"slices"
+)
+
+var scalarTypes = []string{"string", "bytes", "bool"}
+
+// extractCasts 解析 @cast 声明的存储转换：encrypted 加密存储，hashed 只保存哈希，json 把结构体或者切片保存为 json
+func (g *Generator) extractCasts(message *Message) error {
+	var diagnostics Diagnostics
+	for _, field := range message.Fields {
+		annotation := field.Annotations.Get("cast")
+		if annotation == nil {
+			continue
+		}
+		subject := fmt.Sprintf("field %s.%s", message.Name, field.JSONName)
+		kind := annotation.Arg(0, "")
+		scalar := numericTypes[field.Type] || slices.Contains(scalarTypes, field.Type) || g.registry.LookupEnum(field.Type) != nil
+		switch {
+		case !message.IsModel:
+			diagnostics.Errorf(annotation.Position, subject, "@cast 只能用于模型的字段")
+		case kind != "encrypted" && kind != "hashed" && kind != "json":
+			diagnostics.Errorf(annotation.Position, subject, "未知的 @cast 类型 %s，可选值：encrypted、hashed、json", kind)
+		case field.IsPrimaryKey() || field.JSONName == message.SoftDelete || field.JSONName == message.Version:
+			diagnostics.Errorf(annotation.Position, subject, "@cast 不能用于主键、软删除以及版本号字段")
+		case kind == "json" && (field.IsModel || field.Oneof != nil || field.WellKnown != nil || (scalar && !field.Repeated)):
+			diagnostics.Errorf(annotation.Position, subject, "@cast:json 只能用于消息、repeated 以及 map 类型的字段，实际是 %s", field.Type)
+		case kind != "json" && (field.Type != "string" || field.Repeated || field.GoType != ""):
+			diagnostics.Errorf(annotation.Position, subject, "@cast:%s 只能用于 string 类型的字段", kind)
+		default:
+			field.Cast = kind
+		}
+	}
+	return diagnostics.Err()
+}
+
+// HasCast 是否有字段声明了指定的 @cast，没有指定类型时判断任意 @cast
+func (message *Message) HasCast(kinds ...string) bool {
+	for _, field := range message.Fields {
+		if field.Cast != "" && (len(kinds) == 0 || slices.Contains(kinds, field.Cast)) {
+			return true
+		}
+	}
+	return false
+}
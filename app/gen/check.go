@@ -0,0 +1,181 @@
+package gen
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/scanner"
+)
+
+// generatedMarker goal-cli 生成的 Go 文件头部的第一行
+const generatedMarker = "// Code generated by goal-cli."
+
+// CheckResult 漂移检测的结果，路径均相对于输出目录
+type CheckResult struct {
+	Stale   []string // 内容与 proto 不一致
+	Missing []string // 应该生成但是磁盘上不存在
+	Extra   []string // 磁盘上存在但是不会再生成
+	Diffs   []string
+}
+
+// HasDrift 磁盘上的代码是否与 proto 不一致
+func (result *CheckResult) HasDrift() bool {
+	return len(result.Stale)+len(result.Missing)+len(result.Extra) > 0
+}
+
+// Report 输出检测报告以及 unified diff
+func (result *CheckResult) Report(w io.Writer) {
+	for _, file := range result.Stale {
+		_, _ = fmt.Fprintf(w, "过期的文件：%s\n", file)
+	}
+	for _, file := range result.Missing {
+		_, _ = fmt.Fprintf(w, "缺失的文件：%s\n", file)
+	}
+	for _, file := range result.Extra {
+		_, _ = fmt.Fprintf(w, "多余的文件：%s\n", file)
+	}
+	for _, diff := range result.Diffs {
+		_, _ = fmt.Fprint(w, diff)
+	}
+	if result.HasDrift() {
+		_, _ = fmt.Fprintf(w, "生成的代码与 proto 不一致：%d 个过期，%d 个缺失，%d 个多余，请重新执行 goal-cli gen\n",
+			len(result.Stale), len(result.Missing), len(result.Extra))
+	} else {
+		_, _ = fmt.Fprintln(w, "生成的代码与 proto 一致。")
+	}
+}
+
+// Check 在内存中渲染全部目标并与磁盘上的文件比较，不会写入任何文件
+func (g *Generator) Check(protoFiles []string) (*CheckResult, error) {
+	var diagnostics Diagnostics
+	outputDirAbs := g.outputDirAbs
+
+	var files []*File
+	suffix := "_gen.ts"
+	if !g.sdk() {
+		suffix = "_gen.go"
+		var routers []*RouterCollector
+		outputs, err := g.Render(protoFiles)
+		diagnostics.Add(err)
+		for _, output := range outputs {
+			if output != nil {
+				files = append(files, output.Files...)
+				routers = append(routers, output.Routers...)
+			}
+		}
+
+		if len(routers) > 0 {
+			kernel, err := renderKernel(KernelPath(outputDirAbs), routers)
+			diagnostics.Add(err)
+			if kernel != nil {
+				files = append(files, kernel)
+			}
+		}
+	} else {
+		output, err := g.RenderSDK(protoFiles)
+		diagnostics.Add(err)
+		if output != nil {
+			files = output.Files
+		}
+	}
+
+	if diagnostics.HasErrors() {
+		return nil, diagnostics
+	}
+
+	var result CheckResult
+	rendered := make(map[string]*File)
+	for _, file := range files {
+		rendered[file.Path] = file
+	}
+
+	var paths []string
+	for path := range rendered {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		name := relativePath(outputDirAbs, path)
+		content := stripGeneratedHeader(rendered[path].Content)
+		disk, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			result.Missing = append(result.Missing, name)
+			result.Diffs = append(result.Diffs, UnifiedDiff("/dev/null", "b/"+name, nil, content))
+			continue
+		} else if err != nil {
+			diagnostics.Add(NewDiagnostic(scanner.Position{Filename: path}, "check", err))
+			continue
+		}
+
+		if diff := UnifiedDiff("a/"+name, "b/"+name, stripGeneratedHeader(disk), content); diff != "" {
+			result.Stale = append(result.Stale, name)
+			result.Diffs = append(result.Diffs, diff)
+		}
+	}
+
+	// 查找磁盘上由 goal-cli 生成，但是这次不会再生成的文件
+	err := filepath.WalkDir(outputDirAbs, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if path != outputDirAbs && (strings.HasPrefix(entry.Name(), ".") || entry.Name() == "node_modules" || entry.Name() == "vendor") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, suffix) || rendered[path] != nil {
+			return nil
+		}
+		if suffix == "_gen.go" {
+			content, err := os.ReadFile(path)
+			if err != nil || !bytes.HasPrefix(content, []byte(generatedMarker)) {
+				return err
+			}
+		}
+		result.Extra = append(result.Extra, relativePath(outputDirAbs, path))
+		return nil
+	})
+	diagnostics.Add(err)
+
+	return &result, diagnostics.Err()
+}
+
+// renderKernel 在内存中把全部路由注册到 kernel 文件
+func renderKernel(filename string, routers []*RouterCollector) (*File, error) {
+	content, err := ReadKernel(filename)
+	if err != nil {
+		return nil, NewDiagnostic(scanner.Position{Filename: filename}, "router", err)
+	}
+	for _, router := range routers {
+		content, _, err = RenderRouter(filename, content, router.ImportPath, router.UsageName)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &File{Path: filename, Content: content}, nil
+}
+
+// stripGeneratedHeader 去掉生成文件的头部注释，头部包含生成时间等每次都会变化的信息
+func stripGeneratedHeader(content []byte) []byte {
+	if !bytes.HasPrefix(content, []byte(generatedMarker)) {
+		return content
+	}
+	if index := bytes.Index(content, []byte("\npackage ")); index >= 0 {
+		return content[index+1:]
+	}
+	return content
+}
+
+func relativePath(base, path string) string {
+	if rel, err := filepath.Rel(base, path); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
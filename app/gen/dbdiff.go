@@ -0,0 +1,225 @@
+package gen
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// DatabaseDiff db:diff 的结果，对比模型推导的表结构与数据库中实际的表结构
+type DatabaseDiff struct {
+	MissingTables      []string       `json:"missing_tables"`
+	MissingColumns     []*ColumnDrift `json:"missing_columns"`
+	TypeMismatches     []*ColumnDrift `json:"type_mismatches"`
+	NullableMismatches []*ColumnDrift `json:"nullable_mismatches"`
+	MissingIndexes     []*IndexDrift  `json:"missing_indexes"`
+	KeyMismatches      []*KeyDrift    `json:"key_mismatches"`
+}
+
+// ColumnDrift 字段的差异，Expected 为模型中的定义，Actual 为数据库中的定义
+type ColumnDrift struct {
+	Table    string `json:"table"`
+	Column   string `json:"column"`
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+}
+
+// IndexDrift 数据库中缺少的索引
+type IndexDrift struct {
+	Table   string `json:"table"`
+	Name    string `json:"name"`
+	Unique  bool   `json:"unique"`
+	Columns string `json:"columns"`
+}
+
+// KeyDrift 联合主键的差异
+type KeyDrift struct {
+	Table    string `json:"table"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+// HasDrift 数据库是否与模型不一致
+func (diff *DatabaseDiff) HasDrift() bool {
+	return len(diff.MissingTables)+len(diff.MissingColumns)+len(diff.TypeMismatches)+len(diff.NullableMismatches)+len(diff.MissingIndexes)+len(diff.KeyMismatches) > 0
+}
+
+// Report 输出文本格式的报告
+func (diff *DatabaseDiff) Report(w io.Writer) {
+	for _, table := range diff.MissingTables {
+		_, _ = fmt.Fprintf(w, "缺失的表：%s\n", table)
+	}
+	for _, drift := range diff.MissingColumns {
+		_, _ = fmt.Fprintf(w, "缺失的字段：%s.%s %s\n", drift.Table, drift.Column, drift.Expected)
+	}
+	for _, drift := range diff.TypeMismatches {
+		_, _ = fmt.Fprintf(w, "类型不一致：%s.%s 模型为 %s，数据库为 %s\n", drift.Table, drift.Column, drift.Expected, drift.Actual)
+	}
+	for _, drift := range diff.NullableMismatches {
+		_, _ = fmt.Fprintf(w, "可空性不一致：%s.%s 模型为 %s，数据库为 %s\n", drift.Table, drift.Column, drift.Expected, drift.Actual)
+	}
+	for _, drift := range diff.MissingIndexes {
+		kind := "索引"
+		if drift.Unique {
+			kind = "唯一索引"
+		}
+		_, _ = fmt.Fprintf(w, "缺失的%s：%s.%s %s\n", kind, drift.Table, drift.Name, drift.Columns)
+	}
+	for _, drift := range diff.KeyMismatches {
+		_, _ = fmt.Fprintf(w, "主键不一致：%s 模型为 %s，数据库为 %s\n", drift.Table, drift.Expected, drift.Actual)
+	}
+	if diff.HasDrift() {
+		_, _ = fmt.Fprintf(w, "数据库与模型不一致：%d 张表缺失，%d 个字段缺失，%d 个类型不一致，%d 个可空性不一致，%d 个索引缺失，%d 个主键不一致\n",
+			len(diff.MissingTables), len(diff.MissingColumns), len(diff.TypeMismatches), len(diff.NullableMismatches), len(diff.MissingIndexes), len(diff.KeyMismatches))
+	} else {
+		_, _ = fmt.Fprintln(w, "数据库与模型一致。")
+	}
+}
+
+// DiffDatabase 对比模型推导的表结构与数据库中的表结构，数据库中多出来的表和字段不会报告
+func DiffDatabase(schema *Schema, inspector Inspector) (*DatabaseDiff, error) {
+	diff := &DatabaseDiff{
+		MissingTables:      []string{},
+		MissingColumns:     []*ColumnDrift{},
+		TypeMismatches:     []*ColumnDrift{},
+		NullableMismatches: []*ColumnDrift{},
+		MissingIndexes:     []*IndexDrift{},
+		KeyMismatches:      []*KeyDrift{},
+	}
+	tables, err := inspector.Tables()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, expected := range schema.Tables {
+		if !slices.Contains(tables, expected.Name) {
+			diff.MissingTables = append(diff.MissingTables, expected.Name)
+			continue
+		}
+		actual, err := inspector.Table(expected.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, column := range expected.Columns {
+			actualColumn := actual.Column(column.Name)
+			if actualColumn == nil {
+				diff.MissingColumns = append(diff.MissingColumns, &ColumnDrift{Table: expected.Name, Column: column.Name, Expected: column.Type})
+				continue
+			}
+			if !sameSQLType(actual.Dialect, column.Type, actualColumn.Type) {
+				diff.TypeMismatches = append(diff.TypeMismatches, &ColumnDrift{
+					Table: expected.Name, Column: column.Name, Expected: column.Type, Actual: actualColumn.Type,
+				})
+			}
+			if nullable := column.nullable(); nullable != actualColumn.Nullable {
+				diff.NullableMismatches = append(diff.NullableMismatches, &ColumnDrift{
+					Table: expected.Name, Column: column.Name, Expected: nullability(nullable), Actual: nullability(actualColumn.Nullable),
+				})
+			}
+		}
+
+		// clickhouse 没有普通索引和唯一索引
+		if actual.Dialect == "clickhouse" {
+			continue
+		}
+		for _, index := range expected.Indexes {
+			if !actual.hasIndex(index) {
+				diff.MissingIndexes = append(diff.MissingIndexes, &IndexDrift{
+					Table: expected.Name, Name: index.Name, Unique: index.Unique, Columns: index.Columns,
+				})
+			}
+		}
+		// 数据库返回的主键字段按照表中字段的顺序排列，只比较包含的字段
+		if keys := actual.PrimaryKeys(); len(expected.PrimaryKey) > 0 && !slices.Equal(slices.Sorted(slices.Values(expected.PrimaryKey)), slices.Sorted(slices.Values(keys))) {
+			diff.KeyMismatches = append(diff.KeyMismatches, &KeyDrift{
+				Table: expected.Name, Expected: "(" + strings.Join(expected.PrimaryKey, ",") + ")", Actual: "(" + strings.Join(keys, ",") + ")",
+			})
+		}
+	}
+	return diff, nil
+}
+
+// nullable 没有 not null 约束并且不是主键的字段可以为空
+func (column *ColumnSchema) nullable() bool {
+	constraints := strings.ToLower(column.Constraints)
+	return !strings.Contains(constraints, "not null") && !strings.Contains(constraints, "primary key")
+}
+
+func nullability(nullable bool) string {
+	if nullable {
+		return "null"
+	}
+	return "not null"
+}
+
+// hasIndex 按照字段判断索引是否存在，不同环境中索引的名称可能不一样，主键也可以满足索引
+func (table *TableInfo) hasIndex(index *IndexSchema) bool {
+	var columns []string
+	for _, column := range strings.Split(strings.Trim(index.Columns, "() "), ",") {
+		columns = append(columns, strings.TrimSpace(column))
+	}
+	if slices.Equal(columns, table.PrimaryKeys()) {
+		return true
+	}
+	for _, actual := range table.Indexes {
+		if slices.Equal(columns, actual.Columns) && (actual.Unique || !index.Unique) {
+			return true
+		}
+	}
+	return false
+}
+
+// sqlTypeAliases 同一种类型在不同数据库中的名称，统一之后再比较
+var sqlTypeAliases = map[string]string{
+	"integer":                     "int",
+	"int4":                        "int",
+	"int2":                        "smallint",
+	"int8":                        "bigint",
+	"bool":                        "boolean",
+	"character varying":           "varchar",
+	"character":                   "char",
+	"double precision":            "double",
+	"float8":                      "double",
+	"numeric":                     "decimal",
+	"timestamp without time zone": "timestamp",
+	"time without time zone":      "time",
+}
+
+// sqlTypeWithArgs 长度或者精度会影响取值范围的类型，其余类型的参数只是显示宽度
+var sqlTypeWithArgs = []string{"char", "varchar", "decimal", "binary", "varbinary", "bit"}
+
+// sameSQLType 模型中的类型与数据库返回的类型是否一致，sqlite 只比较类型亲和性，clickhouse 的类型名称区分大小写并且有很多别名，只比较对应的 proto 类型
+func sameSQLType(dialect, expected, actual string) bool {
+	switch dialect {
+	case "sqlite3":
+		// sqlite 不检查长度，亲和性相同的类型存储方式相同
+		return sqliteAffinity(ParseSQLType(expected).Base) == sqliteAffinity(ParseSQLType(actual).Base)
+	case "clickhouse":
+		types := &ProtoTypes{Dialect: dialect}
+		expectedType, _ := types.Lookup(expected)
+		actualType, _ := types.Lookup(actual)
+		return expectedType == actualType
+	}
+	return canonicalSQLType(dialect, expected) == canonicalSQLType(dialect, actual)
+}
+
+func canonicalSQLType(dialect, raw string) string {
+	sqlType := ParseSQLType(raw)
+	base := sqlType.Base
+	if alias, exists := sqlTypeAliases[base]; exists {
+		base = alias
+	}
+	// mysql 的 BOOLEAN 实际存储为 tinyint(1)
+	if dialect == "mysql" && base == "tinyint" && sqlType.Args == "1" {
+		base = "boolean"
+	}
+	if slices.Contains(sqlTypeWithArgs, base) && sqlType.Args != "" {
+		base += "(" + strings.ReplaceAll(sqlType.Args, " ", "") + ")"
+	}
+	if sqlType.Unsigned {
+		base += " unsigned"
+	}
+	return base
+}
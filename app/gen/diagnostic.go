@@ -0,0 +1,165 @@
+package gen
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/scanner"
+)
+
+// Severity 诊断信息的级别
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	if s == SeverityWarning {
+		return "warning"
+	}
+	return "error"
+}
+
+// Diagnostic 生成过程中的一条诊断信息，包含 proto 源码位置以及出问题的对象
+type Diagnostic struct {
+	Severity Severity
+	Position scanner.Position
+	Subject  string // 出问题的对象，例如：message UserModel、field UserModel.name、rpc AuthService.Login
+	Err      error
+}
+
+// NewDiagnostic 创建一条错误级别的诊断信息
+func NewDiagnostic(pos scanner.Position, subject string, err error) *Diagnostic {
+	return &Diagnostic{
+		Severity: SeverityError,
+		Position: pos,
+		Subject:  subject,
+		Err:      err,
+	}
+}
+
+func (d *Diagnostic) Error() string {
+	var sb strings.Builder
+	if d.Position.Filename != "" {
+		sb.WriteString(d.Position.Filename)
+		if d.Position.Line > 0 {
+			sb.WriteString(fmt.Sprintf(":%d:%d", d.Position.Line, d.Position.Column))
+		}
+		sb.WriteString(": ")
+	}
+	sb.WriteString(d.Severity.String())
+	sb.WriteString(": ")
+	if d.Subject != "" {
+		sb.WriteString(d.Subject)
+		sb.WriteString(": ")
+	}
+	if d.Err != nil {
+		sb.WriteString(d.Err.Error())
+	}
+	return sb.String()
+}
+
+func (d *Diagnostic) Unwrap() error {
+	return d.Err
+}
+
+// Diagnostics 诊断信息集合，本身实现了 error，方便在各阶段之间传递
+type Diagnostics []*Diagnostic
+
+func (d Diagnostics) Error() string {
+	var lines []string
+	for _, diagnostic := range d {
+		lines = append(lines, diagnostic.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Add 收集一个错误，Diagnostics 会被展开，普通 error 会被包装成没有位置的诊断信息，重复的诊断会被忽略
+func (d *Diagnostics) Add(err error) {
+	if err == nil {
+		return
+	}
+
+	var list Diagnostics
+	var diagnostic *Diagnostic
+	switch {
+	case errors.As(err, &list):
+		for _, item := range list {
+			d.Add(item)
+		}
+		return
+	case errors.As(err, &diagnostic):
+	default:
+		diagnostic = NewDiagnostic(scanner.Position{}, "", err)
+	}
+
+	for _, exists := range *d {
+		if exists.Error() == diagnostic.Error() {
+			return
+		}
+	}
+	*d = append(*d, diagnostic)
+}
+
+// Errorf 记录一条带有位置信息的错误
+func (d *Diagnostics) Errorf(pos scanner.Position, subject, format string, args ...any) {
+	d.Add(NewDiagnostic(pos, subject, fmt.Errorf(format, args...)))
+}
+
+// HasErrors 是否包含错误级别的诊断信息
+func (d Diagnostics) HasErrors() bool {
+	for _, diagnostic := range d {
+		if diagnostic.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// HasErrors err 中是否包含错误级别的诊断信息，只有警告时返回 false
+func HasErrors(err error) bool {
+	var diagnostics Diagnostics
+	diagnostics.Add(err)
+	return diagnostics.HasErrors()
+}
+
+// Err 没有任何诊断信息时返回 nil，避免 typed nil 的问题
+func (d Diagnostics) Err() error {
+	if len(d) == 0 {
+		return nil
+	}
+	return d
+}
+
+// Report 输出完整的诊断报告
+func (d Diagnostics) Report(w io.Writer) {
+	var errorCount, warningCount int
+	for _, diagnostic := range d {
+		_, _ = fmt.Fprintln(w, diagnostic.Error())
+		if diagnostic.Severity == SeverityError {
+			errorCount++
+		} else {
+			warningCount++
+		}
+	}
+	_, _ = fmt.Fprintf(w, "共 %d 个错误，%d 个警告\n", errorCount, warningCount)
+}
+
+var parseErrorRegexp = regexp.MustCompile(`^(.+?):(\d+):(\d+): (.*)$`)
+
+// parseErrorDiagnostic 把 proto 解析器返回的错误转换成带位置的诊断信息
+func parseErrorDiagnostic(filename string, err error) *Diagnostic {
+	pos := scanner.Position{Filename: filename}
+	msg := strings.TrimSpace(err.Error())
+	if matches := parseErrorRegexp.FindStringSubmatch(msg); matches != nil {
+		pos.Line, _ = strconv.Atoi(matches[2])
+		pos.Column, _ = strconv.Atoi(matches[3])
+		msg = matches[4]
+	}
+	return NewDiagnostic(pos, "", errors.New(msg))
+}
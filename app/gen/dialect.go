@@ -0,0 +1,164 @@
+package gen
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// 生成 db tag 以及迁移语句时支持的数据库
+const (
+	DialectMySQL      = "mysql"
+	DialectPostgres   = "postgres"
+	DialectSQLite     = "sqlite"
+	DialectClickHouse = "clickhouse"
+)
+
+// Dialects 全部支持的数据库
+var Dialects = []string{DialectMySQL, DialectPostgres, DialectSQLite, DialectClickHouse}
+
+// DialectOf 连接的驱动名称或者配置中的名称对应的数据库，为空时使用 mysql
+func DialectOf(name string) string {
+	switch name = strings.ToLower(strings.TrimSpace(name)); name {
+	case "":
+		return DialectMySQL
+	case "sqlite3":
+		return DialectSQLite
+	case "pgsql", "postgresql":
+		return DialectPostgres
+	}
+	return name
+}
+
+// dialectTypes 字段类型在各个数据库中的写法，key 为大写的 mysql 类型，没有列出的类型保持不变
+var dialectTypes = map[string]map[string]string{
+	DialectPostgres: {
+		"DOUBLE": "DOUBLE PRECISION",
+		"FLOAT":  "REAL",
+		"INT":    "INTEGER",
+		// postgres 没有无符号整数，uint32 使用 BIGINT，uint64 超过 int64 范围的值无法保存
+		"INT UNSIGNED":    "BIGINT",
+		"BIGINT UNSIGNED": "BIGINT",
+		"BLOB":            "BYTEA",
+		"JSON":            "JSONB",
+		"TIMESTAMP":       "TIMESTAMP",
+	},
+	DialectSQLite: {
+		// sqlite 的整数都是 64 位，自增主键必须声明为 INTEGER
+		"INT":             "INTEGER",
+		"BIGINT":          "INTEGER",
+		"INT UNSIGNED":    "INTEGER",
+		"BIGINT UNSIGNED": "INTEGER",
+		"DOUBLE":          "REAL",
+		"FLOAT":           "REAL",
+		"JSON":            "TEXT",
+		"TIMESTAMP":       "TIMESTAMP",
+	},
+	DialectClickHouse: {
+		"DOUBLE":          "Float64",
+		"FLOAT":           "Float32",
+		"INT":             "Int32",
+		"BIGINT":          "Int64",
+		"INT UNSIGNED":    "UInt32",
+		"BIGINT UNSIGNED": "UInt64",
+		"BOOLEAN":         "Bool",
+		"VARCHAR(255)":    "String",
+		"TEXT":            "String",
+		"BLOB":            "String",
+		"JSON":            "String",
+		"TIMESTAMP":       "DateTime",
+		"CHAR(36)":        "FixedString(36)",
+		"CHAR(26)":        "FixedString(26)",
+	},
+}
+
+// columnType mysql 类型在当前数据库中的写法
+func columnType(dialect, mysqlType string) string {
+	if value, exists := dialectTypes[dialect][strings.ToUpper(mysqlType)]; exists {
+		return value
+	}
+	return mysqlType
+}
+
+// autoIncrement 自增主键的约束，clickhouse 不支持自增，需要在代码中生成主键
+func autoIncrement(dialect string) string {
+	switch dialect {
+	case DialectPostgres:
+		return "GENERATED BY DEFAULT AS IDENTITY;"
+	case DialectSQLite:
+		return "AUTOINCREMENT;"
+	case DialectClickHouse:
+		return ""
+	}
+	return "AUTO_INCREMENT;"
+}
+
+// currentTimestamp 当前时间作为默认值，onUpdate 为 true 时更新记录也会刷新，只有 mysql 可以直接声明在字段上，其他数据库通过 autoUpdateStatements 创建的触发器实现
+func currentTimestamp(dialect string, onUpdate bool) string {
+	switch {
+	case dialect == DialectClickHouse:
+		return "default now();"
+	case onUpdate && dialect == DialectMySQL:
+		return "DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP;"
+	}
+	return "default CURRENT_TIMESTAMP;"
+}
+
+// quote 引用表名和字段名，postgres 和 sqlite 使用标准的双引号
+func quote(dialect, name string) string {
+	if dialect == DialectPostgres || dialect == DialectSQLite {
+		return `"` + name + `"`
+	}
+	return "`" + name + "`"
+}
+
+// indexName postgres 和 sqlite 的索引名在整个库中唯一，没有以表名开头的索引加上表名作为前缀
+func indexName(dialect, table, name string) string {
+	if (dialect == DialectPostgres || dialect == DialectSQLite) && !strings.HasPrefix(name, table+"_") {
+		return table + "_" + name
+	}
+	return name
+}
+
+// autoUpdateStatements 更新记录时刷新字段的触发器，与 mysql 的 ON UPDATE 一样，显式修改该字段时不会覆盖，clickhouse 使用 ReplacingMergeTree 按照该字段保留最新的数据
+func autoUpdateStatements(dialect, table, column string) (up, down []string) {
+	name := table + "_set_" + column
+	switch dialect {
+	case DialectPostgres:
+		up = []string{
+			fmt.Sprintf("CREATE OR REPLACE FUNCTION %s() RETURNS trigger AS $$\nBEGIN\n  IF NEW.%s IS NOT DISTINCT FROM OLD.%s THEN\n    NEW.%s = CURRENT_TIMESTAMP;\n  END IF;\n  RETURN NEW;\nEND;\n$$ LANGUAGE plpgsql",
+				quote(dialect, name), quote(dialect, column), quote(dialect, column), quote(dialect, column)),
+			fmt.Sprintf("CREATE TRIGGER %s BEFORE UPDATE ON %s FOR EACH ROW EXECUTE FUNCTION %s()",
+				quote(dialect, name), quote(dialect, table), quote(dialect, name)),
+		}
+		down = []string{
+			fmt.Sprintf("DROP TRIGGER IF EXISTS %s ON %s", quote(dialect, name), quote(dialect, table)),
+			fmt.Sprintf("DROP FUNCTION IF EXISTS %s()", quote(dialect, name)),
+		}
+	case DialectSQLite:
+		// sqlite 默认不会递归触发
+		up = []string{fmt.Sprintf(
+			"CREATE TRIGGER %s AFTER UPDATE ON %s FOR EACH ROW WHEN NEW.%s IS OLD.%s\nBEGIN\n  UPDATE %s SET %s = CURRENT_TIMESTAMP WHERE rowid = NEW.rowid;\nEND",
+			quote(dialect, name), quote(dialect, table), quote(dialect, column), quote(dialect, column), quote(dialect, table), quote(dialect, column),
+		)}
+		down = []string{fmt.Sprintf("DROP TRIGGER IF EXISTS %s", quote(dialect, name))}
+	}
+	return up, down
+}
+
+// isKeyword 约束中除了默认值以外的关键字
+func isKeyword(word string) bool {
+	return slices.Contains([]string{"not", "null", "primary", "key", "default", "generated", "by", "as", "identity", "auto_increment", "autoincrement", "on", "update"}, strings.ToLower(word))
+}
+
+// columnDefault 约束中声明的默认值，没有声明时返回空字符串
+func columnDefault(constraints string) string {
+	words := strings.Fields(constraints)
+	for i, word := range words {
+		// GENERATED BY DEFAULT AS IDENTITY 不是默认值
+		if strings.EqualFold(word, "default") && i+1 < len(words) && !(i > 0 && strings.EqualFold(words[i-1], "by")) && !isKeyword(words[i+1]) {
+			return words[i+1]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,143 @@
+package gen
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext unified diff 中每个变更块前后保留的上下文行数
+const diffContext = 3
+
+// diffMaxCells 超过这个规模的文件不再计算最长公共子序列，直接整体替换
+const diffMaxCells = 16 << 20
+
+type diffOp struct {
+	Kind byte // ' '、'-'、'+'
+	Line string
+	A, B int // 该操作之前在两个文件中的行号（从 0 开始）
+}
+
+// UnifiedDiff 生成两段文本之间的 unified diff，内容一致时返回空字符串
+func UnifiedDiff(fromName, toName string, from, to []byte) string {
+	a, b := splitLines(string(from)), splitLines(string(to))
+	ops := diffLines(a, b)
+
+	var changes []int
+	for i, op := range ops {
+		if op.Kind != ' ' {
+			changes = append(changes, i)
+		}
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("--- %s\n+++ %s\n", fromName, toName))
+
+	for i := 0; i < len(changes); {
+		// 相邻的变更之间如果上下文重叠，就合并成同一个块
+		j := i
+		for j+1 < len(changes) && changes[j+1]-changes[j] <= diffContext*2 {
+			j++
+		}
+		start := max(0, changes[i]-diffContext)
+		end := min(len(ops), changes[j]+diffContext+1)
+
+		var aLen, bLen int
+		for _, op := range ops[start:end] {
+			if op.Kind != '+' {
+				aLen++
+			}
+			if op.Kind != '-' {
+				bLen++
+			}
+		}
+		aStart, bStart := ops[start].A, ops[start].B
+		if aLen > 0 {
+			aStart++
+		}
+		if bLen > 0 {
+			bStart++
+		}
+		sb.WriteString(fmt.Sprintf("@@ -%d,%d +%d,%d @@\n", aStart, aLen, bStart, bLen))
+		for _, op := range ops[start:end] {
+			sb.WriteByte(op.Kind)
+			sb.WriteString(op.Line)
+			sb.WriteByte('\n')
+		}
+		i = j + 1
+	}
+	return sb.String()
+}
+
+func splitLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
+
+// diffLines 基于最长公共子序列计算逐行的编辑脚本
+func diffLines(a, b []string) []diffOp {
+	var ops []diffOp
+
+	// 先去掉相同的前缀和后缀，减少计算量
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		ops = append(ops, diffOp{Kind: ' ', Line: a[prefix], A: prefix, B: prefix})
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	am, bm := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	n, m := len(am), len(bm)
+	ai, bi := prefix, prefix
+
+	if n*m > diffMaxCells {
+		for _, line := range am {
+			ops = append(ops, diffOp{Kind: '-', Line: line, A: ai, B: bi})
+			ai++
+		}
+		for _, line := range bm {
+			ops = append(ops, diffOp{Kind: '+', Line: line, A: ai, B: bi})
+			bi++
+		}
+	} else {
+		// lcs[i*(m+1)+j] 表示 am[i:] 与 bm[j:] 的最长公共子序列长度
+		lcs := make([]int32, (n+1)*(m+1))
+		for i := n - 1; i >= 0; i-- {
+			for j := m - 1; j >= 0; j-- {
+				if am[i] == bm[j] {
+					lcs[i*(m+1)+j] = lcs[(i+1)*(m+1)+j+1] + 1
+				} else {
+					lcs[i*(m+1)+j] = max(lcs[(i+1)*(m+1)+j], lcs[i*(m+1)+j+1])
+				}
+			}
+		}
+
+		i, j := 0, 0
+		for i < n || j < m {
+			switch {
+			case i < n && j < m && am[i] == bm[j]:
+				ops = append(ops, diffOp{Kind: ' ', Line: am[i], A: ai, B: bi})
+				i, j, ai, bi = i+1, j+1, ai+1, bi+1
+			case j >= m || (i < n && lcs[(i+1)*(m+1)+j] >= lcs[i*(m+1)+j+1]):
+				ops = append(ops, diffOp{Kind: '-', Line: am[i], A: ai, B: bi})
+				i, ai = i+1, ai+1
+			default:
+				ops = append(ops, diffOp{Kind: '+', Line: bm[j], A: ai, B: bi})
+				j, bi = j+1, bi+1
+			}
+		}
+	}
+
+	for k := 0; k < suffix; k++ {
+		ops = append(ops, diffOp{Kind: ' ', Line: a[len(a)-suffix+k], A: ai, B: bi})
+		ai, bi = ai+1, bi+1
+	}
+	return ops
+}
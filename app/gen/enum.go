@@ -2,132 +2,117 @@ package gen
 
 import (
 	"fmt"
-	"log"
-	"os"
 	"path/filepath"
 	"strings"
-	"text/template"
+	"text/scanner"
 
 	"github.com/emicklei/proto"
 )
 
 type Enum struct {
-	Package  string
-	Name     string
-	Comments []string
-	Values   []*EnumValue
-	FilePath string
+	Position   scanner.Position
+	Package    string
+	Name       string
+	Comments   []string
+	Values     []*EnumValue
+	ImportPath string // 包路径，例如：biz/enums
+	FilePath   string
 }
 
 type EnumValue struct {
-	Name     string
-	Value    int
-	Message  string
-	Comments []string
+	Position    scanner.Position
+	Annotations Annotations
+	Name        string
+	Value       int
+	Message     string
+	Comments    []string
 }
 
-func ExtractEnums(p *proto.Proto, basePackage, dir string) []*Enum {
+// ExtractEnums 提取展开后的全部枚举，嵌套的枚举使用拼接后的名称
+func (g *Generator) ExtractEnums(enums []*scopedEnum, basePackage, dir string) ([]*Enum, error) {
+	var diagnostics Diagnostics
 	var list []*Enum
-	for _, e := range p.Elements {
-		if enum, ok := e.(*proto.Enum); ok {
-			enumPath := strings.Join(trim("enums", dir, replaceSuffix(enum.Name, "Enum")+"_gen.go"), "/")
-			enumInstance := Enum{
-				Name:     enum.Name,
-				FilePath: enumPath,
-			}
+	for _, scoped := range enums {
+		enum := scoped.Enum
+		enumPath := strings.Join(trim("enums", dir, replaceSuffix(enum.Name, "Enum")+"_gen.go"), "/")
+		enumInstance := Enum{
+			Position:   enum.Position,
+			Name:       enum.Name,
+			ImportPath: strings.Join(trim(basePackage, "enums", dir), "/"),
+			FilePath:   enumPath,
+		}
 
-			if pkgs := trim("enums", dir); len(pkgs) == 1 {
-				enumInstance.Package = "enums"
-			} else {
-				enumInstance.Package = pkgs[len(pkgs)-1]
-			}
+		if pkgs := trim("enums", dir); len(pkgs) == 1 {
+			enumInstance.Package = "enums"
+		} else {
+			enumInstance.Package = pkgs[len(pkgs)-1]
+		}
 
-			if enum.Comment != nil {
-				enumInstance.Comments = enum.Comment.Lines
-			}
+		if enum.Comment != nil {
+			enumInstance.Comments = enum.Comment.Lines
+		}
 
-			for _, item := range enum.Elements {
-				if v, ok := item.(*proto.EnumField); ok {
-					value := &EnumValue{
-						Name:    v.Name,
-						Value:   v.Integer,
-						Message: GetComment(v.Comment, "@msg", v.Name),
-					}
-					if v.Comment != nil {
-						value.Comments = v.Comment.Lines
-					}
-					enumInstance.Values = append(enumInstance.Values, value)
+		for _, item := range enum.Elements {
+			if v, ok := item.(*proto.EnumField); ok {
+				annotations, err := g.annotations.Parse(v.Comment, TargetEnumValue, fmt.Sprintf("enum %s.%s", enum.Name, v.Name))
+				diagnostics.Add(err)
+				value := &EnumValue{
+					Position:    v.Position,
+					Annotations: annotations,
+					Name:        v.Name,
+					Value:       v.Integer,
+					Message:     annotations.Value("msg", v.Name),
+				}
+				if v.Comment != nil {
+					value.Comments = v.Comment.Lines
 				}
+				enumInstance.Values = append(enumInstance.Values, value)
 			}
-			list = append(list, &enumInstance)
 		}
+		list = append(list, &enumInstance)
 	}
 
-	return list
+	return list, diagnostics.Err()
 }
 
-func GenEnums(baseOutputDir string, tmpl *template.Template, enums []*Enum) []string {
-	var files []string
+func (g *Generator) GenEnums(baseOutputDir string, enums []*Enum) ([]*File, error) {
+	var diagnostics Diagnostics
+	var files []*File
 	for _, enum := range enums {
 		outputPath := filepath.Join(baseOutputDir, enum.FilePath)
 
-		// 创建目录
-		err := os.MkdirAll(filepath.Dir(outputPath), os.ModePerm)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		// 创建输出文件
-		outFile, err := os.Create(outputPath)
-		if err != nil {
-			log.Fatal(err)
-		}
-
 		// 执行模板，传入 moduleName 和 outputPackageName
-		err = tmpl.ExecuteTemplate(outFile, "enum", map[string]any{
+		file, err := renderFile(g.tmpl, "enum", outputPath, map[string]any{
 			"Package": enum.Package,
 			"Name":    enum.Name,
 			"Values":  enum.Values,
 		})
 		if err != nil {
-			log.Fatal(err)
+			diagnostics.Add(NewDiagnostic(enum.Position, "enum "+enum.Name, err))
+			continue
 		}
-		outFile.Close()
-		files = append(files, outputPath)
-		fmt.Printf("生成枚举文件：%s\n", outputPath)
+		files = append(files, file)
 	}
-	return files
+	return files, diagnostics.Err()
 }
 
-func SDKEnums(baseOutputDir string, tmpl *template.Template, enums []*Enum) []string {
-	var files []string
+func (g *Generator) SDKEnums(baseOutputDir string, enums []*Enum) ([]*File, error) {
+	var diagnostics Diagnostics
+	var files []*File
 	for _, enum := range enums {
 		outputPath := filepath.Join(baseOutputDir, strings.ReplaceAll(enum.FilePath, ".go", ".ts"))
 
-		// 创建目录
-		err := os.MkdirAll(filepath.Dir(outputPath), os.ModePerm)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		// 创建输出文件
-		outFile, err := os.Create(outputPath)
-		if err != nil {
-			log.Fatal(err)
-		}
-
 		// 执行模板，传入 moduleName 和 outputPackageName
-		err = tmpl.ExecuteTemplate(outFile, "enum", map[string]any{
+		file, err := renderFile(g.tmpl, "enum", outputPath, map[string]any{
 			"Package": enum.Package,
 			"Name":    enum.Name,
 			"Values":  enum.Values,
 		})
 		if err != nil {
-			log.Fatal(err)
+			diagnostics.Add(NewDiagnostic(enum.Position, "enum "+enum.Name, err))
+			continue
 		}
-		outFile.Close()
-		files = append(files, outputPath)
-		fmt.Printf("生成枚举文件：%s\n", outputPath)
+		files = append(files, file)
 	}
-	return files
+	return files, diagnostics.Err()
 }
@@ -5,6 +5,7 @@ import (
 	"github.com/emicklei/proto"
 	"github.com/goal-web/supports/utils"
 	"path/filepath"
+	"slices"
 	"strings"
 )
 
@@ -16,19 +17,28 @@ type Proto struct {
 	References []*Proto
 }
 
-func ExtractModel(msg *proto.Message, basePackage, dir string) *Message {
+// relationAnnotations 声明模型关联关系的注解
+var relationAnnotations = []string{"belongsTo", "hasOne", "hasMany", "hasOneThrough", "hasManyThrough", "belongsToMany", "belongsToThrough"}
+
+func (g *Generator) ExtractModel(msg *proto.Message, basePackage, dir string) (*Message, error) {
 	// 处理模型和请求实体
+	var diagnostics Diagnostics
 	var fields []*Field
 	var relations []*Field
 	var primaryKey string
+	annotations, err := g.annotations.Parse(msg.Comment, TargetMessage, "message "+msg.Name)
+	diagnostics.Add(err)
 	var message = &Message{
 		IsModel:         true,
+		Position:        msg.Position,
 		Comment:         msg.Comment,
-		TableName:       ConvertCamelToSnake(replaceSuffix(msg.Name, "Model")),
+		Annotations:     annotations,
+		TableName:       annotations.Value("table", ConvertCamelToSnake(replaceSuffix(msg.Name, "Model"))),
+		SoftDelete:      annotations.Arg("softDelete", 0, utils.IfString(annotations.Has("softDelete"), "deleted_at", "")),
 		RawName:         replaceSuffix(msg.Name, "Model"),
 		Name:            msg.Name,
 		Relations:       relations,
-		Authenticatable: HasComment(msg.Comment, "@authenticatable"),
+		Authenticatable: annotations.Has("authenticatable"),
 	}
 	for _, element := range msg.Elements {
 		if field, ok := element.(*proto.NormalField); ok {
@@ -36,44 +46,51 @@ func ExtractModel(msg *proto.Message, basePackage, dir string) *Message {
 				primaryKey = field.Name
 			}
 
+			fieldAnnotations, err := g.annotations.Parse(field.Comment, TargetField, fmt.Sprintf("field %s.%s", msg.Name, field.Name))
+			diagnostics.Add(err)
 			var fieldItem = &Field{
-				Index:     len(fields),
-				Parent:    message,
-				Repeated:  field.Repeated,
-				Comment:   field.Comment,
-				Name:      ToCamelCase(field.Name),
-				Type:      field.Type,
-				JSONName:  field.Name,
-				UsageName: field.Type,
-				GoType:    GetComment(field.Comment, "@goType", ""),
+				Position:    field.Position,
+				Index:       len(fields),
+				Parent:      message,
+				Repeated:    field.Repeated,
+				Comment:     field.Comment,
+				Annotations: fieldAnnotations,
+				Name:        ToCamelCase(field.Name),
+				Type:        field.Type,
+				JSONName:    field.Name,
+				UsageName:   field.Type,
+				GoType:      fieldAnnotations.Value("goType", ""),
+				WellKnown:   LookupWellKnownType(field.Type),
+				Ptr:         fieldAnnotations.Has("ptr"),
 			}
+			if fieldAnnotations.Has("pk") {
+				primaryKey = field.Name
+			}
+			fieldItem.Comments = commentTexts(field.Comment, "pk", "ptr")
 
-			if field.Comment != nil {
-				var commentTexts []string
-				for _, line := range field.Comment.Lines {
-					if strings.HasPrefix(line, "@pk") {
-						primaryKey = field.Name
-					} else if strings.HasPrefix(line, "@ptr") {
-						fieldItem.Ptr = true
-					} else {
-						commentTexts = append(commentTexts, "//"+line)
-					}
-				}
-
-				if len(commentTexts) > 0 {
-					fieldItem.Comments = strings.Join(commentTexts, "\n")
-				}
+			isRelation := false
+			for _, name := range relationAnnotations {
+				isRelation = isRelation || fieldAnnotations.Has(name)
 			}
-			if (HasComment(field.Comment, "@belongsTo") ||
-				HasComment(field.Comment, "@hasOne") ||
-				HasComment(field.Comment, "@hasOneThrough") ||
-				HasComment(field.Comment, "@belongsToThrough") ||
-				HasComment(field.Comment, "@hasMany")) && strings.HasSuffix(field.Type, "Model") {
+			if isRelation && strings.HasSuffix(field.Type, "Model") {
 				fieldItem.IsModel = true
 				relations = append(relations, fieldItem)
 			} else {
 				fields = append(fields, fieldItem)
 			}
+		} else if oneof, ok := element.(*proto.Oneof); ok {
+			oneofField, item, err := g.extractOneof(msg.Name, oneof)
+			diagnostics.Add(err)
+			oneofField.Index = len(fields)
+			oneofField.Parent = message
+			fields = append(fields, oneofField)
+			message.Oneofs = append(message.Oneofs, item)
+		} else if mapField, ok := element.(*proto.MapField); ok {
+			fieldItem, err := g.extractMapField(msg.Name, mapField)
+			diagnostics.Add(err)
+			fieldItem.Index = len(fields)
+			fieldItem.Parent = message
+			fields = append(fields, fieldItem)
 		}
 	}
 	var midDir = "models"
@@ -89,33 +106,64 @@ func ExtractModel(msg *proto.Message, basePackage, dir string) *Message {
 	message.Relations = relations
 	message.FilePath = strings.Join(trim(midDir, replaceSuffix(msg.Name, "Model")+"_gen.go"), "/")
 
+	diagnostics.Add(g.extractPrimaryKey(message))
+
+	if message.SoftDelete != "" {
+		if err := g.checkSoftDelete(message); err != nil {
+			diagnostics.Add(err)
+			message.SoftDelete = ""
+		}
+	}
+
 	if msg.Comment != nil {
 		for _, line := range msg.Comment.Lines {
-			if strings.HasPrefix(line, "@table:") {
-				message.TableName = strings.Trim(strings.TrimPrefix(line, "@table:"), " ")
-			} else if strings.HasPrefix(line, "@softDelete") {
-				message.SoftDelete = strings.Trim(strings.TrimPrefix(line, "@softDelete"), ": ")
-			} else {
+			if annotation, ok := parseAnnotationLine(line); !ok || (annotation.Name != "table" && annotation.Name != "softDelete") {
 				message.Comments = append(message.Comments, "//"+line)
 			}
 		}
 	}
-	usagePackageMap[msg.Name] = message
-	return message
+	g.registry.Register(msg.Name, message)
+	return message, diagnostics.Err()
+}
+
+// checkSoftDelete 软删除依赖模型中声明的删除时间字段，未删除时为 NULL，所以只支持 string 类型
+func (g *Generator) checkSoftDelete(message *Message) error {
+	var diagnostics Diagnostics
+	annotation := message.Annotations.Get("softDelete")
+	subject := "message " + message.Name
+	field := message.SoftDeleteField()
+	if field == nil {
+		diagnostics.Errorf(annotation.Position, subject, "@softDelete 需要在模型中声明字段 string %s", message.SoftDelete)
+	} else if field.Type != "string" || field.Repeated {
+		diagnostics.Errorf(field.Position, subject, "@softDelete 的字段 %s 必须是 string 类型", message.SoftDelete)
+	}
+	return diagnostics.Err()
 }
 
-// 避免死循环解析
-var parsedProtoMap = make(map[string]*Proto)
+// commentTexts 字段的注释，去掉指定的注解后作为 go 注释输出
+func commentTexts(comment *proto.Comment, excludes ...string) string {
+	if comment == nil {
+		return ""
+	}
+	var texts []string
+	for _, line := range comment.Lines {
+		if annotation, ok := parseAnnotationLine(line); ok && slices.Contains(excludes, annotation.Name) {
+			continue
+		}
+		texts = append(texts, "//"+line)
+	}
+	return strings.Join(texts, "\n")
+}
 
-func ExtractProto(pwd string, def *proto.Proto, basePackage string, dir string, skipGoPackage ...bool) *Proto {
+// ExtractProto 提取 proto 文件中的消息、服务和枚举，消息类型会注册到生成器的注册表中
+func (g *Generator) ExtractProto(def *proto.Proto, basePackage string, dir string, skipGoPackage ...bool) (*Proto, error) {
+	var diagnostics Diagnostics
 	var flat = utils.DefaultValue(skipGoPackage, false)
 	var models []*Message
 	var dataList []*Message
 	var requests []*Message
 	var results []*Message
 	var references []*Proto
-	var data *Proto
-	parsedProtoMap[def.Filename] = data
 
 	for _, element := range def.Elements {
 		switch v := element.(type) {
@@ -125,119 +173,162 @@ func ExtractProto(pwd string, def *proto.Proto, basePackage string, dir string,
 				fmt.Printf("读取到包名：%s\n", dir)
 			}
 		case *proto.Import:
-			protoFile := filepath.Join(pwd, v.Filename)
-			if subPro, exists := parsedProtoMap[protoFile]; exists {
-				references = append(references, subPro)
-			} else {
-				subProf := ParseProto(protoFile)
-				subPro = ExtractProto(pwd, subProf, basePackage, dir)
-				parsedProtoMap[def.Filename] = subPro
+			// 被引用的文件已经按照拓扑顺序提取过了，无法解析的 import 在构建引用图时报告
+			importFile, builtin, err := g.resolveImport(v.Filename)
+			if err != nil || builtin {
+				continue
+			}
+			if subPro := g.loadExtracted(importFile); subPro != nil {
 				references = append(references, subPro)
 			}
 		}
 	}
 
-	// 遍历 proto 文件的元素
-	for _, elem := range def.Elements {
-		if e, ok := elem.(*proto.Message); ok {
+	// 展开嵌套的消息和枚举，嵌套类型使用拼接后的名称
+	messages, enums := flattenMessages(def), flattenEnums(def)
+	diagnostics.Add(g.registerNestedNames(messages, enums))
+	pkg := packageName(def)
 
-			var midDir, tmlp string
-			if strings.HasSuffix(e.Name, "Model") {
-				models = append(models, ExtractModel(e, basePackage, dir))
-				continue
-			} else if strings.HasSuffix(e.Name, "Req") || strings.HasSuffix(e.Name, "Request") {
-				midDir = "requests"
-				tmlp = "request"
-			} else if strings.HasSuffix(e.Name, "Result") {
-				midDir = "results"
-				tmlp = "result"
-			} else {
-				midDir = "models"
-				tmlp = "data"
-			}
+	// 遍历 proto 文件中的消息
+	for _, scoped := range messages {
+		e := scoped.Message
 
-			// 处理模型和请求实体
-			var fields []*Field
-			var primaryKey string
-			for _, element := range e.Elements {
-				if field, ok := element.(*proto.NormalField); ok {
-					if primaryKey == "" {
-						primaryKey = field.Name
-					}
+		var midDir, tmlp string
+		if strings.HasSuffix(e.Name, "Model") {
+			model, err := g.ExtractModel(e, basePackage, dir)
+			diagnostics.Add(err)
+			g.resolveFieldTypes(model, scoped.path(), pkg)
+			for _, oneof := range model.Oneofs {
+				warning := NewDiagnostic(oneof.Position, fmt.Sprintf("oneof %s.%s", e.Name, oneof.JSONName), fmt.Errorf("模型中的 oneof 不会保存到数据库"))
+				warning.Severity = SeverityWarning
+				diagnostics.Add(warning)
+			}
+			models = append(models, model)
+			continue
+		} else if strings.HasSuffix(e.Name, "Req") || strings.HasSuffix(e.Name, "Request") {
+			midDir = "requests"
+			tmlp = "request"
+		} else if strings.HasSuffix(e.Name, "Result") {
+			midDir = "results"
+			tmlp = "result"
+		} else {
+			midDir = "models"
+			tmlp = "data"
+		}
 
-					var fieldItem = &Field{
-						Repeated:  field.Repeated,
-						Comment:   field.Comment,
-						Name:      ToCamelCase(field.Name),
-						Type:      field.Type,
-						JSONName:  field.Name,
-						UsageName: field.Type,
-						GoType:    GetComment(field.Comment, "@goType", ""),
-					}
-					if field.Comment != nil {
-						var commentTexts []string
-						for _, line := range field.Comment.Lines {
-							if strings.HasPrefix(line, "@gotag:") {
-								fieldItem.Tags = " " + strings.TrimPrefix(line, "@gotag:")
-							} else if strings.HasPrefix(line, "@ptr") {
-								fieldItem.Ptr = true
-							} else {
-								commentTexts = append(commentTexts, "//"+line)
-							}
-						}
+		// 处理模型和请求实体
+		var fields []*Field
+		var oneofs []*Oneof
+		var primaryKey string
+		for _, element := range e.Elements {
+			if oneof, ok := element.(*proto.Oneof); ok {
+				oneofField, item, err := g.extractOneof(e.Name, oneof)
+				diagnostics.Add(err)
+				fields = append(fields, oneofField)
+				oneofs = append(oneofs, item)
+				continue
+			}
+			if mapField, ok := element.(*proto.MapField); ok {
+				fieldItem, err := g.extractMapField(e.Name, mapField)
+				diagnostics.Add(err)
+				fields = append(fields, fieldItem)
+				continue
+			}
+			if field, ok := element.(*proto.NormalField); ok {
+				if primaryKey == "" {
+					primaryKey = field.Name
+				}
 
-						if len(commentTexts) > 0 {
-							fieldItem.Comments = strings.Join(commentTexts, "\n")
-						}
-					}
-					fields = append(fields, fieldItem)
+				fieldAnnotations, err := g.annotations.Parse(field.Comment, TargetField, fmt.Sprintf("field %s.%s", e.Name, field.Name))
+				diagnostics.Add(err)
+				var fieldItem = &Field{
+					Position:    field.Position,
+					Repeated:    field.Repeated,
+					Comment:     field.Comment,
+					Annotations: fieldAnnotations,
+					Name:        ToCamelCase(field.Name),
+					Type:        field.Type,
+					JSONName:    field.Name,
+					UsageName:   field.Type,
+					GoType:      fieldAnnotations.Value("goType", ""),
+					WellKnown:   LookupWellKnownType(field.Type),
+					Ptr:         fieldAnnotations.Has("ptr"),
+					Comments:    commentTexts(field.Comment, "gotag", "ptr"),
+				}
+				if fieldAnnotations.Has("gotag") {
+					fieldItem.Tags = " " + fieldAnnotations.Value("gotag", "")
 				}
+				fields = append(fields, fieldItem)
 			}
+		}
 
-			importPath := strings.Join(trim(basePackage, midDir, dir), "/")
-			usageName := fmt.Sprintf("%s.%s", filepath.Base(importPath), e.Name)
-
-			msg := Message{
-				Comment:    e.Comment,
-				Template:   tmlp,
-				PrimaryKey: primaryKey,
-				TableName:  ConvertCamelToSnake(replaceSuffix(e.Name, "Request", "Req")),
-				RawName:    replaceSuffix(e.Name, "Request", "Req"),
-				Name:       e.Name,
-				Fields:     fields,
-				ImportPath: importPath,
-				UsageName:  usageName,
-				FilePath:   strings.Join(trim(midDir, dir, replaceSuffix(e.Name, "Model", "Request", "Req")+"_gen.go"), "/"),
-			}
+		annotations, err := g.annotations.Parse(e.Comment, TargetMessage, "message "+e.Name)
+		diagnostics.Add(err)
 
-			usagePackageMap[e.Name] = &msg
+		importPath := strings.Join(trim(basePackage, midDir, dir), "/")
+		usageName := fmt.Sprintf("%s.%s", filepath.Base(importPath), e.Name)
 
-			if strings.HasSuffix(e.Name, "Req") || strings.HasSuffix(e.Name, "Request") {
-				requests = append(requests, &msg)
-			} else if strings.HasSuffix(e.Name, "Result") {
-				results = append(results, &msg)
-			} else {
-				dataList = append(dataList, &msg)
-			}
+		msg := Message{
+			Position:    e.Position,
+			Comment:     e.Comment,
+			Annotations: annotations,
+			Template:    tmlp,
+			PrimaryKey:  primaryKey,
+			TableName:   ConvertCamelToSnake(replaceSuffix(e.Name, "Request", "Req")),
+			RawName:     replaceSuffix(e.Name, "Request", "Req"),
+			Name:        e.Name,
+			Fields:      fields,
+			Oneofs:      oneofs,
+			ImportPath:  importPath,
+			UsageName:   usageName,
+			FilePath:    strings.Join(trim(midDir, dir, replaceSuffix(e.Name, "Model", "Request", "Req")+"_gen.go"), "/"),
+		}
+
+		g.resolveFieldTypes(&msg, scoped.path(), pkg)
+		g.registry.Register(e.Name, &msg)
+
+		if strings.HasSuffix(e.Name, "Req") || strings.HasSuffix(e.Name, "Request") {
+			requests = append(requests, &msg)
+		} else if strings.HasSuffix(e.Name, "Result") {
+			results = append(results, &msg)
+		} else {
+			dataList = append(dataList, &msg)
 		}
 	}
 
+	services, err := g.ExtractServices(def, pkg, basePackage, dir)
+	diagnostics.Add(err)
+
+	enumList, err := g.ExtractEnums(enums, basePackage, dir)
+	diagnostics.Add(err)
+	for _, enum := range enumList {
+		g.registry.RegisterEnum(enum.Name, enum)
+	}
+
+	// 校验规则需要知道字段引用的枚举，所以在枚举注册之后处理
+	for _, message := range slices.Concat(models, dataList, requests, results) {
+		diagnostics.Add(g.extractValidationRules(message))
+		diagnostics.Add(g.extractVersion(message))
+		diagnostics.Add(g.extractCasts(message))
+	}
+
 	// 返回提取的数据
-	data = &Proto{
+	data := &Proto{
 		Messages: map[string][]*Message{
 			"models":   models,
 			"dataList": dataList,
 			"requests": requests,
 			"results":  results,
 		},
-		Services:   ExtractServices(def, basePackage, dir),
-		Enums:      ExtractEnums(def, basePackage, dir),
+		Services:   services,
+		Enums:      enumList,
 		References: references,
 	}
-	return data
+	return data, diagnostics.Err()
 }
 
-func ExtractServices(def *proto.Proto, basePackage string, dir string) map[string]*ExtractServiceTemp {
+func (g *Generator) ExtractServices(def *proto.Proto, pkg, basePackage, dir string) (map[string]*ExtractServiceTemp, error) {
+	var diagnostics Diagnostics
 	var services = map[string]*ExtractServiceTemp{
 		"services": {
 			Suffix:   "Service",
@@ -255,20 +346,42 @@ func ExtractServices(def *proto.Proto, basePackage string, dir string) map[strin
 			for path, temp := range services {
 				if strings.HasSuffix(e.Name, temp.Suffix) {
 					var methods []*Method
+					serviceAnnotations, err := g.annotations.Parse(e.Comment, TargetService, "service "+e.Name)
+					diagnostics.Add(err)
 					for _, se := range e.Elements {
 						if rpc, ok := se.(*proto.RPC); ok {
+							rpcAnnotations, err := g.annotations.Parse(rpc.Comment, TargetRPC, fmt.Sprintf("rpc %s.%s", e.Name, rpc.Name))
+							diagnostics.Add(err)
+							input := g.registry.Lookup(g.resolveType(rpc.RequestType, nil, pkg))
+							output := g.registry.Lookup(g.resolveType(rpc.ReturnsType, nil, pkg))
+							if input == nil {
+								diagnostics.Errorf(rpc.Position, fmt.Sprintf("rpc %s.%s", e.Name, rpc.Name), "未知的请求类型 %s", rpc.RequestType)
+							}
+							if output == nil {
+								diagnostics.Errorf(rpc.Position, fmt.Sprintf("rpc %s.%s", e.Name, rpc.Name), "未知的返回类型 %s", rpc.ReturnsType)
+							}
+							if input == nil || output == nil {
+								continue
+							}
 
+							httpMethods := rpcAnnotations.Values("method")
+							if len(httpMethods) == 0 {
+								httpMethods = []string{"Post"}
+							}
 							method := &Method{
+								Position:            rpc.Position,
 								Comment:             rpc.Comment,
+								Annotations:         rpcAnnotations,
 								Name:                rpc.Name,
-								InputUsageName:      usagePackageMap[rpc.RequestType].UsageName,
-								InputImportPackage:  usagePackageMap[rpc.RequestType].ImportPath,
-								OutputUsageName:     usagePackageMap[rpc.ReturnsType].UsageName,
-								OutputImportPackage: usagePackageMap[rpc.ReturnsType].ImportPath,
-
-								Method:      strings.Split(GetComment(rpc.Comment, "@method", "Post"), ","),
-								Path:        GetComment(rpc.Comment, "@path", fmt.Sprintf("/%s", rpc.Name)),
-								Middlewares: getComments(rpc.Comment, "@middleware", ""),
+								Input:               input,
+								InputUsageName:      input.UsageName,
+								InputImportPackage:  input.ImportPath,
+								OutputUsageName:     output.UsageName,
+								OutputImportPackage: output.ImportPath,
+
+								Method:      httpMethods,
+								Path:        rpcAnnotations.Value("path", fmt.Sprintf("/%s", rpc.Name)),
+								Middlewares: rpcAnnotations.Values("middleware"),
 							}
 
 							methods = append(methods, method)
@@ -279,10 +392,12 @@ func ExtractServices(def *proto.Proto, basePackage string, dir string) map[strin
 					usageName := fmt.Sprintf("%s.%s", filepath.Base(importPath), e.Name)
 
 					temp.List = append(temp.List, &Service{
+						Position:    e.Position,
 						Comment:     e.Comment,
-						Middlewares: getComments(e.Comment, "@middleware", ""),
-						Controller:  HasComment(e.Comment, "@controller"),
-						Prefix:      GetComment(e.Comment, "@controller", ""),
+						Annotations: serviceAnnotations,
+						Middlewares: serviceAnnotations.Values("middleware"),
+						Controller:  serviceAnnotations.Has("controller"),
+						Prefix:      serviceAnnotations.Value("controller", ""),
 
 						Name:        e.Name,
 						Methods:     methods,
@@ -297,5 +412,5 @@ func ExtractServices(def *proto.Proto, basePackage string, dir string) map[strin
 
 		}
 	}
-	return services
+	return services, diagnostics.Err()
 }
@@ -0,0 +1,129 @@
+package gen
+
+import (
+	"fmt"
+	"strings"
+)
+
+// factoryBaseTime 工厂生成时间的起点，每条数据往后推一个小时，保证相同序号的数据总是相同，time 包由 google.protobuf 的时间类型引入
+const factoryBaseTime = "time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(index) * time.Hour)"
+
+// FactoryRelation 模型工厂可以通过 With 自动创建的关联关系
+type FactoryRelation struct {
+	Model      string // 关联的模型，例如 PostModel
+	BelongsTo  bool   // 为 true 时先创建关联的模型，再把它的 RelatedKey 写入当前模型的 LocalKey
+	LocalKey   string // 当前模型的字段
+	RelatedKey string // 关联模型的字段
+}
+
+// FactoryRelations 模型中可以由工厂自动创建的关联关系，同一个模型有多个关联时只使用第一个
+func (g *Generator) FactoryRelations(message *Message) []*FactoryRelation {
+	var relations []*FactoryRelation
+	exists := map[string]bool{}
+	ownerKey := strings.ToLower(message.RawName) + "_id"
+	for _, field := range message.Relations {
+		// 去掉指针、切片以及包名，例如 []*models.PostModel => PostModel
+		model := strings.TrimLeft(g.GoType(field), "[]*")
+		model = model[strings.LastIndex(model, ".")+1:]
+		if exists[model] {
+			continue
+		}
+
+		var relation *FactoryRelation
+		switch {
+		case field.Annotations.Has("belongsTo"):
+			relation = &FactoryRelation{
+				BelongsTo:  true,
+				LocalKey:   field.Annotations.Arg("belongsTo", 1, field.JSONName+"_id"),
+				RelatedKey: field.Annotations.Arg("belongsTo", 0, "id"),
+			}
+		case field.Annotations.Has("hasOne"):
+			relation = &FactoryRelation{
+				LocalKey:   field.Annotations.Arg("hasOne", 0, "id"),
+				RelatedKey: field.Annotations.Arg("hasOne", 1, ownerKey),
+			}
+		case field.Annotations.Has("hasMany"):
+			relation = &FactoryRelation{
+				LocalKey:   field.Annotations.Arg("hasMany", 1, "id"),
+				RelatedKey: field.Annotations.Arg("hasMany", 0, ownerKey),
+			}
+		default:
+			// 中间表的关联需要同时创建中间表的数据，交给 seeder 处理
+			continue
+		}
+		relation.Model = model
+		exists[model] = true
+		relations = append(relations, relation)
+	}
+	return relations
+}
+
+// FakeValue 工厂中字段默认值的 go 表达式，index 为数据的序号，返回空字符串时不生成该字段，交给数据库处理
+func (g *Generator) FakeValue(field *Field) string {
+	if message := field.Parent; message != nil {
+		switch {
+		case field.JSONName == message.SoftDelete || field.JSONName == message.Version:
+			return ""
+		case field.IsPrimaryKey() && !message.CompositeKey():
+			// 自增主键由数据库生成，uuid 之类的主键在创建时生成
+			return ""
+		}
+	}
+
+	goType := g.GoType(field)
+	if field.GoType != "" || strings.HasPrefix(goType, "*") || (!g.IsQueryable(field) && field.Cast != "encrypted" && field.Cast != "hashed") {
+		return ""
+	}
+
+	if field.WellKnown != nil {
+		switch field.WellKnown.GoType {
+		case "time.Time":
+			return factoryBaseTime
+		case "time.Duration":
+			return "time.Duration(index+1) * time.Second"
+		}
+		return ""
+	}
+
+	if enum := g.registry.LookupEnum(field.Type); enum != nil {
+		var values []string
+		for _, value := range enum.Values {
+			values = append(values, fmt.Sprint(value.Value))
+		}
+		if len(values) == 0 {
+			return ""
+		}
+		return fmt.Sprintf("[]%s{%s}[index%%%d]", goType, strings.Join(values, ", "), len(values))
+	}
+
+	name := strings.ToLower(field.JSONName)
+	switch field.Type {
+	case "string":
+		switch {
+		case g.baseDBType(field) == "timestamp":
+			// 字符串类型的时间字段不一定引用了 time 包，直接拼接出同样的时间
+			return `fmt.Sprintf("2024-01-%02d %02d:00:00", index/24%28+1, index%24)`
+		case strings.Contains(name, "email"):
+			return fmt.Sprintf(`fmt.Sprintf("%s%%d@example.com", index+1)`, strings.ToLower(field.Parent.RawName))
+		case strings.Contains(name, "phone") || strings.Contains(name, "mobile"):
+			return `fmt.Sprintf("1380000%04d", index+1)`
+		case strings.Contains(name, "url") || strings.Contains(name, "avatar") || strings.Contains(name, "image"):
+			return fmt.Sprintf(`fmt.Sprintf("https://example.com/%s/%%d", index+1)`, field.JSONName)
+		case strings.Contains(name, "password"):
+			return `"password"`
+		case strings.Contains(name, "name"):
+			return fmt.Sprintf(`fmt.Sprintf("%s %%d", index+1)`, field.Parent.RawName)
+		}
+		return fmt.Sprintf(`fmt.Sprintf("%s %%d", index+1)`, field.JSONName)
+	case "bytes":
+		return fmt.Sprintf(`[]byte(fmt.Sprintf("%s %%d", index+1))`, field.JSONName)
+	case "bool":
+		return "index%2 == 0"
+	case "double", "float":
+		return fmt.Sprintf("%s(index) + 0.5", goType)
+	}
+	if numericTypes[field.Type] {
+		return fmt.Sprintf("%s(index + 1)", goType)
+	}
+	return ""
+}
@@ -0,0 +1,270 @@
+package gen
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/emicklei/proto"
+)
+
+const (
+	ModePro = "pro" // 生成 go 代码
+	ModeSDK = "sdk" // 生成 typescript SDK
+)
+
+// Config 生成器的配置
+type Config struct {
+	Template   string   // 模板文件路径
+	OutputDir  string   // 输出的基准目录
+	Mode       string   // 生成模式，pro 或者 sdk
+	ProtoPaths []string // 查找 import 的目录，与 protoc 的 --proto_path 一致，默认为当前目录
+	Workers    int      // 并发处理 proto 文件的 worker 数量，默认为 CPU 核数
+	Force      bool     // 忽略清单，全部重新生成
+	Prune      bool     // 删除不再生成的文件以及失效的路由注册
+	Dialect    string   // db tag 以及迁移语句的目标数据库：mysql、postgres、sqlite、clickhouse，默认为 mysql
+}
+
+// Generator 代码生成器，持有自己的类型注册表、模板和配置，同一个进程中可以同时存在多个生成器
+type Generator struct {
+	config       Config
+	registry     *Registry
+	annotations  *AnnotationRegistry
+	tmpl         *template.Template
+	templateHash string
+	outputDirAbs string
+	basePackage  string
+	pwd          string
+
+	mu        sync.Mutex
+	parsed    map[string]*proto.Proto
+	extracted map[string]*Proto
+}
+
+// NewGenerator 创建生成器，模板只会在这里解析一次
+func NewGenerator(config Config) (*Generator, error) {
+	if config.Mode == "" {
+		config.Mode = ModePro
+	}
+	if config.Workers < 1 {
+		config.Workers = runtime.NumCPU()
+	}
+	if len(config.ProtoPaths) == 0 {
+		config.ProtoPaths = []string{"."}
+	}
+	if config.Dialect = DialectOf(config.Dialect); !slices.Contains(Dialects, config.Dialect) {
+		return nil, fmt.Errorf("不支持的数据库 %s，可选值：%s", config.Dialect, strings.Join(Dialects, "、"))
+	}
+
+	generator := &Generator{
+		config:      config,
+		registry:    NewRegistry(),
+		annotations: NewAnnotationRegistry(),
+		parsed:      make(map[string]*proto.Proto),
+		extracted:   make(map[string]*Proto),
+	}
+
+	var err error
+	// 确保 outputDir 是绝对路径
+	if generator.outputDirAbs, err = filepath.Abs(config.OutputDir); err != nil {
+		return nil, err
+	}
+	if generator.pwd, err = os.Getwd(); err != nil {
+		return nil, fmt.Errorf("无法读取当前目录：%v", err)
+	}
+
+	if generator.sdk() {
+		generator.basePackage = "@"
+	} else {
+		// 读取模块名和模块根目录
+		moduleName, _, err := GetModuleNameAndDir(generator.outputDirAbs)
+		if err != nil {
+			return nil, fmt.Errorf("无法读取模块名：%v", err)
+		}
+		fmt.Printf("读取到的模块名：%s\n", moduleName)
+		generator.basePackage = filepath.Join(moduleName, config.OutputDir)
+	}
+
+	// 初始化模板，并添加函数映射
+	content := templateContent(config.Template)
+	generator.templateHash = hashBytes(content)
+	if config.Dialect != DialectMySQL {
+		// 切换数据库之后 db tag 会变化，需要重新生成全部模型
+		generator.templateHash = hashBytes(slices.Concat(content, []byte(config.Dialect)))
+	}
+	if generator.tmpl, err = generator.parseTemplate(config.Template, content); err != nil {
+		return nil, err
+	}
+
+	return generator, nil
+}
+
+// RegisterAnnotation 注册自定义注解，自定义模板可以通过 .Annotations 读取解析后的参数
+func (g *Generator) RegisterAnnotation(spec *AnnotationSpec) {
+	g.annotations.Register(spec)
+}
+
+func (g *Generator) sdk() bool {
+	return g.config.Mode != ModePro
+}
+
+// parse 解析 proto 文件，同一个文件只会解析一次
+func (g *Generator) parse(protoFile string) (*proto.Proto, error) {
+	key, err := filepath.Abs(protoFile)
+	if err != nil {
+		key = protoFile
+	}
+
+	g.mu.Lock()
+	definition, exists := g.parsed[key]
+	g.mu.Unlock()
+	if exists {
+		return definition, nil
+	}
+
+	definition, err = ParseProto(protoFile)
+	if err != nil {
+		return nil, err
+	}
+
+	g.mu.Lock()
+	g.parsed[key] = definition
+	g.mu.Unlock()
+	return definition, nil
+}
+
+func (g *Generator) loadExtracted(file string) *Proto {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.extracted[file]
+}
+
+func (g *Generator) storeExtracted(file string, data *Proto) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.extracted[file] = data
+}
+
+// resolveImport 按照 protoc 的规则依次在 include 路径中查找被引用的文件，先找到的优先，
+// google/protobuf 的内置文件总是使用内置的定义
+func (g *Generator) resolveImport(filename string) (string, bool, error) {
+	if IsWellKnownFile(filename) {
+		return "", true, nil
+	}
+	for _, dir := range g.config.ProtoPaths {
+		path := filepath.Join(dir, filename)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			// 转换成相对当前目录的路径，与扫描到的 proto 文件保持一致
+			if abs, err := filepath.Abs(path); err == nil {
+				path = relativePath(g.pwd, abs)
+			}
+			return path, false, nil
+		}
+	}
+	return "", false, fmt.Errorf("在 include 路径 %s 中找不到被引用的文件", strings.Join(g.config.ProtoPaths, ", "))
+}
+
+// parseAll 并发解析全部 proto 文件
+func (g *Generator) parseAll(protoFiles []string) error {
+	errs := make([]error, len(protoFiles))
+	parallel(g.config.Workers, len(protoFiles), func(i int) {
+		_, errs[i] = g.parse(protoFiles[i])
+	})
+
+	var diagnostics Diagnostics
+	for _, err := range errs {
+		diagnostics.Add(err)
+	}
+	return diagnostics.Err()
+}
+
+// defaultDir 没有 go_package 时使用的子目录
+func (g *Generator) defaultDir(protoFile string) string {
+	if g.sdk() {
+		return ""
+	}
+	return filepath.Dir(protoFile)
+}
+
+// Registry 类型注册表，记录消息类型到包的映射
+type Registry struct {
+	mu       sync.RWMutex
+	messages map[string]*Message
+	enums    map[string]*Enum
+	aliases  map[string]string // 嵌套类型的限定名到展开后名称的映射
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		messages: make(map[string]*Message),
+		enums:    make(map[string]*Enum),
+		aliases:  make(map[string]string),
+	}
+}
+
+// Register 注册消息类型
+func (registry *Registry) Register(name string, message *Message) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	registry.messages[name] = message
+}
+
+// Lookup 查找消息类型，不存在时返回 nil
+func (registry *Registry) Lookup(name string) *Message {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	return registry.messages[name]
+}
+
+// RegisterEnum 注册枚举类型
+func (registry *Registry) RegisterEnum(name string, enum *Enum) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	registry.enums[name] = enum
+}
+
+// LookupEnum 查找枚举类型，不存在时返回 nil
+func (registry *Registry) LookupEnum(name string) *Enum {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	return registry.enums[name]
+}
+
+// Alias 注册嵌套类型，例如 Order.Item => OrderItem
+func (registry *Registry) Alias(qualified, name string) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	registry.aliases[qualified] = name
+}
+
+// ResolveAlias 查找嵌套类型展开后的名称，不存在时返回空字符串
+func (registry *Registry) ResolveAlias(qualified string) string {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	return registry.aliases[qualified]
+}
+
+// parallel 使用固定数量的 worker 并发执行 count 个任务
+func parallel(workers, count int, handler func(i int)) {
+	var wg sync.WaitGroup
+	jobs := make(chan int)
+	for w := 0; w < min(workers, count); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				handler(i)
+			}
+		}()
+	}
+	for i := 0; i < count; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
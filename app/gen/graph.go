@@ -0,0 +1,152 @@
+package gen
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/emicklei/proto"
+)
+
+// ImportGraph proto 文件之间的引用关系，key 是相对当前目录的路径
+type ImportGraph struct {
+	Order []string // 拓扑顺序，被引用的文件总是在引用它的文件之前
+	Nodes map[string]*ImportNode
+}
+
+// ImportNode 引用图中的一个 proto 文件
+type ImportNode struct {
+	Definition *proto.Proto
+	Imports    []string // 直接引用的文件，不包含 google/protobuf 内置文件
+	Errors     Diagnostics
+	Broken     bool // 解析失败或者处于循环引用中，无法提取
+}
+
+// BuildImportGraph 从给定的 proto 文件出发构建引用图，每个文件只会解析一次，循环引用会报告完整的路径
+func (g *Generator) BuildImportGraph(protoFiles []string) *ImportGraph {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	graph := &ImportGraph{Nodes: make(map[string]*ImportNode)}
+	state := make(map[string]int)
+	var stack []string
+
+	var visit func(file string)
+	visit = func(file string) {
+		node := &ImportNode{}
+		graph.Nodes[file] = node
+		state[file] = visiting
+		stack = append(stack, file)
+
+		definition, err := g.parse(file)
+		if err != nil {
+			node.Errors.Add(err)
+			node.Broken = true
+		} else {
+			node.Definition = definition
+			for _, element := range definition.Elements {
+				v, ok := element.(*proto.Import)
+				if !ok {
+					continue
+				}
+				importFile, builtin, err := g.resolveImport(v.Filename)
+				if err != nil {
+					node.Errors.Add(NewDiagnostic(v.Position, "import "+v.Filename, err))
+					continue
+				} else if builtin {
+					continue
+				}
+				node.Imports = append(node.Imports, importFile)
+
+				switch state[importFile] {
+				case unvisited:
+					visit(importFile)
+				case visiting:
+					// 栈中从被引用的文件到当前文件就是完整的循环路径
+					cycle := stack[indexOf(stack, importFile):]
+					diagnostic := NewDiagnostic(v.Position, "import "+v.Filename,
+						fmt.Errorf("循环引用：%s", strings.Join(append(cycle, importFile), " -> ")))
+					for _, member := range cycle {
+						graph.Nodes[member].Errors.Add(diagnostic)
+						graph.Nodes[member].Broken = true
+					}
+				}
+			}
+		}
+
+		stack = stack[:len(stack)-1]
+		state[file] = visited
+		graph.Order = append(graph.Order, file)
+	}
+
+	for _, protoFile := range protoFiles {
+		if file := g.sourceKey(protoFile); state[file] == unvisited {
+			visit(file)
+		}
+	}
+	return graph
+}
+
+// Errors 文件自身以及它传递引用的全部文件的错误
+func (graph *ImportGraph) Errors(file string) error {
+	var diagnostics Diagnostics
+	seen := make(map[string]bool)
+	var collect func(file string)
+	collect = func(file string) {
+		node := graph.Nodes[file]
+		if seen[file] || node == nil {
+			return
+		}
+		seen[file] = true
+		diagnostics.Add(node.Errors.Err())
+		for _, importFile := range node.Imports {
+			collect(importFile)
+		}
+	}
+	collect(file)
+	return diagnostics.Err()
+}
+
+// sourceKey 引用图中使用的 key，统一为相对当前目录的路径
+func (g *Generator) sourceKey(protoFile string) string {
+	if abs, err := filepath.Abs(protoFile); err == nil {
+		return relativePath(g.pwd, abs)
+	}
+	return filepath.ToSlash(filepath.Clean(protoFile))
+}
+
+// extractAll 按照拓扑顺序提取全部 proto 文件以及它们引用的文件，每个文件只会提取一次，
+// 返回的结果与 protoFiles 一一对应
+func (g *Generator) extractAll(protoFiles []string) ([]*Proto, []error) {
+	graph := g.BuildImportGraph(protoFiles)
+	for _, file := range graph.Order {
+		node := graph.Nodes[file]
+		if node.Broken {
+			continue
+		}
+		data, err := g.ExtractProto(node.Definition, g.basePackage, g.defaultDir(file), g.sdk())
+		node.Errors.Add(err)
+		g.storeExtracted(file, data)
+	}
+
+	dataList := make([]*Proto, len(protoFiles))
+	errs := make([]error, len(protoFiles))
+	for i, protoFile := range protoFiles {
+		file := g.sourceKey(protoFile)
+		dataList[i] = g.loadExtracted(file)
+		errs[i] = graph.Errors(file)
+	}
+	return dataList, errs
+}
+
+func indexOf(list []string, value string) int {
+	for i, item := range list {
+		if item == value {
+			return i
+		}
+	}
+	return -1
+}
@@ -11,21 +11,38 @@ type Import struct {
 	Alias string
 }
 
-func DetermineMessageImports(message *Message) []Import {
+func (g *Generator) DetermineMessageImports(message *Message) []Import {
 	importsSet := make(map[string]string)
 	usageMap := map[string]string{}
-	fields := append(message.Fields, message.Relations...)
+	fields := messageFields(message)
 	var base string
 
 	for i, field := range fields {
 		if message.IsModel && field.IsModel {
 			continue
 		}
+		if field.WellKnown != nil {
+			if pkg := field.WellKnown.GoImport; pkg != "" && field.GoType == "" {
+				importsSet[filepath.Base(pkg)] = pkg
+			}
+			continue
+		}
+		if enum := g.registry.LookupEnum(field.Type); enum != nil {
+			if enum.ImportPath != message.ImportPath {
+				base = filepath.Base(enum.ImportPath)
+				if path, exists := importsSet[base]; exists && path != enum.ImportPath {
+					base = fmt.Sprintf("%s%d", base, i)
+				}
+				importsSet[base] = enum.ImportPath
+				field.UsageName = fmt.Sprintf("%s.%s", base, field.Type)
+			}
+			continue
+		}
 		if alias, exists := usageMap[field.ImportPath]; exists {
 			field.UsageName = strings.ReplaceAll(field.UsageName, filepath.Base(field.ImportPath), alias)
 		} else {
-			if msg, exists := usagePackageMap[field.Type]; exists && msg.ImportPath != message.ImportPath {
-				if HasComment(msg.Comment, "@goType") {
+			if msg := g.registry.Lookup(field.Type); msg != nil && msg.ImportPath != message.ImportPath {
+				if msg.Annotations.Has("goType") {
 					continue
 				}
 
@@ -51,10 +68,10 @@ func DetermineMessageImports(message *Message) []Import {
 	return imports
 }
 
-func DetermineTsMessageImports(message *Message) []Import {
+func (g *Generator) DetermineTsMessageImports(message *Message) []Import {
 	importsSet := make(map[string]string)
 	usageMap := map[string]string{}
-	fields := append(message.Fields, message.Relations...)
+	fields := messageFields(message)
 	var base string
 
 	for i, field := range fields {
@@ -62,15 +79,19 @@ func DetermineTsMessageImports(message *Message) []Import {
 			continue
 		}
 
-		if msg, ok := usagePackageMap[field.Type]; ok {
+		if msg := g.registry.Lookup(field.Type); msg != nil {
 			importsSet[field.Type] = "../" + strings.TrimSuffix(msg.FilePath, ".go")
 			continue
 		}
+		if enum := g.registry.LookupEnum(field.Type); enum != nil {
+			importsSet[field.Type] = "../" + strings.TrimSuffix(enum.FilePath, ".go")
+			continue
+		}
 
 		if alias, exists := usageMap[field.ImportPath]; exists {
 			field.UsageName = strings.ReplaceAll(field.UsageName, filepath.Base(field.ImportPath), alias)
 		} else {
-			if msg, exists := usagePackageMap[field.Type]; exists && msg.ImportPath != message.ImportPath {
+			if msg := g.registry.Lookup(field.Type); msg != nil && msg.ImportPath != message.ImportPath {
 				base = field.Name
 				if path, exists := importsSet[base]; exists && path != msg.ImportPath {
 					base = fmt.Sprintf("%s%d", base, i)
@@ -93,7 +114,7 @@ func DetermineTsMessageImports(message *Message) []Import {
 	return imports
 }
 
-func DetermineServiceImports(service *Service) []Import {
+func (g *Generator) DetermineServiceImports(service *Service) []Import {
 	var base string
 	var svcImportsSets = make(map[string]string)
 	// key 是 pkg
@@ -115,12 +136,12 @@ func DetermineServiceImports(service *Service) []Import {
 		}
 		method.InputUsageName = fmt.Sprintf("%s.%s", inputAlias, Last(strings.Split(method.InputUsageName, ".")))
 
-		inputMsg, _ := usagePackageMap[Last(strings.Split(method.InputUsageName, "."))]
-		if inputMsg != nil && HasComment(inputMsg.Comment, "@goType") {
-			method.InputUsageName = GetComment(inputMsg.Comment, "@goType", method.InputUsageName)
+		inputMsg := g.registry.Lookup(Last(strings.Split(method.InputUsageName, ".")))
+		if inputMsg != nil && inputMsg.Annotations.Has("goType") {
+			method.InputUsageName = inputMsg.Annotations.Value("goType", method.InputUsageName)
 		}
 
-		outputMsg, _ := usagePackageMap[Last(strings.Split(method.OutputUsageName, "."))]
+		outputMsg := g.registry.Lookup(Last(strings.Split(method.OutputUsageName, ".")))
 		if oa, exists := tmpMap[method.OutputImportPackage]; exists {
 			outputAlias = oa
 			svcImportsSets[oa] = method.OutputImportPackage
@@ -136,8 +157,8 @@ func DetermineServiceImports(service *Service) []Import {
 		}
 		method.OutputUsageName = fmt.Sprintf("%s.%s", outputAlias, Last(strings.Split(method.OutputUsageName, ".")))
 
-		if outputMsg != nil && HasComment(outputMsg.Comment, "@goType") {
-			method.OutputUsageName = GetComment(outputMsg.Comment, "@goType", method.OutputUsageName)
+		if outputMsg != nil && outputMsg.Annotations.Has("goType") {
+			method.OutputUsageName = outputMsg.Annotations.Value("goType", method.OutputUsageName)
 		}
 	}
 
@@ -155,9 +176,10 @@ func DetermineServiceImports(service *Service) []Import {
 	return imports
 }
 
-func DetermineTsServiceImports(service *Service) []Import {
+func (g *Generator) DetermineTsServiceImports(service *Service) []Import {
 	var base string
-	svcImportsSet = make(map[string]string)
+	svcImportsSet := make(map[string]string)
+	svcUsageMap := make(map[string]string)
 
 	for i, method := range service.Methods {
 		if alias, exists := svcUsageMap[method.InputImportPackage]; exists {
@@ -165,7 +187,7 @@ func DetermineTsServiceImports(service *Service) []Import {
 		} else {
 			base = strings.Split(method.InputUsageName, ".")[1]
 			method.InputUsageName = base
-			method.InputImportPackage = fmt.Sprintf("../%s", strings.TrimSuffix(usagePackageMap[base].FilePath, ".go"))
+			method.InputImportPackage = fmt.Sprintf("../%s", strings.TrimSuffix(g.registry.Lookup(base).FilePath, ".go"))
 
 			if importPackage, exists := svcImportsSet[base]; exists && importPackage != method.InputImportPackage {
 				alias = fmt.Sprintf("%s%d", base, i)
@@ -182,7 +204,7 @@ func DetermineTsServiceImports(service *Service) []Import {
 		} else {
 			base = strings.Split(method.OutputUsageName, ".")[1]
 			method.OutputUsageName = base
-			method.OutputImportPackage = fmt.Sprintf("../%s", strings.TrimSuffix(usagePackageMap[base].FilePath, ".go"))
+			method.OutputImportPackage = fmt.Sprintf("../%s", strings.TrimSuffix(g.registry.Lookup(base).FilePath, ".go"))
 
 			if importPackage, exists := svcImportsSet[base]; exists && importPackage != method.OutputImportPackage {
 				alias = fmt.Sprintf("%s%d", base, i)
@@ -201,6 +223,3 @@ func DetermineTsServiceImports(service *Service) []Import {
 	}
 	return imports
 }
-
-var svcImportsSet = make(map[string]string)
-var svcUsageMap = map[string]string{}
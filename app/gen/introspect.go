@@ -0,0 +1,454 @@
+package gen
+
+import (
+	"fmt"
+	"strings"
+	"text/scanner"
+
+	"github.com/goal-web/contracts"
+)
+
+// TableInfo 从数据库中读取的表结构，make:model 据此生成 proto
+type TableInfo struct {
+	Name        string
+	Dialect     string // 连接的驱动名称，决定数据库类型对应的 proto 类型
+	Comment     string
+	Columns     []*ColumnInfo
+	Indexes     []*IndexInfo // 不包含主键
+	ForeignKeys []*ForeignKeyInfo
+	Relations   []*RelationInfo // LinkTables 根据外键推导的关联关系
+	Pivot       bool            // 多对多的中间表，不需要生成模型
+}
+
+// ColumnInfo 数据库中的字段
+type ColumnInfo struct {
+	Name       string `db:"name"`
+	Type       string `db:"type"` // 数据库中的原始类型，例如 varchar(255)、Nullable(UInt64)
+	Nullable   bool   `db:"nullable"`
+	PrimaryKey bool   `db:"primary_key"`
+	Comment    string `db:"comment"`
+}
+
+// IndexInfo 数据库中的索引
+type IndexInfo struct {
+	Name    string
+	Unique  bool
+	Columns []string
+}
+
+// ForeignKeyInfo 单个字段的外键，联合外键无法映射为关联关系，读取时会忽略
+type ForeignKeyInfo struct {
+	Column           string
+	Table            string
+	ReferencedColumn string // sqlite 没有声明时为空，表示引用主键
+}
+
+// RelationInfo 生成到模型中的关联字段
+type RelationInfo struct {
+	Kind  string // belongsTo、hasMany、belongsToMany
+	Name  string
+	Model string
+	Args  []string
+}
+
+type indexRow struct {
+	Name   string `db:"name"`
+	Unique bool   `db:"is_unique"`
+	Column string `db:"column_name"`
+}
+
+type foreignKeyRow struct {
+	Name             string `db:"name"`
+	Column           string `db:"column_name"`
+	Table            string `db:"referenced_table"`
+	ReferencedColumn string `db:"referenced_column"`
+}
+
+// Column 按名称查找字段，不存在时返回 nil
+func (table *TableInfo) Column(name string) *ColumnInfo {
+	for _, column := range table.Columns {
+		if column.Name == name {
+			return column
+		}
+	}
+	return nil
+}
+
+// PrimaryKeys 主键包含的字段
+func (table *TableInfo) PrimaryKeys() []string {
+	var keys []string
+	for _, column := range table.Columns {
+		if column.PrimaryKey {
+			keys = append(keys, column.Name)
+		}
+	}
+	return keys
+}
+
+// Inspector 读取数据库中的表结构，不同的数据库使用各自的系统表
+type Inspector interface {
+	Tables() ([]string, error)
+	Table(name string) (*TableInfo, error)
+}
+
+// NewInspector 根据连接的驱动选择读取表结构的方式
+func NewInspector(connection contracts.DBConnection) (Inspector, error) {
+	switch driver := connection.DriverName(); driver {
+	case "mysql":
+		return &inspector{connection, driver, mysqlQueries}, nil
+	case "postgres":
+		return &inspector{connection, driver, postgresQueries}, nil
+	case "sqlite3":
+		return &inspector{connection, driver, sqliteQueries}, nil
+	case "clickhouse":
+		return &inspector{connection, driver, clickhouseQueries}, nil
+	default:
+		return nil, fmt.Errorf("不支持读取 %s 数据库的表结构", driver)
+	}
+}
+
+// inspectQueries 读取表结构的查询语句，除了 tables 之外都以表名作为参数，为空表示该数据库不支持
+type inspectQueries struct {
+	tables      string
+	comment     string
+	columns     string
+	indexes     string
+	foreignKeys string
+}
+
+var mysqlQueries = inspectQueries{
+	tables:  "SELECT TABLE_NAME FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_TYPE = 'BASE TABLE' ORDER BY TABLE_NAME",
+	comment: "SELECT TABLE_COMMENT FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?",
+	columns: `SELECT COLUMN_NAME AS name, COLUMN_TYPE AS type, IS_NULLABLE = 'YES' AS nullable, COLUMN_KEY = 'PRI' AS primary_key, COLUMN_COMMENT AS comment
+FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION`,
+	indexes: `SELECT INDEX_NAME AS name, NON_UNIQUE = 0 AS is_unique, COLUMN_NAME AS column_name
+FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND INDEX_NAME != 'PRIMARY' ORDER BY INDEX_NAME, SEQ_IN_INDEX`,
+	foreignKeys: `SELECT CONSTRAINT_NAME AS name, COLUMN_NAME AS column_name, REFERENCED_TABLE_NAME AS referenced_table, REFERENCED_COLUMN_NAME AS referenced_column
+FROM information_schema.KEY_COLUMN_USAGE WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND REFERENCED_TABLE_NAME IS NOT NULL ORDER BY CONSTRAINT_NAME, ORDINAL_POSITION`,
+}
+
+var postgresQueries = inspectQueries{
+	tables:  "SELECT tablename FROM pg_catalog.pg_tables WHERE schemaname = ANY (current_schemas(false)) ORDER BY tablename",
+	comment: "SELECT COALESCE(obj_description(to_regclass(?), 'pg_class'), '')",
+	columns: `SELECT a.attname AS name, format_type(a.atttypid, a.atttypmod) AS type, NOT a.attnotnull AS nullable,
+  EXISTS (SELECT 1 FROM pg_index i WHERE i.indrelid = a.attrelid AND i.indisprimary AND a.attnum = ANY (i.indkey)) AS primary_key,
+  COALESCE(col_description(a.attrelid, a.attnum), '') AS comment
+FROM pg_attribute a WHERE a.attrelid = to_regclass(?) AND a.attnum > 0 AND NOT a.attisdropped ORDER BY a.attnum`,
+	indexes: `SELECT c.relname AS name, i.indisunique AS is_unique, a.attname AS column_name
+FROM pg_index i
+JOIN pg_class c ON c.oid = i.indexrelid
+JOIN LATERAL unnest(i.indkey) WITH ORDINALITY AS k(attnum, position) ON true
+JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = k.attnum
+WHERE i.indrelid = to_regclass(?) AND NOT i.indisprimary ORDER BY c.relname, k.position`,
+	foreignKeys: `SELECT c.conname AS name, a.attname AS column_name, r.relname AS referenced_table, ra.attname AS referenced_column
+FROM pg_constraint c
+JOIN LATERAL unnest(c.conkey, c.confkey) WITH ORDINALITY AS k(attnum, referenced_attnum, position) ON true
+JOIN pg_class r ON r.oid = c.confrelid
+JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = k.attnum
+JOIN pg_attribute ra ON ra.attrelid = c.confrelid AND ra.attnum = k.referenced_attnum
+WHERE c.conrelid = to_regclass(?) AND c.contype = 'f' ORDER BY c.conname, k.position`,
+}
+
+// sqlite 没有注释，主键没有声明 NOT NULL 时也不能为空，origin 为 pk 的是主键自动创建的索引
+var sqliteQueries = inspectQueries{
+	tables:  "SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name",
+	columns: `SELECT name, type, "notnull" = 0 AND pk = 0 AS nullable, pk > 0 AS primary_key, '' AS comment FROM pragma_table_info(?) ORDER BY cid`,
+	indexes: `SELECT l.name AS name, l."unique" AS is_unique, i.name AS column_name
+FROM pragma_index_list(?) AS l, pragma_index_info(l.name) AS i
+WHERE l.origin != 'pk' AND i.name IS NOT NULL ORDER BY l.name, i.seqno`,
+	foreignKeys: `SELECT CAST(id AS TEXT) AS name, "from" AS column_name, "table" AS referenced_table, COALESCE("to", '') AS referenced_column
+FROM pragma_foreign_key_list(?) ORDER BY id, seq`,
+}
+
+// clickhouse 没有普通索引、唯一索引和外键，排序键中的字段作为主键
+var clickhouseQueries = inspectQueries{
+	tables:  "SELECT name FROM system.tables WHERE database = currentDatabase() AND NOT is_temporary ORDER BY name",
+	comment: "SELECT comment FROM system.tables WHERE database = currentDatabase() AND name = ?",
+	columns: `SELECT name, type, startsWith(type, 'Nullable(') AS nullable, is_in_primary_key AS primary_key, comment
+FROM system.columns WHERE database = currentDatabase() AND table = ? ORDER BY position`,
+}
+
+type inspector struct {
+	executor contracts.SqlExecutor
+	dialect  string
+	queries  inspectQueries
+}
+
+// Tables 当前数据库中全部的表
+func (inspector *inspector) Tables() ([]string, error) {
+	var tables []string
+	if exception := inspector.executor.Select(&tables, inspector.queries.tables); exception != nil {
+		return nil, exception
+	}
+	return tables, nil
+}
+
+// Table 读取表的字段、索引以及外键，表不存在时返回错误
+func (inspector *inspector) Table(name string) (*TableInfo, error) {
+	executor, queries := inspector.executor, inspector.queries
+	table := &TableInfo{Name: name, Dialect: inspector.dialect}
+	if exception := executor.Select(&table.Columns, queries.columns, name); exception != nil {
+		return nil, exception
+	}
+	if len(table.Columns) == 0 {
+		return nil, fmt.Errorf("表 %s 不存在", name)
+	}
+
+	if queries.comment != "" {
+		var comments []string
+		if exception := executor.Select(&comments, queries.comment, name); exception != nil {
+			return nil, exception
+		}
+		if len(comments) > 0 {
+			table.Comment = comments[0]
+		}
+	}
+
+	if queries.indexes != "" {
+		var rows []indexRow
+		if exception := executor.Select(&rows, queries.indexes, name); exception != nil {
+			return nil, exception
+		}
+		for _, row := range rows {
+			if length := len(table.Indexes); length > 0 && table.Indexes[length-1].Name == row.Name {
+				table.Indexes[length-1].Columns = append(table.Indexes[length-1].Columns, row.Column)
+				continue
+			}
+			table.Indexes = append(table.Indexes, &IndexInfo{Name: row.Name, Unique: row.Unique, Columns: []string{row.Column}})
+		}
+	}
+
+	if queries.foreignKeys != "" {
+		var rows []foreignKeyRow
+		if exception := executor.Select(&rows, queries.foreignKeys, name); exception != nil {
+			return nil, exception
+		}
+		columns := map[string]int{}
+		for _, row := range rows {
+			columns[row.Name]++
+		}
+		for _, row := range rows {
+			if columns[row.Name] == 1 {
+				table.ForeignKeys = append(table.ForeignKeys, &ForeignKeyInfo{Column: row.Column, Table: row.Table, ReferencedColumn: row.ReferencedColumn})
+			}
+		}
+	}
+	return table, nil
+}
+
+// ModelProto 根据表结构生成模型的 proto 文件，mysql 的 enum 字段生成对应的枚举，无法识别的类型作为 string 并返回警告
+func (table *TableInfo) ModelProto(name string, overrides map[string]string) (string, error) {
+	var diagnostics Diagnostics
+	var builder, enums strings.Builder
+	enumValues := map[string]bool{}
+	types := &ProtoTypes{Dialect: table.Dialect, Overrides: overrides}
+	builder.WriteString("syntax = \"proto3\";\n\noption go_package = \".\";\n\n")
+
+	writeComment(&builder, "", table.Comment)
+	keys := table.PrimaryKeys()
+	if len(keys) > 1 {
+		builder.WriteString("//@pk:" + strings.Join(keys, ",") + "\n")
+	}
+	if table.Column("created_at") != nil && table.Column("updated_at") != nil {
+		builder.WriteString("//@timestamps\n")
+	}
+	fmt.Fprintf(&builder, "//@table: %s\nmessage %sModel {\n", table.Name, name)
+
+	for i, column := range table.Columns {
+		if i > 0 {
+			builder.WriteString("\n")
+		}
+		writeComment(&builder, "    ", column.Comment)
+		if len(keys) == 1 && column.PrimaryKey {
+			builder.WriteString("    //@pk\n")
+		}
+		if column.Nullable {
+			builder.WriteString("    //@nullable\n")
+		}
+		for _, index := range table.Indexes {
+			if index.Columns[0] == column.Name {
+				builder.WriteString("    " + index.annotation() + "\n")
+			}
+		}
+
+		protoType, known := types.Lookup(column.Type)
+		if values := ParseSQLType(column.Type).EnumValues(); !known && len(values) > 0 {
+			protoType = name + ToCamelCase(column.Name) + "Enum"
+			writeEnum(&enums, protoType, values, enumValues)
+		} else if !known {
+			warning := NewDiagnostic(scanner.Position{}, fmt.Sprintf("column %s.%s", table.Name, column.Name),
+				fmt.Errorf("无法识别的数据库类型 %s，已使用 string，可以在 env.toml 的 [gen.types] 中指定对应的 proto 类型", column.Type))
+			warning.Severity = SeverityWarning
+			diagnostics.Add(warning)
+		}
+		fmt.Fprintf(&builder, "    %s %s = %d;\n", protoType, column.Name, i+1)
+	}
+
+	for i, relation := range table.Relations {
+		label := "repeated "
+		if relation.Kind == "belongsTo" {
+			label = ""
+		}
+		fmt.Fprintf(&builder, "\n    //@%s:%s\n    %s%s %s = %d;\n",
+			relation.Kind, strings.Join(relation.Args, ","), label, relation.Model, relation.Name, len(table.Columns)+i+1)
+	}
+
+	builder.WriteString("}\n")
+	builder.WriteString(enums.String())
+	return builder.String(), diagnostics.Err()
+}
+
+// writeEnum 枚举值从 1 开始，与 mysql enum 的序号一致，@msg 保存数据库中的原始值。
+// proto3 的枚举值与枚举类型处于同一个作用域，所以值名以枚举名作为前缀，并且在同一个文件中去重
+func writeEnum(builder *strings.Builder, name string, values []string, names map[string]bool) {
+	prefix := strings.TrimSuffix(name, "Enum")
+	fmt.Fprintf(builder, "\nenum %s {\n    %sUnspecified = 0;\n", name, prefix)
+	names[prefix+"Unspecified"] = true
+	for i, value := range values {
+		valueName := prefix + enumValueName(value, i+1)
+		if names[valueName] {
+			valueName = fmt.Sprintf("%s%d", valueName, i+1)
+		}
+		names[valueName] = true
+		fmt.Fprintf(builder, "    //@msg:%s\n    %s = %d;\n", value, valueName, i+1)
+	}
+	builder.WriteString("}\n")
+}
+
+// LinkTables 根据外键推导关联关系：外键所在的表 belongsTo 被引用的表，被引用的表 hasMany 外键所在的表，
+// 只有两个外键以及 id、时间字段的表作为中间表，两边的表互相 belongsToMany
+func LinkTables(tables []*TableInfo) {
+	byName := map[string]*TableInfo{}
+	for _, table := range tables {
+		byName[table.Name] = table
+	}
+	for _, table := range tables {
+		for _, key := range table.ForeignKeys {
+			if key.ReferencedColumn == "" {
+				key.ReferencedColumn = "id"
+				if parent := byName[key.Table]; parent != nil && len(parent.PrimaryKeys()) == 1 {
+					key.ReferencedColumn = parent.PrimaryKeys()[0]
+				}
+			}
+		}
+	}
+
+	for _, table := range tables {
+		if table.isPivot(byName) {
+			table.Pivot = true
+			first, second := table.ForeignKeys[0], table.ForeignKeys[1]
+			left, right := byName[first.Table], byName[second.Table]
+			left.addRelation(second.Column, &RelationInfo{
+				Kind: "belongsToMany", Name: right.Name, Model: ModelName(right.Name) + "Model",
+				Args: []string{table.Name, first.Column, second.ReferencedColumn, first.ReferencedColumn, second.Column},
+			})
+			right.addRelation(first.Column, &RelationInfo{
+				Kind: "belongsToMany", Name: left.Name, Model: ModelName(left.Name) + "Model",
+				Args: []string{table.Name, second.Column, first.ReferencedColumn, second.ReferencedColumn, first.Column},
+			})
+			continue
+		}
+
+		references := map[string]int{}
+		for _, key := range table.ForeignKeys {
+			references[key.Table]++
+		}
+		for _, key := range table.ForeignKeys {
+			parent := byName[key.Table]
+			if parent == nil {
+				continue
+			}
+			// 多个外键引用同一张表时，以模型命名的外键（例如 user_id）使用 posts，其余的使用 posts_by_editor
+			hasMany := table.Name
+			if references[key.Table] > 1 && key.Column != singularize(parent.Name)+"_id" {
+				hasMany += "_by_" + strings.TrimSuffix(key.Column, "_id")
+			}
+			name := strings.TrimSuffix(key.Column, "_id")
+			if name == key.Column {
+				name = singularize(parent.Name)
+			}
+			table.addRelation(key.Column, &RelationInfo{
+				Kind: "belongsTo", Name: name, Model: ModelName(parent.Name) + "Model",
+				Args: []string{key.ReferencedColumn, key.Column},
+			})
+			parent.addRelation(key.Column, &RelationInfo{
+				Kind: "hasMany", Name: hasMany, Model: ModelName(table.Name) + "Model",
+				Args: []string{key.Column, key.ReferencedColumn},
+			})
+		}
+	}
+}
+
+// isPivot 是否为多对多的中间表，两个外键不能为空并且引用不同的表，引用的表都需要存在
+func (table *TableInfo) isPivot(tables map[string]*TableInfo) bool {
+	if len(table.ForeignKeys) != 2 || table.ForeignKeys[0].Table == table.ForeignKeys[1].Table {
+		return false
+	}
+	allowed := map[string]bool{"id": true, "created_at": true, "updated_at": true}
+	for _, key := range table.ForeignKeys {
+		if column := table.Column(key.Column); tables[key.Table] == nil || column == nil || column.Nullable {
+			return false
+		}
+		allowed[key.Column] = true
+	}
+	for _, column := range table.Columns {
+		if !allowed[column.Name] {
+			return false
+		}
+	}
+	return true
+}
+
+// addRelation 关联字段与已有的字段重名时，加上外键作为后缀，例如 posts_by_editor
+func (table *TableInfo) addRelation(column string, relation *RelationInfo) {
+	exists := func(name string) bool {
+		if table.Column(name) != nil {
+			return true
+		}
+		for _, item := range table.Relations {
+			if item.Name == name {
+				return true
+			}
+		}
+		return false
+	}
+	if exists(relation.Name) {
+		relation.Name += "_by_" + strings.TrimSuffix(column, "_id")
+	}
+	for name, i := relation.Name, 2; exists(relation.Name); i++ {
+		relation.Name = fmt.Sprintf("%s_%d", name, i)
+	}
+	table.Relations = append(table.Relations, relation)
+}
+
+// annotation 索引对应的 @index、@unique 注解，名称与默认值相同时省略
+func (index *IndexInfo) annotation() string {
+	kind := "index"
+	if index.Unique {
+		kind = "unique"
+	}
+	name := index.Name
+	if strings.HasPrefix(name, "sqlite_autoindex_") || (len(index.Columns) == 1 && name == index.Columns[0]+"_idx") {
+		// sqlite 为 UNIQUE 约束自动创建的索引不能用这个名称重新创建
+		name = ""
+	}
+	var args []string
+	if len(index.Columns) > 1 {
+		args = []string{name, "(" + strings.Join(index.Columns, ";") + ")"}
+	} else if name != "" {
+		args = []string{name}
+	}
+	if len(args) == 0 {
+		return "//@" + kind
+	}
+	return "//@" + kind + ":" + strings.Join(args, ",")
+}
+
+func writeComment(builder *strings.Builder, indent, comment string) {
+	if comment = strings.TrimSpace(comment); comment == "" {
+		return
+	}
+	for _, line := range strings.Split(comment, "\n") {
+		builder.WriteString(indent + "// " + strings.TrimSpace(line) + "\n")
+	}
+}
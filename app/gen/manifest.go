@@ -0,0 +1,277 @@
+package gen
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/scanner"
+
+	"github.com/emicklei/proto"
+)
+
+// ManifestFile 增量生成使用的清单文件，保存在输出目录中
+const ManifestFile = ".goal-gen.lock"
+
+// Manifest 记录每个 proto 文件生成时的 hash 以及它生成的文件
+type Manifest struct {
+	Version string                    `json:"version"`
+	Sources map[string]*ManifestEntry `json:"sources"` // key 是 proto 文件路径
+	Orphans *ManifestOrphans          `json:"orphans,omitempty"`
+}
+
+// ManifestOrphans 已经不再生成，但是还没有使用 --prune 删除的文件和路由注册
+type ManifestOrphans struct {
+	Outputs []string           `json:"outputs,omitempty"`
+	Routers []*RouterCollector `json:"routers,omitempty"`
+}
+
+// ManifestEntry 单个 proto 文件的生成记录
+type ManifestEntry struct {
+	Hash         string   `json:"hash"`          // proto 文件内容的 hash
+	ImportsHash  string   `json:"imports_hash"`  // 传递引用的 proto 文件的 hash
+	TemplateHash string   `json:"template_hash"` // 模板的 hash
+	Version      string   `json:"version"`       // goal-cli 版本
+	Outputs      []string `json:"outputs"`       // 生成的文件，相对于输出目录
+
+	Routers []*RouterCollector `json:"routers,omitempty"` // 注册到 controllers/kernel.go 的路由
+}
+
+// LoadManifest 读取输出目录中的清单文件，文件不存在时返回空清单
+func LoadManifest(outputDir string) (*Manifest, error) {
+	manifest := &Manifest{Version: Version, Sources: make(map[string]*ManifestEntry)}
+	path := filepath.Join(outputDir, ManifestFile)
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return manifest, nil
+	} else if err != nil {
+		return manifest, NewDiagnostic(scanner.Position{Filename: path}, "manifest", err)
+	}
+
+	if err = json.Unmarshal(content, manifest); err != nil {
+		// 清单损坏时全部重新生成
+		warning := NewDiagnostic(scanner.Position{Filename: path}, "manifest", fmt.Errorf("清单文件已损坏，将全部重新生成：%v", err))
+		warning.Severity = SeverityWarning
+		return &Manifest{Version: Version, Sources: make(map[string]*ManifestEntry)}, warning
+	}
+	if manifest.Sources == nil {
+		manifest.Sources = make(map[string]*ManifestEntry)
+	}
+	return manifest, nil
+}
+
+// Save 把清单写入输出目录
+func (manifest *Manifest) Save(outputDir string) error {
+	manifest.Version = Version
+	content, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(outputDir, ManifestFile)
+	if err = os.WriteFile(path, append(content, '\n'), 0644); err != nil {
+		return NewDiagnostic(scanner.Position{Filename: path}, "manifest", err)
+	}
+	return nil
+}
+
+// Fresh 判断 proto 文件是否不需要重新生成：hash 全部一致并且生成的文件都还在
+func (manifest *Manifest) Fresh(protoFile string, entry *ManifestEntry, outputDir string) bool {
+	exists, ok := manifest.Sources[manifestKey(protoFile)]
+	if !ok ||
+		exists.Hash != entry.Hash ||
+		exists.ImportsHash != entry.ImportsHash ||
+		exists.TemplateHash != entry.TemplateHash ||
+		exists.Version != entry.Version {
+		return false
+	}
+
+	for _, output := range exists.Outputs {
+		if _, err := os.Stat(filepath.Join(outputDir, output)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Record 记录 proto 文件本次生成的结果
+func (manifest *Manifest) Record(protoFile string, entry *ManifestEntry) {
+	manifest.Sources[manifestKey(protoFile)] = entry
+}
+
+// Invalidate 让 proto 文件下次一定会重新生成，已有的生成记录仍然保留，用于判断文件归属
+func (manifest *Manifest) Invalidate(protoFile string) {
+	if exists, ok := manifest.Sources[manifestKey(protoFile)]; ok {
+		manifest.Sources[manifestKey(protoFile)] = &ManifestEntry{
+			Outputs: exists.Outputs,
+			Routers: exists.Routers,
+		}
+	}
+}
+
+// Retain 只保留指定 proto 文件的记录
+func (manifest *Manifest) Retain(protoFiles []string) {
+	var keep = make(map[string]struct{})
+	for _, protoFile := range protoFiles {
+		keep[manifestKey(protoFile)] = struct{}{}
+	}
+	for key := range manifest.Sources {
+		if _, exists := keep[key]; !exists {
+			delete(manifest.Sources, key)
+		}
+	}
+}
+
+// Ownership 当前清单中记录的全部生成文件以及路由注册
+func (manifest *Manifest) Ownership() (map[string]struct{}, map[string]*RouterCollector) {
+	var outputs = make(map[string]struct{})
+	var routers = make(map[string]*RouterCollector)
+	for _, entry := range manifest.Sources {
+		for _, output := range entry.Outputs {
+			outputs[output] = struct{}{}
+		}
+		for _, router := range entry.Routers {
+			routers[routerKey(router)] = router
+		}
+	}
+	return outputs, routers
+}
+
+// CollectOrphans 对比上一次的归属记录，找出已经不再生成的文件和路由注册
+func (manifest *Manifest) CollectOrphans(previousOutputs map[string]struct{}, previousRouters map[string]*RouterCollector) {
+	if manifest.Orphans != nil {
+		for _, output := range manifest.Orphans.Outputs {
+			previousOutputs[output] = struct{}{}
+		}
+		for _, router := range manifest.Orphans.Routers {
+			previousRouters[routerKey(router)] = router
+		}
+	}
+
+	outputs, routers := manifest.Ownership()
+	var orphans ManifestOrphans
+	for output := range previousOutputs {
+		if _, exists := outputs[output]; !exists {
+			orphans.Outputs = append(orphans.Outputs, output)
+		}
+	}
+	for key, router := range previousRouters {
+		if _, exists := routers[key]; !exists {
+			orphans.Routers = append(orphans.Routers, router)
+		}
+	}
+
+	sort.Strings(orphans.Outputs)
+	sort.Slice(orphans.Routers, func(i, j int) bool {
+		return routerKey(orphans.Routers[i]) < routerKey(orphans.Routers[j])
+	})
+
+	manifest.Orphans = nil
+	if len(orphans.Outputs)+len(orphans.Routers) > 0 {
+		manifest.Orphans = &orphans
+	}
+}
+
+// SetOutputs 记录生成的文件以及路由注册，路径转换为相对于输出目录的路径
+func (entry *ManifestEntry) SetOutputs(outputDirAbs string, output *Output) {
+	entry.Outputs = nil
+	for _, file := range output.Files {
+		entry.Outputs = append(entry.Outputs, relativePath(outputDirAbs, file.Path))
+	}
+	sort.Strings(entry.Outputs)
+	entry.Routers = output.Routers
+}
+
+func routerKey(router *RouterCollector) string {
+	return router.ImportPath + " " + router.UsageName
+}
+
+// NewManifestEntry 计算 proto 文件、它传递引用的 proto 文件以及模板的 hash
+func (g *Generator) NewManifestEntry(protoFile string) (*ManifestEntry, error) {
+	content, err := os.ReadFile(protoFile)
+	if err != nil {
+		return nil, NewDiagnostic(scanner.Position{Filename: protoFile}, "", err)
+	}
+
+	imports := make(map[string]string)
+	if err = g.collectImportHashes(protoFile, imports); err != nil {
+		var diagnostic *Diagnostic
+		if !errors.As(err, &diagnostic) {
+			err = NewDiagnostic(scanner.Position{Filename: protoFile}, "", err)
+		}
+		return nil, err
+	}
+	delete(imports, manifestKey(protoFile))
+
+	var keys []string
+	for key := range imports {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	for _, key := range keys {
+		sb.WriteString(key)
+		sb.WriteByte(0)
+		sb.WriteString(imports[key])
+		sb.WriteByte('\n')
+	}
+
+	return &ManifestEntry{
+		Hash:         hashBytes(content),
+		ImportsHash:  hashBytes([]byte(sb.String())),
+		TemplateHash: g.templateHash,
+		Version:      Version,
+	}, nil
+}
+
+// collectImportHashes 递归计算 proto 文件以及它引用的全部 proto 文件的 hash
+func (g *Generator) collectImportHashes(protoFile string, hashes map[string]string) error {
+	// 使用相对路径作为 key，项目目录移动之后 hash 保持不变
+	key := protoFile
+	if abs, err := filepath.Abs(protoFile); err == nil {
+		key = relativePath(g.pwd, abs)
+	}
+	if _, exists := hashes[key]; exists {
+		return nil
+	}
+
+	content, err := os.ReadFile(protoFile)
+	if err != nil {
+		return err
+	}
+	hashes[key] = hashBytes(content)
+
+	definition, err := g.parse(protoFile)
+	if err != nil {
+		return err
+	}
+
+	for _, element := range definition.Elements {
+		if v, ok := element.(*proto.Import); ok {
+			importFile, builtin, err := g.resolveImport(v.Filename)
+			if err == nil && !builtin {
+				err = g.collectImportHashes(importFile, hashes)
+			}
+			var diagnostic *Diagnostic
+			if err != nil && !errors.As(err, &diagnostic) {
+				return NewDiagnostic(v.Position, "import "+v.Filename, err)
+			} else if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func manifestKey(protoFile string) string {
+	return filepath.ToSlash(filepath.Clean(protoFile))
+}
+
+func hashBytes(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,50 @@
+package gen
+
+import (
+	"fmt"
+
+	"github.com/emicklei/proto"
+)
+
+// MapType proto 中的 map<K, V>，value 是消息时会和普通字段一样解析 import
+type MapType struct {
+	Key   *Field
+	Value *Field
+}
+
+// extractMapField 提取 map 字段，key 和 value 分别保存为独立的字段
+func (g *Generator) extractMapField(messageName string, field *proto.MapField) (*Field, error) {
+	var diagnostics Diagnostics
+	subject := fmt.Sprintf("field %s.%s", messageName, field.Name)
+	annotations, err := g.annotations.Parse(field.Comment, TargetField, subject)
+	diagnostics.Add(err)
+	if field.KeyType == "bool" && !g.sdk() {
+		// encoding/json 只支持字符串和整数的 key，ts 中 bool 的 key 按照字符串处理
+		diagnostics.Errorf(field.Position, subject, "go 代码中的 map 不能使用 bool 类型的 key，encoding/json 无法序列化")
+	}
+	return &Field{
+		Position:    field.Position,
+		Comment:     field.Comment,
+		Annotations: annotations,
+		Name:        ToCamelCase(field.Name),
+		Type:        fmt.Sprintf("map<%s, %s>", field.KeyType, field.Type),
+		JSONName:    field.Name,
+		UsageName:   fmt.Sprintf("map<%s, %s>", field.KeyType, field.Type),
+		GoType:      annotations.Value("goType", ""),
+		Map: &MapType{
+			Key: &Field{
+				Name:      ToCamelCase(field.Name) + "Key",
+				Type:      field.KeyType,
+				JSONName:  field.Name,
+				UsageName: field.KeyType,
+			},
+			Value: &Field{
+				Name:      ToCamelCase(field.Name) + "Value",
+				Type:      field.Type,
+				JSONName:  field.Name,
+				UsageName: field.Type,
+				WellKnown: LookupWellKnownType(field.Type),
+			},
+		},
+	}, diagnostics.Err()
+}
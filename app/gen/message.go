@@ -1,30 +1,34 @@
 package gen
 
 import (
-	"fmt"
 	"github.com/emicklei/proto"
-	"log"
-	"os"
 	"path/filepath"
 	"strings"
-	"text/template"
+	"text/scanner"
 )
 
 type Field struct {
-	Comment    *proto.Comment
-	Index      int
-	Name       string
-	Type       string
-	JSONName   string
-	Comments   string
-	Tags       string
-	ImportPath string
-	UsageName  string
-	GoType     string // 用来映射 any 之类的
-	Ptr        bool
-	IsModel    bool
-	Repeated   bool
-	Parent     *Message
+	Position    scanner.Position
+	Comment     *proto.Comment
+	Annotations Annotations // 解析后的注解
+	Index       int
+	Name        string
+	Type        string
+	JSONName    string
+	Comments    string
+	Tags        string
+	ImportPath  string
+	UsageName   string
+	GoType      string            // 用来映射 any 之类的
+	WellKnown   *WellKnownType    // google/protobuf 内置类型
+	Oneof       *Oneof            // 代表整个 oneof 的字段
+	Map         *MapType          // map<K, V> 的 key 和 value
+	Rules       []*ValidationRule // @validate 声明的校验规则
+	Cast        string            // @cast 声明的存储转换：encrypted、hashed、json
+	Ptr         bool
+	IsModel     bool
+	Repeated    bool
+	Parent      *Message
 }
 
 type Message struct {
@@ -33,12 +37,16 @@ type Message struct {
 	Name    string
 	RawName string // 没有后缀
 
-	TableName  string // 模型才有
-	SoftDelete string // 模型才有
-	PrimaryKey string // 模型才有
-	Fields     []*Field
+	TableName   string   // 模型才有
+	SoftDelete  string   // 模型才有，软删除时间的字段名
+	PrimaryKey  string   // 模型才有，联合主键时为第一个字段
+	PrimaryKeys []string // 模型才有，主键包含的全部字段
+	KeyStrategy string   // 模型才有，主键的生成策略：uuid、ulid、snowflake，为空时使用自增主键
+	Version     string   // 乐观锁版本号的字段名
+	Fields      []*Field
 
 	Relations       []*Field // 关联关系
+	Oneofs          []*Oneof // oneof 的全部取值
 	Template        string   // model
 	Authenticatable bool     // 是否可用作登录
 	ImportPath      string   // 包路径，例如：biz/models/auth
@@ -46,28 +54,45 @@ type Message struct {
 	FilePath        string   // biz/models/user.go
 	Comments        []string
 	Comment         *proto.Comment
+	Annotations     Annotations // 解析后的注解
+	Position        scanner.Position
 }
 
-func GenMessages(tmpl *template.Template, baseOutputDir string, messages []*Message) []string {
-	var files []string
-	for _, message := range messages {
-		outputPath := filepath.Join(baseOutputDir, message.FilePath)
-
-		// 创建目录
-		err := os.MkdirAll(filepath.Dir(outputPath), os.ModePerm)
-		if err != nil {
-			log.Fatal(err)
+// SoftDeleteField 软删除时间对应的字段，没有开启软删除时返回 nil
+func (message *Message) SoftDeleteField() *Field {
+	if message.SoftDelete == "" {
+		return nil
+	}
+	for _, field := range message.Fields {
+		if field.JSONName == message.SoftDelete {
+			return field
 		}
+	}
+	return nil
+}
 
-		// 创建输出文件
-		outFile, err := os.Create(outputPath)
-		if err != nil {
-			log.Fatal(err)
+// HasAnnotation 消息本身或者任意字段声明了指定的注解
+func (message *Message) HasAnnotation(name string) bool {
+	if message.Annotations.Has(name) {
+		return true
+	}
+	for _, field := range messageFields(message) {
+		if field.Annotations.Has(name) {
+			return true
 		}
+	}
+	return false
+}
+
+func (g *Generator) GenMessages(baseOutputDir string, messages []*Message) ([]*File, error) {
+	var diagnostics Diagnostics
+	var files []*File
+	for _, message := range messages {
+		outputPath := filepath.Join(baseOutputDir, message.FilePath)
 
 		// 执行模板，传入 moduleName 和 outputPackageName
-		err = tmpl.ExecuteTemplate(outFile, message.Template, map[string]any{
-			"Imports":   DetermineMessageImports(message),
+		file, err := renderFile(g.tmpl, message.Template, outputPath, map[string]any{
+			"Imports":   g.DetermineMessageImports(message),
 			"Model":     message,
 			"Package":   filepath.Base(message.ImportPath),
 			"Name":      message.Name,
@@ -75,36 +100,24 @@ func GenMessages(tmpl *template.Template, baseOutputDir string, messages []*Mess
 			"Relations": message.Relations,
 		})
 		if err != nil {
-			log.Fatal(err)
+			diagnostics.Add(NewDiagnostic(message.Position, "message "+message.Name, err))
+			continue
 		}
-		outFile.Close()
 
-		fmt.Printf("生成模型文件：%s\n", outputPath)
-		files = append(files, outputPath)
+		files = append(files, file)
 	}
-	return files
+	return files, diagnostics.Err()
 }
 
-func SDKMessages(tmpl *template.Template, baseOutputDir string, messages []*Message) []string {
-	var files []string
+func (g *Generator) SDKMessages(baseOutputDir string, messages []*Message) ([]*File, error) {
+	var diagnostics Diagnostics
+	var files []*File
 	for _, message := range messages {
 		outputPath := filepath.Join(baseOutputDir, strings.ReplaceAll(message.FilePath, ".go", ".ts"))
 
-		// 创建目录
-		err := os.MkdirAll(filepath.Dir(outputPath), os.ModePerm)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		// 创建输出文件
-		outFile, err := os.Create(outputPath)
-		if err != nil {
-			log.Fatal(err)
-		}
-
 		// 执行模板，传入 moduleName 和 outputPackageName
-		err = tmpl.ExecuteTemplate(outFile, message.Template, map[string]any{
-			"Imports":   DetermineTsMessageImports(message),
+		file, err := renderFile(g.tmpl, message.Template, outputPath, map[string]any{
+			"Imports":   g.DetermineTsMessageImports(message),
 			"Model":     message,
 			"Package":   filepath.Base(message.ImportPath),
 			"Name":      message.Name,
@@ -112,12 +125,11 @@ func SDKMessages(tmpl *template.Template, baseOutputDir string, messages []*Mess
 			"Relations": message.Relations,
 		})
 		if err != nil {
-			log.Fatal(err)
+			diagnostics.Add(NewDiagnostic(message.Position, "message "+message.Name, err))
+			continue
 		}
-		outFile.Close()
 
-		fmt.Printf("生成模型文件：%s\n", outputPath)
-		files = append(files, outputPath)
+		files = append(files, file)
 	}
-	return files
+	return files, diagnostics.Err()
 }
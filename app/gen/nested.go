@@ -0,0 +1,126 @@
+package gen
+
+import (
+	"strings"
+	"text/scanner"
+
+	"github.com/emicklei/proto"
+)
+
+// scopedMessage 展开后的消息，嵌套的消息使用拼接后的名称，例如 Order.Item 展开为 OrderItem
+type scopedMessage struct {
+	*proto.Message
+	Scope    []string // 外层消息的原始名称，例如：[Order]
+	Original string   // 原始名称，例如：Item
+}
+
+// path 消息自身的作用域，例如：[Order Item]
+func (msg *scopedMessage) path() []string {
+	return append(msg.Scope[:len(msg.Scope):len(msg.Scope)], msg.Original)
+}
+
+// scopedEnum 展开后的枚举，命名规则与嵌套的消息一致
+type scopedEnum struct {
+	*proto.Enum
+	Scope    []string
+	Original string
+}
+
+// flattenMessages 按照声明顺序展开 proto 文件中的全部消息，外层消息在嵌套消息之前
+func flattenMessages(def *proto.Proto) []*scopedMessage {
+	var list []*scopedMessage
+	var walk func(elements []proto.Visitee, scope []string)
+	walk = func(elements []proto.Visitee, scope []string) {
+		for _, element := range elements {
+			if msg, ok := element.(*proto.Message); ok {
+				flatten := *msg
+				flatten.Name = strings.Join(append(scope[:len(scope):len(scope)], msg.Name), "")
+				list = append(list, &scopedMessage{Message: &flatten, Scope: scope, Original: msg.Name})
+				walk(msg.Elements, append(scope[:len(scope):len(scope)], msg.Name))
+			}
+		}
+	}
+	walk(def.Elements, nil)
+	return list
+}
+
+// flattenEnums 展开 proto 文件中的全部枚举，包括消息中嵌套的枚举
+func flattenEnums(def *proto.Proto) []*scopedEnum {
+	var list []*scopedEnum
+	var walk func(elements []proto.Visitee, scope []string)
+	walk = func(elements []proto.Visitee, scope []string) {
+		for _, element := range elements {
+			switch v := element.(type) {
+			case *proto.Enum:
+				flatten := *v
+				flatten.Name = strings.Join(append(scope[:len(scope):len(scope)], v.Name), "")
+				list = append(list, &scopedEnum{Enum: &flatten, Scope: scope, Original: v.Name})
+			case *proto.Message:
+				walk(v.Elements, append(scope[:len(scope):len(scope)], v.Name))
+			}
+		}
+	}
+	walk(def.Elements, nil)
+	return list
+}
+
+// registerNestedNames 注册嵌套类型的限定名，展开后的名称与其他类型冲突时报告错误
+func (g *Generator) registerNestedNames(messages []*scopedMessage, enums []*scopedEnum) error {
+	var diagnostics Diagnostics
+	declared := make(map[string]string)
+	declare := func(kind, name string, scope []string, original string, position scanner.Position) {
+		qualified := strings.Join(append(scope[:len(scope):len(scope)], original), ".")
+		if exists, ok := declared[name]; ok {
+			diagnostics.Errorf(position, kind+" "+qualified, "展开后的名称 %s 与 %s 冲突", name, exists)
+			return
+		}
+		declared[name] = qualified
+		if len(scope) > 0 {
+			g.registry.Alias(qualified, name)
+		}
+	}
+
+	for _, msg := range messages {
+		declare("message", msg.Name, msg.Scope, msg.Original, msg.Position)
+	}
+	for _, enum := range enums {
+		declare("enum", enum.Name, enum.Scope, enum.Original, enum.Position)
+	}
+	return diagnostics.Err()
+}
+
+// resolveType 按照 protobuf 的作用域规则解析字段引用的类型，嵌套的类型会被替换为展开后的名称
+func (g *Generator) resolveType(typeName string, scope []string, pkg string) string {
+	name := strings.TrimPrefix(typeName, ".")
+	if pkg != "" {
+		name = strings.TrimPrefix(name, pkg+".")
+	}
+	// 由内向外依次查找，例如在 Order.Item 中引用 Status，会依次尝试 Order.Item.Status、Order.Status、Status
+	for i := len(scope); i >= 0; i-- {
+		candidate := strings.Join(append(scope[:i:i], name), ".")
+		if alias := g.registry.ResolveAlias(candidate); alias != "" {
+			return alias
+		}
+	}
+	return typeName
+}
+
+// packageName proto 文件中声明的 package
+func packageName(def *proto.Proto) string {
+	for _, element := range def.Elements {
+		if pkg, ok := element.(*proto.Package); ok {
+			return pkg.Name
+		}
+	}
+	return ""
+}
+
+// resolveFieldTypes 解析消息中全部字段引用的类型
+func (g *Generator) resolveFieldTypes(message *Message, scope []string, pkg string) {
+	for _, field := range messageFields(message) {
+		if resolved := g.resolveType(field.Type, scope, pkg); resolved != field.Type {
+			field.Type = resolved
+			field.UsageName = resolved
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package gen
+
+import (
+	"fmt"
+	"text/scanner"
+
+	"github.com/emicklei/proto"
+)
+
+// Oneof proto 中的 oneof，生成 go 的 sealed interface 以及 ts 的 discriminated union
+type Oneof struct {
+	Position scanner.Position
+	Comment  *proto.Comment
+	Name     string   // 驼峰命名，例如：Method
+	JSONName string   // 原始名称，例如：method
+	TypeName string   // interface 名称，例如：PayRequestMethod
+	Cases    []*Field // 每个取值都会生成一个实现了 interface 的类型
+}
+
+// extractOneof 提取 oneof，返回代表整个 oneof 的字段，具体的取值保存在 Oneof.Cases 中
+func (g *Generator) extractOneof(messageName string, oneof *proto.Oneof) (*Field, *Oneof, error) {
+	var diagnostics Diagnostics
+	item := &Oneof{
+		Position: oneof.Position,
+		Comment:  oneof.Comment,
+		Name:     ToCamelCase(oneof.Name),
+		JSONName: oneof.Name,
+		TypeName: messageName + ToCamelCase(oneof.Name),
+	}
+
+	for _, element := range oneof.Elements {
+		if field, ok := element.(*proto.OneOfField); ok {
+			annotations, err := g.annotations.Parse(field.Comment, TargetField, fmt.Sprintf("field %s.%s", messageName, field.Name))
+			diagnostics.Add(err)
+			item.Cases = append(item.Cases, &Field{
+				Position:    field.Position,
+				Comment:     field.Comment,
+				Annotations: annotations,
+				Name:        ToCamelCase(field.Name),
+				Type:        field.Type,
+				JSONName:    field.Name,
+				UsageName:   field.Type,
+				GoType:      annotations.Value("goType", ""),
+				WellKnown:   LookupWellKnownType(field.Type),
+			})
+		}
+	}
+
+	return &Field{
+		Position:  oneof.Position,
+		Comment:   oneof.Comment,
+		Name:      item.Name,
+		Type:      item.TypeName,
+		JSONName:  oneof.Name,
+		UsageName: item.TypeName,
+		GoType:    item.TypeName,
+		Oneof:     item,
+	}, item, diagnostics.Err()
+}
+
+// messageFields 消息的全部字段，包括关联关系、oneof 的取值以及 map 的 value
+func messageFields(message *Message) []*Field {
+	fields := append(message.Fields[:len(message.Fields):len(message.Fields)], message.Relations...)
+	for _, oneof := range message.Oneofs {
+		fields = append(fields, oneof.Cases...)
+	}
+	for _, field := range fields {
+		if field.Map != nil {
+			fields = append(fields, field.Map.Value)
+		}
+	}
+	return fields
+}
@@ -0,0 +1,50 @@
+package gen
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/scanner"
+	"text/template"
+)
+
+// File 渲染到内存中的生成文件
+type File struct {
+	Path    string // 输出的绝对路径
+	Content []byte
+}
+
+// Output 一个 proto 文件渲染出的全部产物
+type Output struct {
+	Files   []*File
+	Routers []*RouterCollector
+}
+
+// renderFile 执行指定的模板，把结果保存在内存中
+func renderFile(tmpl *template.Template, name, path string, data any) (*File, error) {
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, name, data); err != nil {
+		return nil, err
+	}
+	return &File{Path: path, Content: buf.Bytes()}, nil
+}
+
+// WriteFiles 把渲染好的文件写入磁盘
+func WriteFiles(files []*File) error {
+	var diagnostics Diagnostics
+	for _, file := range files {
+		// 创建目录
+		if err := os.MkdirAll(filepath.Dir(file.Path), os.ModePerm); err != nil {
+			diagnostics.Add(NewDiagnostic(scanner.Position{Filename: file.Path}, "write", err))
+			continue
+		}
+
+		if err := os.WriteFile(file.Path, file.Content, 0644); err != nil {
+			diagnostics.Add(NewDiagnostic(scanner.Position{Filename: file.Path}, "write", err))
+			continue
+		}
+		fmt.Printf("生成文件：%s\n", file.Path)
+	}
+	return diagnostics.Err()
+}
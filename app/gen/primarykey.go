@@ -0,0 +1,113 @@
+package gen
+
+import (
+	"slices"
+	"strings"
+)
+
+// keyStrategy 主键的生成策略，在 Save 插入数据之前生成主键
+type keyStrategy struct {
+	Types  []string // 支持的字段类型
+	DBType string   // 为空时沿用字段类型对应的数据库类型
+}
+
+var keyStrategies = map[string]keyStrategy{
+	"uuid":      {Types: []string{"string"}, DBType: "CHAR(36)"},
+	"ulid":      {Types: []string{"string"}, DBType: "CHAR(26)"},
+	"snowflake": {Types: []string{"int64", "uint64", "sint64", "fixed64", "sfixed64"}},
+}
+
+// extractPrimaryKey 解析模型的主键：消息上的 @pk:a,b 声明联合主键，字段上的 @pk:uuid 声明主键的生成策略
+func (g *Generator) extractPrimaryKey(message *Message) error {
+	var diagnostics Diagnostics
+	subject := "message " + message.Name
+	fields := map[string]*Field{}
+	for _, field := range message.Fields {
+		fields[field.JSONName] = field
+	}
+
+	if annotation := message.Annotations.Get("pk"); annotation != nil {
+		columns := annotation.Values()
+		if len(columns) == 0 {
+			diagnostics.Errorf(annotation.Position, subject, "@pk 需要声明联合主键的字段，例如 @pk:user_id,role_id")
+		}
+		for _, column := range columns {
+			if _, exists := fields[column]; !exists {
+				diagnostics.Errorf(annotation.Position, subject, "@pk 的字段 %s 不存在", column)
+			}
+		}
+		for _, field := range message.Fields {
+			if field.Annotations.Has("pk") {
+				diagnostics.Errorf(field.Annotations.Get("pk").Position, subject, "已经在消息上声明了联合主键，字段 %s 不能再使用 @pk", field.JSONName)
+			}
+		}
+		if message.Authenticatable {
+			diagnostics.Errorf(annotation.Position, subject, "联合主键的模型不能使用 @authenticatable，登录需要单个字段的主键")
+		}
+		if diagnostics.Err() == nil {
+			message.PrimaryKey = columns[0]
+			message.PrimaryKeys = columns
+		}
+		return diagnostics.Err()
+	}
+
+	message.PrimaryKeys = []string{message.PrimaryKey}
+	field, exists := fields[message.PrimaryKey]
+	if !exists || !field.Annotations.Has("pk") {
+		return nil
+	}
+
+	annotation := field.Annotations.Get("pk")
+	switch args := annotation.Values(); {
+	case len(args) > 1:
+		diagnostics.Errorf(annotation.Position, subject, "字段上的 @pk 只能指定一个生成策略，联合主键请在消息上声明")
+	case len(args) == 1:
+		strategy, exists := keyStrategies[args[0]]
+		if !exists {
+			diagnostics.Errorf(annotation.Position, subject, "未知的主键生成策略 %s，可选值：uuid、ulid、snowflake", args[0])
+		} else if field.Repeated || !slices.Contains(strategy.Types, field.Type) {
+			diagnostics.Errorf(field.Position, subject, "@pk:%s 只能用于 %s 类型的字段", args[0], strings.Join(strategy.Types, "、"))
+		} else {
+			message.KeyStrategy = args[0]
+		}
+	}
+	return diagnostics.Err()
+}
+
+// PrimaryKeyField 主键对应的字段，联合主键时为第一个字段
+func (message *Message) PrimaryKeyField() *Field {
+	for _, field := range message.Fields {
+		if field.JSONName == message.PrimaryKey {
+			return field
+		}
+	}
+	return nil
+}
+
+// CompositeKey 是否为联合主键
+func (message *Message) CompositeKey() bool {
+	return len(message.PrimaryKeys) > 1
+}
+
+// IsPrimaryKey 字段是否为主键或者联合主键的一部分
+func (field *Field) IsPrimaryKey() bool {
+	return field.Parent != nil && slices.Contains(field.Parent.PrimaryKeys, field.JSONName)
+}
+
+// keyConstraint 主键字段的 db 约束，联合主键在建表语句中单独声明
+func (field *Field) keyConstraint(dialect string) string {
+	if field.Parent == nil || field.Parent.PrimaryKeys == nil {
+		// 非模型的消息沿用第一个字段或者 @pk 字段作为主键
+		if field.Annotations.Has("pk") || (field.Parent != nil && !field.Parent.HasAnnotation("pk") && field.Index == 0) {
+			return "primary key;" + autoIncrement(dialect)
+		}
+		return ""
+	}
+	switch {
+	case field.Parent.CompositeKey() || !field.IsPrimaryKey():
+		return ""
+	case field.Parent.KeyStrategy != "":
+		return "primary key;"
+	}
+	return "primary key;" + autoIncrement(dialect)
+}
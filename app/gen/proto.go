@@ -3,95 +3,180 @@ package gen
 import (
 	"fmt"
 	"github.com/emicklei/proto"
-	"log"
 	"os"
 	"path/filepath"
 	"runtime"
+	"text/scanner"
 	"time"
 )
 
-// 自定义头部注释
+// Version goal-cli 的版本号，会写入生成文件的头部以及清单文件
+const Version = "v0.5.24"
 
-func Pro(protoFile, tmplFile, outputDir string) {
-	var headerComment = fmt.Sprintf(`// Code generated by goal-cli. DO NOT EDIT.
+// HeaderComment 自定义头部注释
+func HeaderComment(protoFile string) string {
+	return fmt.Sprintf(`// Code generated by goal-cli. DO NOT EDIT.
 // versions:
-// 	goal-cli v0.5.24
+// 	goal-cli %s
 // 	go       %s
 //
 // updated_at: %s
 // source: %s
-`, runtime.Version(), time.Now().Format("2006-01-02 15:04:05"), protoFile)
+`, Version, runtime.Version(), time.Now().Format("2006-01-02 15:04:05"), protoFile)
+}
 
-	// 确保 outputDir 是绝对路径
-	outputDirAbs, err := filepath.Abs(outputDir)
-	if err != nil {
-		log.Fatal(err)
+// Generate 增量生成，跳过 proto 文件、引用的 proto 文件以及模板都没有变化的文件
+func (g *Generator) Generate(protoFiles []string) error {
+	var diagnostics Diagnostics
+
+	manifest, err := LoadManifest(g.outputDirAbs)
+	diagnostics.Add(err)
+	previousOutputs, previousRouters := manifest.Ownership()
+
+	// 并发解析全部 proto 文件，之后计算 hash 和提取数据都不会重复解析
+	diagnostics.Add(g.parseAll(protoFiles))
+
+	var staleFiles []string
+	entries := make(map[string]*ManifestEntry)
+	for _, protoFile := range protoFiles {
+		entry, err := g.NewManifestEntry(protoFile)
+		if err == nil && !g.config.Force && manifest.Fresh(protoFile, entry, g.outputDirAbs) {
+			fmt.Printf("proto 文件没有变化，跳过: %s\n", protoFile)
+			continue
+		}
+		if err != nil {
+			// 有错误的 proto 文件下次需要重新生成
+			diagnostics.Add(err)
+			manifest.Invalidate(protoFile)
+		} else {
+			entries[protoFile] = entry
+		}
+		staleFiles = append(staleFiles, protoFile)
 	}
 
-	// 读取模块名和模块根目录
-	moduleName, _, err := GetModuleNameAndDir(outputDirAbs)
-	if err != nil {
-		log.Fatalf("无法读取模块名：%v", err)
+	outputs, errs := g.renderAll(staleFiles, g.renderProto)
+	for i, protoFile := range staleFiles {
+		fmt.Printf("正在处理 proto 文件: %s\n", protoFile)
+		diagnostics.Add(errs[i])
+		output := outputs[i]
+		if output == nil {
+			manifest.Invalidate(protoFile)
+			continue
+		}
+
+		// 路由注册会修改同一个 kernel 文件，写入必须串行
+		err = WriteFiles(output.Files)
+		diagnostics.Add(err)
+		routerErr := GenRouters(g.outputDirAbs, output.Routers)
+		diagnostics.Add(routerErr)
+		fmt.Println("代码生成完成。")
+
+		// 只有警告的 proto 文件照常记录，下次没有变化时可以跳过
+		entry := entries[protoFile]
+		if HasErrors(errs[i]) || err != nil || routerErr != nil || entry == nil {
+			manifest.Invalidate(protoFile)
+			continue
+		}
+		entry.SetOutputs(g.outputDirAbs, output)
+		manifest.Record(protoFile, entry)
+	}
+
+	// 已经删除的 proto 文件生成的文件和路由都会成为孤立的产物
+	manifest.Retain(protoFiles)
+	manifest.CollectOrphans(previousOutputs, previousRouters)
+	if g.config.Prune {
+		diagnostics.Add(Prune(g.outputDirAbs, manifest))
+	} else if manifest.Orphans != nil {
+		fmt.Printf("发现 %d 个不再生成的文件、%d 个失效的路由注册，可以使用 --prune 删除\n",
+			len(manifest.Orphans.Outputs), len(manifest.Orphans.Routers))
 	}
-	fmt.Printf("读取到的模块名：%s\n", moduleName)
+	diagnostics.Add(manifest.Save(g.outputDirAbs))
 
-	// 初始化模板，并添加函数映射
-	tmpl := GetTemplate(tmplFile)
+	return diagnostics.Err()
+}
 
-	definition := ParseProto(protoFile)
-	basePackage := filepath.Join(moduleName, outputDir)
-	pwd, err := os.Getwd()
-	if err != nil {
-		log.Fatalf("无法读取当前目录：%v", err)
+// Render 在内存中渲染并格式化 proto 文件对应的全部代码，不会写入磁盘，结果与 protoFiles 一一对应
+func (g *Generator) Render(protoFiles []string) ([]*Output, error) {
+	var diagnostics Diagnostics
+	outputs, errs := g.renderAll(protoFiles, g.renderProto)
+	for _, err := range errs {
+		diagnostics.Add(err)
 	}
-	// 提取数据
-	data := ExtractProto(pwd, definition, basePackage, filepath.Dir(protoFile))
+	return outputs, diagnostics.Err()
+}
+
+// renderAll 先按照拓扑顺序提取全部 proto 文件，注册表完整之后再使用 worker pool 并发渲染
+func (g *Generator) renderAll(protoFiles []string, render func(protoFile string, data *Proto) (*Output, error)) ([]*Output, []error) {
+	outputs := make([]*Output, len(protoFiles))
+	// 并发解析，解析失败的文件会在构建引用图时返回同样的错误
+	_ = g.parseAll(protoFiles)
 
-	// 更新输出目录
+	// 提取数据
+	dataList, errs := g.extractAll(protoFiles)
+
+	parallel(g.config.Workers, len(protoFiles), func(i int) {
+		if dataList[i] == nil {
+			return
+		}
+		var diagnostics Diagnostics
+		diagnostics.Add(errs[i])
+		output, err := render(protoFiles[i], dataList[i])
+		diagnostics.Add(err)
+		outputs[i], errs[i] = output, diagnostics.Err()
+	})
+
+	return outputs, errs
+}
 
-	var files []string
+// renderProto 渲染单个 proto 文件的 go 代码
+func (g *Generator) renderProto(protoFile string, data *Proto) (*Output, error) {
+	var diagnostics Diagnostics
+	var output Output
 
 	for _, messages := range data.Messages {
-		files = append(files, GenMessages(tmpl, outputDirAbs, messages)...)
+		messageFiles, err := g.GenMessages(g.outputDirAbs, messages)
+		diagnostics.Add(err)
+		output.Files = append(output.Files, messageFiles...)
 	}
 
 	// 生成服务代码
 	for _, service := range data.Services {
-		files = append(files, GenServices(outputDirAbs, basePackage, tmpl, service.List)...)
-
-		GenRouters(outputDirAbs, service.List)
+		serviceFiles, err := g.GenServices(g.outputDirAbs, service.List)
+		diagnostics.Add(err)
+		output.Files = append(output.Files, serviceFiles...)
+		output.Routers = append(output.Routers, CollectRouters(service.List)...)
 	}
 
-	files = append(files, GenEnums(outputDirAbs, tmpl, data.Enums)...)
+	enumFiles, err := g.GenEnums(g.outputDirAbs, data.Enums)
+	diagnostics.Add(err)
+	output.Files = append(output.Files, enumFiles...)
 
 	// 调用 AddHeaderAndFormatFiles 函数，传入文件列表和注释内容
-	err = AddHeaderAndFormatFiles(files, headerComment)
-	if err != nil {
-		fmt.Printf("Error processing files: %v\n", err)
-	}
+	diagnostics.Add(AddHeaderAndFormatFiles(output.Files, HeaderComment(protoFile)))
 
-	fmt.Println("代码生成完成。")
+	return &output, diagnostics.Err()
 }
 
-func ParseProto(protoFile string) *proto.Proto {
+func ParseProto(protoFile string) (*proto.Proto, error) {
 	// 读取 proto 文件
 	protoFilePath, err := filepath.Abs(protoFile)
 	if err != nil {
-		log.Fatal(err)
+		return nil, NewDiagnostic(scanner.Position{Filename: protoFile}, "", err)
 	}
 
 	// 解析 proto 文件
 	reader, err := os.Open(protoFilePath)
 	if err != nil {
-		log.Fatal(err)
+		return nil, NewDiagnostic(scanner.Position{Filename: protoFile}, "", err)
 	}
 	defer reader.Close()
 
 	parser := proto.NewParser(reader)
+	parser.Filename(protoFile)
 	definition, err := parser.Parse()
 	if err != nil {
-		log.Fatal(err)
+		return nil, parseErrorDiagnostic(protoFile, err)
 	}
 	definition.Filename = protoFile
-	return definition
+	return definition, nil
 }
@@ -0,0 +1,82 @@
+package gen
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/scanner"
+)
+
+// Prune 删除清单中记录的孤立文件，并从 controllers/kernel.go 中删除失效的路由注册
+func Prune(outputDirAbs string, manifest *Manifest) error {
+	if manifest.Orphans == nil {
+		fmt.Println("没有需要清理的文件。")
+		return nil
+	}
+
+	var diagnostics Diagnostics
+	var remains ManifestOrphans
+
+	for _, output := range manifest.Orphans.Outputs {
+		path := filepath.Join(outputDirAbs, output)
+		content, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			diagnostics.Add(NewDiagnostic(scanner.Position{Filename: path}, "prune", err))
+			remains.Outputs = append(remains.Outputs, output)
+			continue
+		}
+
+		// 只删除带有 goal-cli 生成标记的文件，与 --check 一致，没有标记的文件可能是手写或者手动修改过的
+		if !bytes.HasPrefix(content, []byte(generatedMarker)) {
+			warning := NewDiagnostic(scanner.Position{Filename: path}, "prune", fmt.Errorf("文件不是由 goal-cli 生成的，已跳过"))
+			warning.Severity = SeverityWarning
+			diagnostics.Add(warning)
+			continue
+		}
+
+		if err = os.Remove(path); err != nil {
+			diagnostics.Add(NewDiagnostic(scanner.Position{Filename: path}, "prune", err))
+			remains.Outputs = append(remains.Outputs, output)
+			continue
+		}
+		fmt.Printf("删除孤立文件：%s\n", path)
+	}
+
+	if len(manifest.Orphans.Routers) > 0 {
+		kernel := KernelPath(outputDirAbs)
+		content, err := os.ReadFile(kernel)
+		if err != nil && !os.IsNotExist(err) {
+			diagnostics.Add(NewDiagnostic(scanner.Position{Filename: kernel}, "prune", err))
+			remains.Routers = manifest.Orphans.Routers
+		} else if err == nil {
+			modified := false
+			for _, router := range manifest.Orphans.Routers {
+				newContent, removed, err := RemoveRouter(kernel, content, router.ImportPath, router.UsageName)
+				if err != nil {
+					diagnostics.Add(err)
+					remains.Routers = append(remains.Routers, router)
+					continue
+				}
+				content = newContent
+				modified = modified || removed
+			}
+			if modified {
+				if err = os.WriteFile(kernel, content, 0644); err != nil {
+					diagnostics.Add(NewDiagnostic(scanner.Position{Filename: kernel}, "prune", err))
+					remains.Routers = manifest.Orphans.Routers
+				} else {
+					fmt.Printf("删除失效的路由注册：%s\n", kernel)
+				}
+			}
+		}
+	}
+
+	manifest.Orphans = nil
+	if len(remains.Outputs)+len(remains.Routers) > 0 {
+		manifest.Orphans = &remains
+	}
+	return diagnostics.Err()
+}
@@ -7,46 +7,42 @@ import (
 	"go/format"
 	"go/parser"
 	"go/token"
-	"os"
 	"strings"
+	"text/scanner"
 )
 
 // AddHeaderAndFormatFiles 给文件数组中的每个文件添加头部注释、移除未使用的引用，并格式化代码
 // 参数:
-// - files: 文件路径数组，表示需要处理的文件列表
+// - files: 渲染到内存中的文件列表，处理结果会直接写回 File.Content
 // - headerComment: 需要添加的文件头部注释内容
-func AddHeaderAndFormatFiles(files []string, headerComment string) error {
+func AddHeaderAndFormatFiles(files []*File, headerComment string) error {
+	var diagnostics Diagnostics
 	for _, file := range files {
-		// 检查文件是否存在
-		if _, err := os.Stat(file); os.IsNotExist(err) {
-			fmt.Printf("File does not exist: %s\n", file)
-			continue
-		}
-
 		// 检查是否为 Go 文件
-		if !strings.HasSuffix(file, ".go") {
-			fmt.Printf("Skipping non-Go file: %s\n", file)
+		if !strings.HasSuffix(file.Path, ".go") {
+			fmt.Printf("Skipping non-Go file: %s\n", file.Path)
 			continue
 		}
 
 		// 格式化并添加注释
-		err := addHeaderAndFormat(file, headerComment)
+		content, err := addHeaderAndFormat(file.Path, file.Content, headerComment)
 		if err != nil {
-			fmt.Printf("Failed to process file %s: %v\n", file, err)
-			return err
+			diagnostics.Add(NewDiagnostic(scanner.Position{Filename: file.Path}, "format", err))
+			continue
 		}
+		file.Content = content
 	}
 
-	return nil
+	return diagnostics.Err()
 }
 
-// addHeaderAndFormat 格式化指定的 Go 文件，并在文件头部添加指定注释，移除未使用的 import
-func addHeaderAndFormat(filename, headerComment string) error {
+// addHeaderAndFormat 格式化指定的 Go 源码，并在头部添加指定注释，移除未使用的 import
+func addHeaderAndFormat(filename string, src []byte, headerComment string) ([]byte, error) {
 	// 解析源文件
 	fileSet := token.NewFileSet()
-	node, err := parser.ParseFile(fileSet, filename, nil, parser.ParseComments)
+	node, err := parser.ParseFile(fileSet, filename, src, parser.ParseComments)
 	if err != nil {
-		return fmt.Errorf("failed to parse file: %v", err)
+		return nil, fmt.Errorf("failed to parse file: %v", err)
 	}
 
 	// 格式化 headerComment
@@ -58,16 +54,10 @@ func addHeaderAndFormat(filename, headerComment string) error {
 	// 格式化 AST 并输出到缓冲区
 	var buf bytes.Buffer
 	if err := format.Node(&buf, fileSet, node); err != nil {
-		return fmt.Errorf("failed to format code: %v", err)
-	}
-
-	// 将格式化后的代码写回文件
-	if err := os.WriteFile(filename, []byte(fmt.Sprintf("%s\n%s", headerComment, buf.Bytes())), 0644); err != nil {
-		return fmt.Errorf("failed to write formatted code to file: %v", err)
+		return nil, fmt.Errorf("failed to format code: %v", err)
 	}
-	fmt.Printf("File formatted and updated successfully: %s\n", filename)
 
-	return nil
+	return []byte(fmt.Sprintf("%s\n%s", headerComment, buf.Bytes())), nil
 }
 
 // removeUnusedImports 移除未使用的 import 语句
@@ -1,21 +1,25 @@
 package gen
 
 import (
+	"bytes"
 	"fmt"
 	"go/ast"
 	"go/parser"
 	"go/printer"
 	"go/token"
 	"os"
+	"path/filepath"
 	"strings"
+	"text/scanner"
 )
 
 type RouterCollector struct {
-	ImportPath string
-	UsageName  string
+	ImportPath string `json:"import_path"`
+	UsageName  string `json:"usage_name"`
 }
 
-func GenRouters(output string, services []*Service) {
+// CollectRouters 收集控制器需要注册的路由，需要在 GenServices 之后调用
+func CollectRouters(services []*Service) []*RouterCollector {
 	var routers []*RouterCollector
 	for _, service := range services {
 		if service.Controller {
@@ -25,26 +29,71 @@ func GenRouters(output string, services []*Service) {
 			})
 		}
 	}
+	return routers
+}
+
+// KernelPath 路由注册文件的路径
+func KernelPath(output string) string {
+	return strings.Join([]string{output, "controllers", "kernel.go"}, "/")
+}
 
+func GenRouters(output string, routers []*RouterCollector) error {
+	var diagnostics Diagnostics
 	for _, router := range routers {
-		GenRouter(strings.Join([]string{output, "controllers", "kernel.go"}, "/"), router.ImportPath, router.UsageName)
+		diagnostics.Add(GenRouter(KernelPath(output), router.ImportPath, router.UsageName))
+	}
+	return diagnostics.Err()
+}
+
+// ReadKernel 读取路由注册文件，文件不存在时返回初始内容
+func ReadKernel(filename string) ([]byte, error) {
+	src, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return []byte(initialKernelContent), nil
 	}
+	return src, err
 }
 
 // GenRouter 通过指定的 importPath 和 usage 动态修改指定 Go 文件中的路由注册函数
-func GenRouter(filename, importPath, usage string) {
+func GenRouter(filename, importPath, usage string) error {
 	// 检查并创建文件，如果文件不存在则创建
 	if _, err := os.Stat(filename); os.IsNotExist(err) {
 		fmt.Printf("File %s does not exist. Creating a new file...\n", filename)
-		createInitialFile(filename)
+		if err = createInitialFile(filename); err != nil {
+			return NewDiagnostic(scanner.Position{Filename: filename}, "router "+usage, err)
+		}
+	}
+
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		return NewDiagnostic(scanner.Position{Filename: filename}, "router "+usage, err)
+	}
+
+	content, modified, err := RenderRouter(filename, src, importPath, usage)
+	if err != nil {
+		return err
+	}
+
+	// 如果文件被修改，则写回文件
+	if modified {
+		fmt.Println("File modified, saving changes...")
+		if err = os.WriteFile(filename, content, 0644); err != nil {
+			return NewDiagnostic(scanner.Position{Filename: filename}, "router "+usage, err)
+		}
+		fmt.Println("File successfully updated.")
+	} else {
+		fmt.Println("No modifications made to the file.")
 	}
+	return nil
+}
 
+// RenderRouter 在内存中把路由调用插入到 Register 函数，返回新的源码以及是否有修改
+func RenderRouter(filename string, src []byte, importPath, usage string) ([]byte, bool, error) {
 	// 解析文件
 	fset := token.NewFileSet()
-	node, err := parser.ParseFile(fset, filename, nil, parser.ParseComments)
+	node, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
 	if err != nil {
-		fmt.Println("Error parsing file:", err)
-		return
+		return nil, false, NewDiagnostic(scanner.Position{Filename: filename}, "router "+usage, err)
 	}
 
 	// 获取现有的 import 别名和包路径
@@ -67,14 +116,15 @@ func GenRouter(filename, importPath, usage string) {
 
 	// 查找 Register 函数，并插入新的路由调用
 	modified := false
+	var callErr error
 	ast.Inspect(node, func(n ast.Node) bool {
 		if fn, ok := n.(*ast.FuncDecl); ok && fn.Name.Name == "Register" {
 			// 查找是否已经存在相同的调用，避免重复插入
 			for _, stmt := range fn.Body.List {
 				if exprStmt, ok := stmt.(*ast.ExprStmt); ok {
 					if call, ok := exprStmt.X.(*ast.CallExpr); ok {
-						// 格式化现有的调用，判断是否已存在
-						if formatNode(call, fset) == actualUsage {
+						// 格式化现有的调用，忽略空白字符判断是否已存在
+						if sameCall(formatNode(call, fset), actualUsage) {
 							fmt.Printf("Router call already exists: %s\n", actualUsage)
 							return false
 						}
@@ -84,31 +134,117 @@ func GenRouter(filename, importPath, usage string) {
 
 			// 插入新的调用语句
 			fmt.Printf("Inserting router call: %s\n", actualUsage)
-			newCallStmt := createRouterCallStmt(actualUsage)
+			newCallStmt, err := createRouterCallStmt(actualUsage)
+			if err != nil {
+				callErr = err
+				return false
+			}
 			fn.Body.List = append(fn.Body.List, newCallStmt)
 			modified = true
 		}
 		return true
 	})
 
-	// 如果文件被修改，则写回文件
-	if modified {
-		fmt.Println("File modified, saving changes...")
-		f, err := os.Create(filename)
-		if err != nil {
-			fmt.Println("Error creating file:", err)
-			return
+	if callErr != nil {
+		return nil, false, NewDiagnostic(scanner.Position{Filename: filename}, "router "+usage, callErr)
+	}
+
+	if !modified {
+		return src, false, nil
+	}
+
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fset, node); err != nil {
+		return nil, false, NewDiagnostic(scanner.Position{Filename: filename}, "router "+usage, err)
+	}
+	return buf.Bytes(), true, nil
+}
+
+// RemoveRouter 在内存中删除 Register 函数里的路由调用，import 不再被使用时一并删除
+func RemoveRouter(filename string, src []byte, importPath, usage string) ([]byte, bool, error) {
+	fset := token.NewFileSet()
+	node, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		return nil, false, NewDiagnostic(scanner.Position{Filename: filename}, "router "+usage, err)
+	}
+
+	alias, exists := getImportAliases(node)[importPath]
+	if !exists {
+		return src, false, nil
+	}
+	actualUsage := strings.Replace(usage, strings.Split(usage, ".")[0], alias, 1)
+
+	modified := false
+	for _, decl := range node.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Name.Name == "Register" && fn.Body != nil {
+			var list []ast.Stmt
+			for _, stmt := range fn.Body.List {
+				if exprStmt, ok := stmt.(*ast.ExprStmt); ok {
+					if call, ok := exprStmt.X.(*ast.CallExpr); ok && sameCall(formatNode(call, fset), actualUsage) {
+						fmt.Printf("Removing router call: %s\n", actualUsage)
+						modified = true
+						continue
+					}
+				}
+				list = append(list, stmt)
+			}
+			fn.Body.List = list
 		}
-		defer f.Close()
+	}
 
-		if err := printer.Fprint(f, fset, node); err != nil {
-			fmt.Println("Error printing file:", err)
-		} else {
-			fmt.Println("File successfully updated.")
+	if !modified {
+		return src, false, nil
+	}
+
+	// 别名已经没有任何引用时删除对应的 import
+	used := false
+	ast.Inspect(node, func(n ast.Node) bool {
+		if selector, ok := n.(*ast.SelectorExpr); ok {
+			if ident, ok := selector.X.(*ast.Ident); ok && ident.Name == alias {
+				used = true
+			}
 		}
-	} else {
-		fmt.Println("No modifications made to the file.")
+		return !used
+	})
+	if !used {
+		removeImport(node, importPath)
+	}
+
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fset, node); err != nil {
+		return nil, false, NewDiagnostic(scanner.Position{Filename: filename}, "router "+usage, err)
 	}
+	return buf.Bytes(), true, nil
+}
+
+// removeImport 删除指定路径的 import 语句
+func removeImport(node *ast.File, importPath string) {
+	var imports []*ast.ImportSpec
+	for _, imp := range node.Imports {
+		if strings.Trim(imp.Path.Value, `"`) != importPath {
+			imports = append(imports, imp)
+		}
+	}
+	node.Imports = imports
+
+	for _, decl := range node.Decls {
+		if genDecl, ok := decl.(*ast.GenDecl); ok && genDecl.Tok == token.IMPORT {
+			var specs []ast.Spec
+			for _, spec := range genDecl.Specs {
+				if imp, ok := spec.(*ast.ImportSpec); ok && strings.Trim(imp.Path.Value, `"`) == importPath {
+					fmt.Printf("Removing import: %s\n", importPath)
+					continue
+				}
+				specs = append(specs, spec)
+			}
+			genDecl.Specs = specs
+		}
+	}
+}
+
+// sameCall 忽略空白字符比较两个调用表达式
+func sameCall(a, b string) bool {
+	return strings.Join(strings.Fields(a), "") == strings.Join(strings.Fields(b), "")
 }
 
 // 获取文件中现有的 import 语句及其别名
@@ -198,18 +334,15 @@ func formatNode(node ast.Node, fset *token.FileSet) string {
 }
 
 // 创建新的 router 调用语句
-func createRouterCallStmt(usage string) ast.Stmt {
+func createRouterCallStmt(usage string) (ast.Stmt, error) {
 	expr, err := parser.ParseExpr(usage)
 	if err != nil {
-		fmt.Println("Error creating new call expression:", err)
-		return nil
+		return nil, fmt.Errorf("无法解析路由调用 %s：%v", usage, err)
 	}
-	return &ast.ExprStmt{X: expr}
+	return &ast.ExprStmt{X: expr}, nil
 }
 
-// 创建初始 Go 文件的内容
-func createInitialFile(filename string) {
-	initialContent := `package controllers
+const initialKernelContent = `package controllers
 
 import (
 	"github.com/goal-web/contracts"
@@ -220,10 +353,15 @@ func Register(router contracts.HttpRouter) {
 	// 在这里添加您的路由注册逻辑
 }
 `
-	err := os.WriteFile(filename, []byte(initialContent), 0644)
-	if err != nil {
-		fmt.Printf("Error creating initial file: %v\n", err)
-	} else {
-		fmt.Printf("Initial file %s created successfully.\n", filename)
+
+// 创建初始 Go 文件的内容
+func createInitialFile(filename string) error {
+	if err := os.MkdirAll(filepath.Dir(filename), os.ModePerm); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filename, []byte(initialKernelContent), 0644); err != nil {
+		return err
 	}
+	fmt.Printf("Initial file %s created successfully.\n", filename)
+	return nil
 }
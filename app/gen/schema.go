@@ -0,0 +1,519 @@
+package gen
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"text/scanner"
+)
+
+// SchemaFile 上一次生成迁移时的表结构快照，保存在迁移目录中，需要和迁移文件一起提交
+const SchemaFile = "schema.json"
+
+// Schema 全部模型对应的表结构
+type Schema struct {
+	Dialect string         `json:"dialect,omitempty"` // 生成表结构时的目标数据库，为空时为 mysql
+	Tables  []*TableSchema `json:"tables"`
+}
+
+// TableSchema 单个模型对应的表结构
+type TableSchema struct {
+	Name        string          `json:"name"`
+	Columns     []*ColumnSchema `json:"columns"`
+	Indexes     []*IndexSchema  `json:"indexes,omitempty"`
+	PrimaryKey  []string        `json:"primary_key,omitempty"` // @pk 声明的联合主键，单个字段的主键声明在字段的约束中
+	RenamedFrom string          `json:"-"`                     // @renamedFrom 声明的旧表名，只在本次对比时使用
+}
+
+// ColumnSchema 字段的 db tag，与 migrate.Migrate 解析 tag 的方式一致
+type ColumnSchema struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Constraints string `json:"constraints,omitempty"`
+	AutoUpdate  bool   `json:"auto_update,omitempty"` // 更新记录时刷新为当前时间，mysql 通过 ON UPDATE 声明在约束中，其他数据库需要创建触发器
+	RenamedFrom string `json:"-"`
+}
+
+// IndexSchema @index、@unique 声明的索引
+type IndexSchema struct {
+	Name    string `json:"name"`
+	Unique  bool   `json:"unique,omitempty"`
+	Columns string `json:"columns"` // 例如 (name,email)
+}
+
+// LoadSchema 读取迁移目录中的快照，文件不存在时返回空的表结构
+func LoadSchema(dir string) (*Schema, error) {
+	schema := &Schema{}
+	path := filepath.Join(dir, SchemaFile)
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return schema, nil
+	} else if err != nil {
+		return schema, NewDiagnostic(scanner.Position{Filename: path}, "schema", err)
+	}
+	if err = json.Unmarshal(content, schema); err != nil {
+		return schema, NewDiagnostic(scanner.Position{Filename: path}, "schema", fmt.Errorf("快照文件已损坏：%v", err))
+	}
+	return schema, nil
+}
+
+// Save 把快照写入迁移目录
+func (schema *Schema) Save(dir string) error {
+	content, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, SchemaFile), append(content, '\n'), 0644)
+}
+
+// Table 按名称查找表，不存在时返回 nil
+func (schema *Schema) Table(name string) *TableSchema {
+	for _, table := range schema.Tables {
+		if table.Name == name {
+			return table
+		}
+	}
+	return nil
+}
+
+// Column 按名称查找字段，不存在时返回 nil
+func (table *TableSchema) Column(name string) *ColumnSchema {
+	for _, column := range table.Columns {
+		if column.Name == name {
+			return column
+		}
+	}
+	return nil
+}
+
+// Index 按名称查找索引，不存在时返回 nil
+func (table *TableSchema) Index(name string) *IndexSchema {
+	for _, index := range table.Indexes {
+		if index.Name == name {
+			return index
+		}
+	}
+	return nil
+}
+
+// Schema 根据 proto 中的模型生成表结构，字段与模型的 db tag 一致，索引与模型的 Indexes 一致
+func (g *Generator) Schema(protoFiles []string) (*Schema, error) {
+	var diagnostics Diagnostics
+	schema := &Schema{}
+	if g.config.Dialect != DialectMySQL {
+		schema.Dialect = g.config.Dialect
+	}
+
+	dataList, errs := g.extractAll(protoFiles)
+	for i := range protoFiles {
+		diagnostics.Add(errs[i])
+		if dataList[i] == nil {
+			continue
+		}
+		for _, message := range dataList[i].Messages["models"] {
+			if schema.Table(message.TableName) != nil {
+				continue
+			}
+			schema.Tables = append(schema.Tables, g.tableSchema(message))
+		}
+	}
+
+	sort.Slice(schema.Tables, func(i, j int) bool {
+		return schema.Tables[i].Name < schema.Tables[j].Name
+	})
+	return schema, diagnostics.Err()
+}
+
+func (g *Generator) tableSchema(message *Message) *TableSchema {
+	table := &TableSchema{
+		Name:        message.TableName,
+		RenamedFrom: message.Annotations.Value("renamedFrom", ""),
+	}
+
+	var updatedAt string
+	if message.Annotations.Has("timestamps") && g.config.Dialect != DialectMySQL {
+		updatedAt = message.Annotations.Arg("timestamps", 1, "updated_at")
+	}
+	for _, field := range message.Fields {
+		if !g.IsColumn(field) {
+			continue
+		}
+		db := reflect.StructTag(g.ToTags(field)).Get("db")
+		column := &ColumnSchema{
+			AutoUpdate:  field.JSONName == updatedAt,
+			RenamedFrom: field.Annotations.Value("renamedFrom", ""),
+		}
+		var constraints []string
+		for i, tag := range strings.Split(db, ";") {
+			switch {
+			case i == 0:
+				column.Name = tag
+			case strings.HasPrefix(tag, "type:"):
+				column.Type = strings.TrimPrefix(tag, "type:")
+			case tag != "":
+				constraints = append(constraints, tag)
+			}
+		}
+		column.Constraints = strings.Join(constraints, " ")
+		table.Columns = append(table.Columns, column)
+	}
+
+	for _, field := range message.Fields {
+		for _, name := range []string{"index", "unique"} {
+			if field.Annotations.Has(name) {
+				table.Indexes = append(table.Indexes, &IndexSchema{
+					Name:    field.Annotations.Arg(name, 0, field.JSONName+"_idx"),
+					Unique:  name == "unique",
+					Columns: strings.ReplaceAll(field.Annotations.Arg(name, 1, "("+field.JSONName+")"), ";", ","),
+				})
+			}
+		}
+	}
+	if message.CompositeKey() {
+		table.PrimaryKey = message.PrimaryKeys
+	}
+	return table
+}
+
+// CreateTable 模型的建表语句，生成的 Migrator 在表不存在时执行
+func (g *Generator) CreateTable(message *Message) []string {
+	diff := &SchemaDiff{dialect: g.config.Dialect}
+	return diff.createTable(g.tableSchema(message))
+}
+
+// primaryKey 表的主键字段，没有联合主键时为约束中声明了 primary key 的字段
+func (table *TableSchema) primaryKey() []string {
+	if len(table.PrimaryKey) > 0 {
+		return table.PrimaryKey
+	}
+	var columns []string
+	for _, column := range table.Columns {
+		if strings.Contains(strings.ToLower(column.Constraints), "primary key") {
+			columns = append(columns, column.Name)
+		}
+	}
+	return columns
+}
+
+// SchemaDiff 两份表结构之间的迁移语句，Down 按照与 Up 相反的顺序撤销
+type SchemaDiff struct {
+	Up         []string
+	Down       []string
+	Migrations []*Migration // 逐条对应的迁移语句，migrate 每个文件只执行一次 Exec，每条语句需要单独保存为一个文件
+	Warnings   Diagnostics  // 当前数据库无法通过迁移语句完成的修改，需要手动处理
+	dialect    string
+}
+
+// Migration 一条迁移语句以及撤销它的语句
+type Migration struct {
+	Up   string
+	Down string
+}
+
+func (diff *SchemaDiff) add(up, down string) {
+	diff.addGroup([]string{up}, []string{down})
+}
+
+// addGroup 一组需要按顺序执行的语句，例如建表之后再创建索引，down 中的语句按照相反的顺序逐条撤销 up 中的语句
+func (diff *SchemaDiff) addGroup(up, down []string) {
+	if len(up) != len(down) {
+		panic(fmt.Sprintf("迁移语句与撤销语句的数量不一致：%d != %d", len(up), len(down)))
+	}
+	diff.Up = append(diff.Up, up...)
+	diff.Down = append(slices.Clone(down), diff.Down...)
+	for i, statement := range up {
+		diff.Migrations = append(diff.Migrations, &Migration{Up: statement, Down: down[len(down)-1-i]})
+	}
+}
+
+// warn 同一个对象在 Up 和 Down 中只提示一次
+func (diff *SchemaDiff) warn(subject, format string, args ...any) {
+	diagnostic := NewDiagnostic(scanner.Position{}, subject, fmt.Errorf(format, args...))
+	diagnostic.Severity = SeverityWarning
+	for _, exists := range diff.Warnings {
+		if exists.Error() == diagnostic.Error() {
+			return
+		}
+	}
+	diff.Warnings = append(diff.Warnings, diagnostic)
+}
+
+// Empty 表结构是否没有变化
+func (diff *SchemaDiff) Empty() bool {
+	return len(diff.Up) == 0
+}
+
+// DiffSchema 对比上一次的快照和当前的表结构，@renamedFrom 声明的表和字段生成 RENAME 语句而不是删除再创建，
+// 语句按照当前表结构的数据库生成，快照与当前表结构的数据库不一致时返回错误
+func DiffSchema(previous, current *Schema) (*SchemaDiff, error) {
+	diff := &SchemaDiff{dialect: DialectOf(current.Dialect)}
+	if dialect := DialectOf(previous.Dialect); len(previous.Tables) > 0 && dialect != diff.dialect {
+		return diff, NewDiagnostic(scanner.Position{Filename: SchemaFile}, "schema",
+			fmt.Errorf("快照是按照 %s 生成的，无法对比生成 %s 的迁移，切换数据库时请使用新的迁移目录", dialect, diff.dialect))
+	}
+	matched := map[string]bool{}
+
+	for _, table := range current.Tables {
+		old := previous.Table(table.Name)
+		if old == nil && table.RenamedFrom != "" && current.Table(table.RenamedFrom) == nil {
+			if old = previous.Table(table.RenamedFrom); old != nil {
+				diff.add(diff.renameTable(old.Name, table.Name), diff.renameTable(table.Name, old.Name))
+			}
+		}
+		if old == nil {
+			diff.addGroup(diff.createTable(table), diff.dropTable(table))
+			continue
+		}
+		matched[old.Name] = true
+		diff.diffTable(old, table)
+	}
+
+	for _, table := range previous.Tables {
+		if matched[table.Name] {
+			continue
+		}
+		diff.addGroup(diff.dropTable(table), diff.createTable(table))
+	}
+	return diff, nil
+}
+
+func (diff *SchemaDiff) diffTable(old, table *TableSchema) {
+	// 先删除旧的索引，字段修改之后再创建新的索引
+	for _, index := range old.Indexes {
+		if current := table.Index(index.Name); current == nil || *current != *index {
+			diff.addGroup(diff.dropIndex(table.Name, index), diff.createIndex(table.Name, index))
+		}
+	}
+
+	matched := map[string]bool{}
+	for _, column := range table.Columns {
+		oldColumn := old.Column(column.Name)
+		if oldColumn == nil && column.RenamedFrom != "" && table.Column(column.RenamedFrom) == nil {
+			if oldColumn = old.Column(column.RenamedFrom); oldColumn != nil {
+				diff.add(
+					fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s", diff.quote(table.Name), diff.quote(oldColumn.Name), diff.quote(column.Name)),
+					fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s", diff.quote(table.Name), diff.quote(column.Name), diff.quote(oldColumn.Name)),
+				)
+			}
+		}
+		if oldColumn == nil {
+			diff.addGroup(diff.addColumn(table.Name, column), diff.dropColumn(table.Name, column))
+			continue
+		}
+		matched[oldColumn.Name] = true
+		if oldColumn.Type != column.Type || oldColumn.Constraints != column.Constraints {
+			previous := &ColumnSchema{Name: column.Name, Type: oldColumn.Type, Constraints: oldColumn.Constraints}
+			diff.addGroup(diff.modifyColumn(table.Name, column), diff.modifyColumn(table.Name, previous))
+		}
+		if oldColumn.AutoUpdate != column.AutoUpdate {
+			up, down := autoUpdateStatements(diff.dialect, table.Name, column.Name)
+			if !column.AutoUpdate {
+				up, down = down, up
+			}
+			diff.addGroup(up, down)
+		}
+	}
+
+	for _, column := range old.Columns {
+		if !matched[column.Name] {
+			diff.addGroup(diff.dropColumn(table.Name, column), diff.addColumn(table.Name, column))
+		}
+	}
+
+	// 单个字段的主键随着字段一起修改，只需要处理涉及联合主键的变化
+	if (len(old.PrimaryKey) > 0 || len(table.PrimaryKey) > 0) && !slices.Equal(old.primaryKey(), table.primaryKey()) {
+		diff.addGroup(diff.changePrimaryKey(table.Name, table.primaryKey()), diff.changePrimaryKey(table.Name, old.primaryKey()))
+	}
+
+	for _, index := range table.Indexes {
+		if oldIndex := old.Index(index.Name); oldIndex == nil || *oldIndex != *index {
+			diff.addGroup(diff.createIndex(table.Name, index), diff.dropIndex(table.Name, index))
+		}
+	}
+}
+
+func (diff *SchemaDiff) quote(name string) string {
+	return quote(diff.dialect, name)
+}
+
+func (diff *SchemaDiff) columnDefinition(column *ColumnSchema) string {
+	return strings.TrimSpace(fmt.Sprintf("%s %s %s", diff.quote(column.Name), column.Type, column.Constraints))
+}
+
+func (diff *SchemaDiff) renameTable(from, to string) string {
+	if diff.dialect == DialectClickHouse {
+		return fmt.Sprintf("RENAME TABLE %s TO %s", diff.quote(from), diff.quote(to))
+	}
+	return fmt.Sprintf("ALTER TABLE %s RENAME TO %s", diff.quote(from), diff.quote(to))
+}
+
+// addColumn 添加字段以及刷新字段的触发器
+func (diff *SchemaDiff) addColumn(table string, column *ColumnSchema) []string {
+	statements := []string{fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", diff.quote(table), diff.columnDefinition(column))}
+	if column.AutoUpdate {
+		up, _ := autoUpdateStatements(diff.dialect, table, column.Name)
+		statements = append(statements, up...)
+	}
+	return statements
+}
+
+// dropColumn 先删除触发器再删除字段
+func (diff *SchemaDiff) dropColumn(table string, column *ColumnSchema) []string {
+	var statements []string
+	if column.AutoUpdate {
+		_, statements = autoUpdateStatements(diff.dialect, table, column.Name)
+	}
+	return append(statements, fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", diff.quote(table), diff.quote(column.Name)))
+}
+
+// modifyColumn 修改字段的类型、可空性以及默认值，主键约束保持不变
+func (diff *SchemaDiff) modifyColumn(table string, column *ColumnSchema) []string {
+	name := diff.quote(column.Name)
+	switch diff.dialect {
+	case DialectSQLite:
+		diff.warn("column "+table+"."+column.Name, "sqlite 不支持修改字段，需要手动重建表 %s", table)
+		return nil
+	case DialectPostgres:
+		actions := []string{fmt.Sprintf("ALTER COLUMN %s TYPE %s USING %s::%s", name, column.Type, name, column.Type)}
+		if column.nullable() {
+			actions = append(actions, fmt.Sprintf("ALTER COLUMN %s DROP NOT NULL", name))
+		} else {
+			actions = append(actions, fmt.Sprintf("ALTER COLUMN %s SET NOT NULL", name))
+		}
+		// 自增主键的默认值由 IDENTITY 维护
+		if value := columnDefault(column.Constraints); value != "" {
+			actions = append(actions, fmt.Sprintf("ALTER COLUMN %s SET DEFAULT %s", name, value))
+		} else if !strings.Contains(strings.ToUpper(column.Constraints), "IDENTITY") {
+			actions = append(actions, fmt.Sprintf("ALTER COLUMN %s DROP DEFAULT", name))
+		}
+		return []string{fmt.Sprintf("ALTER TABLE %s %s", diff.quote(table), strings.Join(actions, ", "))}
+	}
+	// MODIFY COLUMN 不能重复声明主键
+	constraints := slices.DeleteFunc(strings.Fields(column.Constraints), func(word string) bool {
+		return strings.EqualFold(word, "primary") || strings.EqualFold(word, "key")
+	})
+	return []string{fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s", diff.quote(table),
+		diff.columnDefinition(&ColumnSchema{Name: column.Name, Type: column.Type, Constraints: strings.Join(constraints, " ")}))}
+}
+
+// changePrimaryKey 删除原来的主键之后按照新的字段创建主键，sqlite 和 clickhouse 需要重建表
+func (diff *SchemaDiff) changePrimaryKey(table string, columns []string) []string {
+	switch diff.dialect {
+	case DialectSQLite, DialectClickHouse:
+		diff.warn("table "+table, "%s 不支持修改主键，需要手动重建表 %s", diff.dialect, table)
+		return nil
+	}
+	var drop string
+	if diff.dialect == DialectPostgres {
+		// postgres 建表时主键约束的默认名称
+		drop = "DROP CONSTRAINT " + diff.quote(table+"_pkey")
+	} else {
+		drop = "DROP PRIMARY KEY"
+	}
+	if len(columns) == 0 {
+		return []string{fmt.Sprintf("ALTER TABLE %s %s", diff.quote(table), drop)}
+	}
+	return []string{fmt.Sprintf("ALTER TABLE %s %s, ADD PRIMARY KEY %s", diff.quote(table), drop, diff.columnList(columns))}
+}
+
+func (diff *SchemaDiff) columnList(columns []string) string {
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = diff.quote(column)
+	}
+	return "(" + strings.Join(quoted, ", ") + ")"
+}
+
+// createTable 建表、创建索引以及触发器的语句
+func (diff *SchemaDiff) createTable(table *TableSchema) []string {
+	var columns []string
+	for _, column := range table.Columns {
+		if diff.dialect == DialectClickHouse {
+			// clickhouse 的主键通过 ORDER BY 声明
+			column = &ColumnSchema{Name: column.Name, Type: column.Type, Constraints: strings.TrimSpace(strings.ReplaceAll(column.Constraints, "primary key", ""))}
+		}
+		columns = append(columns, "  "+diff.columnDefinition(column))
+	}
+	if len(table.PrimaryKey) > 0 && diff.dialect != DialectClickHouse {
+		columns = append(columns, "  PRIMARY KEY "+diff.columnList(table.PrimaryKey))
+	}
+	statement := fmt.Sprintf("CREATE TABLE %s (\n%s\n)", diff.quote(table.Name), strings.Join(columns, ",\n"))
+	if diff.dialect == DialectClickHouse {
+		statement += diff.engine(table)
+	}
+
+	statements := []string{statement}
+	for _, index := range table.Indexes {
+		statements = append(statements, diff.createIndex(table.Name, index)...)
+	}
+	for _, column := range table.Columns {
+		if column.AutoUpdate {
+			up, _ := autoUpdateStatements(diff.dialect, table.Name, column.Name)
+			statements = append(statements, up...)
+		}
+	}
+	return statements
+}
+
+// engine clickhouse 按照主键排序，有自动更新的时间字段时使用 ReplacingMergeTree 保留最新的数据
+func (diff *SchemaDiff) engine(table *TableSchema) string {
+	engine, orderBy := "MergeTree", "tuple()"
+	for _, column := range table.Columns {
+		if strings.Contains(column.Constraints, "primary key") {
+			orderBy = "(" + column.Name + ")"
+		}
+		if column.AutoUpdate {
+			engine = "ReplacingMergeTree(" + column.Name + ")"
+		}
+	}
+	if len(table.PrimaryKey) > 0 {
+		orderBy = "(" + strings.Join(table.PrimaryKey, ", ") + ")"
+	}
+	return fmt.Sprintf(" ENGINE = %s ORDER BY %s", engine, orderBy)
+}
+
+// dropTable 按照与 createTable 相反的顺序删除触发器、索引以及表，每条语句都可以单独撤销
+func (diff *SchemaDiff) dropTable(table *TableSchema) []string {
+	var statements []string
+	for _, column := range slices.Backward(table.Columns) {
+		if column.AutoUpdate {
+			_, down := autoUpdateStatements(diff.dialect, table.Name, column.Name)
+			statements = append(statements, down...)
+		}
+	}
+	for _, index := range slices.Backward(table.Indexes) {
+		statements = append(statements, diff.dropIndex(table.Name, index)...)
+	}
+	return append(statements, fmt.Sprintf("DROP TABLE %s", diff.quote(table.Name)))
+}
+
+// createIndex clickhouse 没有普通索引和唯一索引，跳过并提示
+func (diff *SchemaDiff) createIndex(table string, index *IndexSchema) []string {
+	if diff.dialect == DialectClickHouse {
+		diff.warn("index "+table+"."+index.Name, "clickhouse 不支持普通索引和唯一索引，已跳过")
+		return nil
+	}
+	kind := "INDEX"
+	if index.Unique {
+		kind = "UNIQUE INDEX"
+	}
+	return []string{fmt.Sprintf("CREATE %s %s ON %s %s", kind, diff.quote(indexName(diff.dialect, table, index.Name)), diff.quote(table), index.Columns)}
+}
+
+func (diff *SchemaDiff) dropIndex(table string, index *IndexSchema) []string {
+	switch diff.dialect {
+	case DialectClickHouse:
+		return nil
+	case DialectPostgres, DialectSQLite:
+		return []string{fmt.Sprintf("DROP INDEX %s", diff.quote(indexName(diff.dialect, table, index.Name)))}
+	}
+	return []string{fmt.Sprintf("DROP INDEX %s ON %s", diff.quote(index.Name), diff.quote(table))}
+}
@@ -2,46 +2,59 @@ package gen
 
 import (
 	"fmt"
-	"log"
-	"os"
-	"path/filepath"
 )
 
-func SDK(protoFiles []string, tmplFile, outputDir string) {
-	var files []string
-	for _, protoFile := range protoFiles {
-		// 确保 outputDir 是绝对路径
-		outputDirAbs, err := filepath.Abs(outputDir)
-		if err != nil {
-			log.Fatal(err)
-		}
+func (g *Generator) SDK(protoFiles []string) error {
+	var diagnostics Diagnostics
 
-		// 初始化模板，并添加函数映射
-		tmpl := GetTemplate(tmplFile)
+	output, err := g.RenderSDK(protoFiles)
+	diagnostics.Add(err)
+	if output == nil {
+		return diagnostics.Err()
+	}
 
-		definition := ParseProto(protoFile)
-		basePackage := "@"
-		pwd, err := os.Getwd()
-		if err != nil {
-			log.Fatalf("无法读取当前目录：%v", err)
-		}
-		// 提取数据
-		data := ExtractProto(pwd, definition, basePackage, "", true)
+	diagnostics.Add(WriteFiles(output.Files))
+	return diagnostics.Err()
+}
 
-		// 更新输出目录
+// RenderSDK 在内存中渲染全部 proto 文件对应的 SDK 代码，不会写入磁盘
+func (g *Generator) RenderSDK(protoFiles []string) (*Output, error) {
+	var diagnostics Diagnostics
+	var output Output
 
-		for _, messages := range data.Messages {
-			files = append(files, SDKMessages(tmpl, outputDirAbs, messages)...)
+	outputs, errs := g.renderAll(protoFiles, g.renderSDKProto)
+	for i := range protoFiles {
+		diagnostics.Add(errs[i])
+		if outputs[i] != nil {
+			output.Files = append(output.Files, outputs[i].Files...)
 		}
+	}
 
-		// 生成服务代码
-		for _, service := range data.Services {
-			files = append(files, SDKServices(outputDirAbs, basePackage, tmpl, service.List)...)
-		}
+	return &output, diagnostics.Err()
+}
 
-		files = append(files, SDKEnums(outputDirAbs, tmpl, data.Enums)...)
+// renderSDKProto 渲染单个 proto 文件的 typescript 代码
+func (g *Generator) renderSDKProto(protoFile string, data *Proto) (*Output, error) {
+	var diagnostics Diagnostics
+	var output Output
 
-		fmt.Println("代码生成完成。", protoFile)
+	for _, messages := range data.Messages {
+		messageFiles, err := g.SDKMessages(g.outputDirAbs, messages)
+		diagnostics.Add(err)
+		output.Files = append(output.Files, messageFiles...)
 	}
 
+	// 生成服务代码
+	for _, service := range data.Services {
+		serviceFiles, err := g.SDKServices(g.outputDirAbs, service.List)
+		diagnostics.Add(err)
+		output.Files = append(output.Files, serviceFiles...)
+	}
+
+	enumFiles, err := g.SDKEnums(g.outputDirAbs, data.Enums)
+	diagnostics.Add(err)
+	output.Files = append(output.Files, enumFiles...)
+
+	fmt.Println("代码生成完成。", protoFile)
+	return &output, diagnostics.Err()
 }
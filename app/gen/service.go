@@ -3,11 +3,9 @@ package gen
 import (
 	"fmt"
 	"github.com/emicklei/proto"
-	"log"
-	"os"
 	"path/filepath"
 	"strings"
-	"text/template"
+	"text/scanner"
 )
 
 type ExtractServiceTemp struct {
@@ -17,8 +15,11 @@ type ExtractServiceTemp struct {
 }
 
 type Method struct {
+	Position            scanner.Position
 	Comment             *proto.Comment
+	Annotations         Annotations
 	Name                string
+	Input               *Message // 请求消息
 	InputImportPackage  string   // biz/request
 	OutputImportPackage string   // biz/models
 	InputUsageName      string   // 包含包名的完整类型，例如：requests.LoginRequest
@@ -29,7 +30,9 @@ type Method struct {
 }
 
 type Service struct {
+	Position    scanner.Position
 	Comment     *proto.Comment
+	Annotations Annotations
 	Name        string
 	Methods     []*Method
 	PackageName string // 包名，例如：auth
@@ -44,55 +47,60 @@ type Service struct {
 }
 
 // GenServices 生成 service 代码
-func GenServices(baseOutputDir, basePackage string, tmpl *template.Template, services []*Service) []string {
-	var files []string
+func (g *Generator) GenServices(baseOutputDir string, services []*Service) ([]*File, error) {
+	var diagnostics Diagnostics
+	var files []*File
 	for _, svc := range services {
-		files = append(files, GenService(baseOutputDir, basePackage, tmpl, svc, DetermineServiceImports(svc)))
-		fmt.Printf("生成服务文件：%s\n", filepath.Join(baseOutputDir, svc.Filename))
+		file, err := g.GenService(baseOutputDir, svc, g.DetermineServiceImports(svc))
+		if err != nil {
+			diagnostics.Add(err)
+		} else {
+			files = append(files, file)
+		}
 
 		if svc.Controller {
 			svc.Filename = strings.Replace(svc.Filename, "services", "controllers", 1)
 			svc.UsageName = strings.Replace(svc.UsageName, filepath.Base(svc.ImportPath), "svc", 1)
 			svc.Template = "controller"
-			files = append(files, GenService(baseOutputDir, basePackage, tmpl, svc, DetermineServiceImports(svc)))
-			fmt.Printf("生成控制器文件：%s\n", filepath.Join(baseOutputDir, svc.Filename))
+			file, err = g.GenService(baseOutputDir, svc, g.DetermineServiceImports(svc))
+			if err != nil {
+				diagnostics.Add(err)
+				continue
+			}
+			files = append(files, file)
 		}
 
 	}
-	return files
+	return files, diagnostics.Err()
 }
 
 // SDKServices 生成 service 代码
-func SDKServices(baseOutputDir, basePackage string, tmpl *template.Template, services []*Service) []string {
-	var files []string
+func (g *Generator) SDKServices(baseOutputDir string, services []*Service) ([]*File, error) {
+	var diagnostics Diagnostics
+	var files []*File
 	for _, svc := range services {
 
 		if svc.Controller {
 			svc.Filename = fmt.Sprintf("services/%s", filepath.Base(strings.ReplaceAll(svc.Filename, ".go", ".ts")))
 			svc.UsageName = strings.Replace(svc.UsageName, filepath.Base(svc.ImportPath), "svc", 1)
 			svc.Template = "controller"
-			files = append(files, GenService(baseOutputDir, basePackage, tmpl, svc, DetermineTsServiceImports(svc)))
-			fmt.Printf("生成控制器文件：%s\n", filepath.Join(baseOutputDir, svc.Filename))
+			file, err := g.GenService(baseOutputDir, svc, g.DetermineTsServiceImports(svc))
+			if err != nil {
+				diagnostics.Add(err)
+				continue
+			}
+			files = append(files, file)
 		}
 
 	}
-	return files
+	return files, diagnostics.Err()
 }
 
-func GenService(baseOutputDir, basePackage string, tmpl *template.Template, svc *Service, imports []Import) string {
+func (g *Generator) GenService(baseOutputDir string, svc *Service, imports []Import) (*File, error) {
 	outputPath := filepath.Join(baseOutputDir, svc.Filename)
-	err := os.MkdirAll(filepath.Dir(outputPath), os.ModePerm)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	outFile, err := os.Create(outputPath)
-	if err != nil {
-		log.Fatal(err)
-	}
 
 	// 执行模板，传入 moduleName、outputPackageName 和 imports
-	err = tmpl.ExecuteTemplate(outFile, svc.Template, map[string]interface{}{
+	file, err := renderFile(g.tmpl, svc.Template, outputPath, map[string]interface{}{
 		"Comment":      svc.Comment,
 		"Package":      svc.PackageName,
 		"ImportPath":   svc.ImportPath,
@@ -102,11 +110,10 @@ func GenService(baseOutputDir, basePackage string, tmpl *template.Template, svc
 		"Methods":      svc.Methods,
 		"Prefix":       svc.Prefix,
 		"Imports":      imports,
-		"ResponsePath": fmt.Sprintf("%s/response", basePackage),
+		"ResponsePath": fmt.Sprintf("%s/response", g.basePackage),
 	})
 	if err != nil {
-		log.Fatal(err)
+		return nil, NewDiagnostic(svc.Position, "service "+svc.Name, err)
 	}
-	outFile.Close()
-	return outputPath
+	return file, nil
 }
@@ -0,0 +1,264 @@
+package gen
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// SQLType 解析后的数据库类型，例如 bigint(20) unsigned 的 Base 为 bigint，Args 为 20
+type SQLType struct {
+	Raw      string // 去掉 Nullable、LowCardinality 之后的小写类型
+	Base     string
+	Args     string
+	Unsigned bool
+}
+
+var sqlTypeArgsRegexp = regexp.MustCompile(`\((.*)\)`)
+
+// ParseSQLType 解析数据库返回的原始类型
+func ParseSQLType(raw string) SQLType {
+	raw = strings.ToLower(strings.TrimSpace(raw))
+	for _, wrapper := range []string{"nullable(", "lowcardinality("} {
+		if strings.HasPrefix(raw, wrapper) {
+			return ParseSQLType(strings.TrimSuffix(strings.TrimPrefix(raw, wrapper), ")"))
+		}
+	}
+
+	sqlType := SQLType{Raw: raw}
+	if matches := sqlTypeArgsRegexp.FindStringSubmatch(raw); matches != nil {
+		sqlType.Args = matches[1]
+	}
+	var words []string
+	for _, word := range strings.Fields(sqlTypeArgsRegexp.ReplaceAllString(raw, " ")) {
+		switch word {
+		case "unsigned":
+			sqlType.Unsigned = true
+		case "zerofill", "signed":
+		default:
+			words = append(words, word)
+		}
+	}
+	sqlType.Base = strings.Join(words, " ")
+	return sqlType
+}
+
+// EnumValues mysql 的 enum('a','b') 中声明的值，不是 enum 时返回 nil
+func (sqlType SQLType) EnumValues() []string {
+	if sqlType.Base != "enum" {
+		return nil
+	}
+	// 值中可能包含逗号，按照单引号逐个读取，两个单引号表示转义
+	var values []string
+	var value strings.Builder
+	quoted := false
+	args := []rune(sqlType.Args)
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == '\'' && quoted && i+1 < len(args) && args[i+1] == '\'':
+			value.WriteRune('\'')
+			i++
+		case args[i] == '\'':
+			if quoted = !quoted; !quoted {
+				values = append(values, value.String())
+				value.Reset()
+			}
+		case quoted:
+			value.WriteRune(args[i])
+		}
+	}
+	return values
+}
+
+var mysqlProtoTypes = map[string]string{
+	"tinyint":    "int32",
+	"smallint":   "int32",
+	"mediumint":  "int32",
+	"int":        "int32",
+	"integer":    "int32",
+	"bigint":     "int64",
+	"bool":       "bool",
+	"boolean":    "bool",
+	"float":      "float",
+	"double":     "double",
+	"real":       "double",
+	"decimal":    "string", // 使用字符串避免丢失精度
+	"numeric":    "string",
+	"char":       "string",
+	"varchar":    "string",
+	"tinytext":   "string",
+	"text":       "string",
+	"mediumtext": "string",
+	"longtext":   "string",
+	"set":        "string",
+	"json":       "string",
+	"date":       "string",
+	"datetime":   "string",
+	"timestamp":  "string",
+	"time":       "string",
+	"year":       "int32",
+	"bit":        "bytes",
+	"binary":     "bytes",
+	"varbinary":  "bytes",
+	"tinyblob":   "bytes",
+	"blob":       "bytes",
+	"mediumblob": "bytes",
+	"longblob":   "bytes",
+}
+
+var postgresProtoTypes = map[string]string{
+	"smallint":                    "int32",
+	"integer":                     "int32",
+	"bigint":                      "int64",
+	"real":                        "float",
+	"double precision":            "double",
+	"numeric":                     "string",
+	"money":                       "string",
+	"boolean":                     "bool",
+	"character":                   "string",
+	"character varying":           "string",
+	"text":                        "string",
+	"citext":                      "string",
+	"uuid":                        "string",
+	"json":                        "string",
+	"jsonb":                       "string",
+	"xml":                         "string",
+	"inet":                        "string",
+	"cidr":                        "string",
+	"macaddr":                     "string",
+	"date":                        "string",
+	"time without time zone":      "string",
+	"time with time zone":         "string",
+	"timestamp without time zone": "string",
+	"timestamp with time zone":    "string",
+	"interval":                    "string",
+	"bytea":                       "bytes",
+}
+
+// sqlite 的类型可以随意声明，先按照常见的名称匹配，再按照 sqlite 的类型亲和性规则推导
+var sqliteProtoTypes = map[string]string{
+	"integer":   "int64", // sqlite 的整数都是 64 位
+	"boolean":   "bool",
+	"bool":      "bool",
+	"datetime":  "string",
+	"timestamp": "string",
+	"date":      "string",
+	"json":      "string",
+	"decimal":   "string",
+	"numeric":   "string",
+}
+
+var clickhouseProtoTypes = map[string]string{
+	"int8":        "int32",
+	"int16":       "int32",
+	"int32":       "int32",
+	"int64":       "int64",
+	"int128":      "string",
+	"int256":      "string",
+	"uint8":       "uint32",
+	"uint16":      "uint32",
+	"uint32":      "uint32",
+	"uint64":      "uint64",
+	"uint128":     "string",
+	"uint256":     "string",
+	"float32":     "float",
+	"float64":     "double",
+	"decimal":     "string",
+	"decimal32":   "string",
+	"decimal64":   "string",
+	"decimal128":  "string",
+	"decimal256":  "string",
+	"bool":        "bool",
+	"string":      "string",
+	"fixedstring": "string",
+	"uuid":        "string",
+	"date":        "string",
+	"date32":      "string",
+	"datetime":    "string",
+	"datetime64":  "string",
+	"ipv4":        "string",
+	"ipv6":        "string",
+	"enum8":       "string",
+	"enum16":      "string",
+	"json":        "string",
+}
+
+// ProtoTypes 数据库类型到 proto 类型的映射，项目中的自定义映射优先
+type ProtoTypes struct {
+	Dialect   string            // 连接的驱动名称：mysql、postgres、sqlite3、clickhouse
+	Overrides map[string]string // 小写的数据库类型，可以只写类型名，也可以带上长度和 unsigned，例如 decimal、tinyint(4)、int unsigned
+}
+
+// Lookup 数据库类型对应的 proto 类型，无法识别时使用 string 并返回 false
+func (types *ProtoTypes) Lookup(raw string) (string, bool) {
+	sqlType := ParseSQLType(raw)
+	keys := []string{sqlType.Raw, sqlType.Base}
+	if sqlType.Unsigned {
+		keys = []string{sqlType.Raw, sqlType.Base + " unsigned", sqlType.Base}
+	}
+	for _, key := range keys {
+		if value, exists := types.Overrides[key]; exists {
+			return value, true
+		}
+	}
+
+	var protoType string
+	switch types.Dialect {
+	case "postgres":
+		protoType = postgresProtoTypes[sqlType.Base]
+	case "clickhouse":
+		protoType = clickhouseProtoTypes[sqlType.Base]
+	case "sqlite3":
+		if protoType = sqliteProtoTypes[sqlType.Base]; protoType == "" {
+			protoType = mysqlType(sqlType)
+		}
+		if protoType == "" {
+			protoType = sqliteAffinity(sqlType.Base)
+		}
+	default:
+		protoType = mysqlType(sqlType)
+	}
+	if protoType == "" {
+		return "string", false
+	}
+	return protoType, true
+}
+
+// mysqlType tinyint(1) 作为布尔值，unsigned 的整数使用无符号类型
+func mysqlType(sqlType SQLType) string {
+	if sqlType.Base == "tinyint" && sqlType.Args == "1" {
+		return "bool"
+	}
+	protoType := mysqlProtoTypes[sqlType.Base]
+	if sqlType.Unsigned && strings.HasPrefix(protoType, "int") {
+		protoType = "u" + protoType
+	}
+	return protoType
+}
+
+// sqliteAffinity https://www.sqlite.org/datatype3.html#determination_of_column_affinity
+func sqliteAffinity(base string) string {
+	switch {
+	case strings.Contains(base, "int"):
+		return "int64"
+	case strings.Contains(base, "char") || strings.Contains(base, "clob") || strings.Contains(base, "text"):
+		return "string"
+	case base == "" || strings.Contains(base, "blob"):
+		return "bytes"
+	case strings.Contains(base, "real") || strings.Contains(base, "floa") || strings.Contains(base, "doub"):
+		return "double"
+	default:
+		return ""
+	}
+}
+
+// enumValueName 把 enum 的值转换成 proto 枚举值的名称，例如 in_progress 转换成 InProgress
+func enumValueName(value string, index int) string {
+	name := ToCamelCase(strings.Join(strings.FieldsFunc(value, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+	}), "_"))
+	if name == "" || name[0] >= '0' && name[0] <= '9' {
+		name = fmt.Sprintf("Value%d%s", index, name)
+	}
+	return name
+}
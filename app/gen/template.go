@@ -2,51 +2,60 @@ package gen
 
 import (
 	"fmt"
-	"log"
 	"os"
 	"strings"
+	"text/scanner"
 	"text/template"
 
 	"github.com/goal-web/supports/logs"
 )
 
-var defaultTemplate = []byte("{{- define \"model\" -}}\npackage {{ .Package }}\n  \nimport (\n    \"encoding/json\"\n    \"github.com/goal-web/supports/logs\"\n    \"github.com/goal-web/contracts\"\n    \"github.com/goal-web/database/table\"\n\t\"github.com/goal-web/migration/migrate\"\n    \"github.com/goal-web/supports/utils\"\n    \"github.com/goal-web/collection\"\n\t\"github.com/spf13/cast\"\n    \"fmt\"\n    {{- if hasMsgComment .Model \"@carbon\" }}\n    \"github.com/golang-module/carbon/v2\"\n    {{- end }}\n    {{- range .Imports }}\n    {{ .Alias }} \"{{ .Pkg }}\"\n    {{- end }}\n)\n\n{{- $modelName := .Model.Name }}\n{{- $rawName := .Model.RawName }}\n{{- $tableName := .Model.TableName }}\n{{- $primaryKey := .Model.PrimaryKey }}\n\nvar (\n    {{- range .Relations }}\n    {{ $rawName }}{{ .Name }}Relation contracts.RelationType = \"{{ .JSONName }}\"\n    {{- end }}\n)\n\n{{ toComments .Model.Name .Model.Comments }}\ntype {{ $modelName }} struct {\n\n  {{- range .Fields }}\n  {{- if hasComment .Comment \"@belongsTo\" }}\n  {{- else }}\n  {{ .Comments }}\n  {{ .Name }} {{ goType . }} `{{ toTags . }}`\n  {{- end }}\n  {{- end }}\n\n  _raw contracts.Fields\n  _update contracts.Fields\n  _append contracts.Fields\n  _hidden map[string]struct{}\n\n  _relation_loaded map[contracts.RelationType]struct{}\n  {{- range .Relations }}\n    _{{ .Name }} {{ goType . }}\n  {{- end }}\n}\n\n{{- $define := join $rawName \"Define\" }}\nvar {{ $define }} {{ $rawName }}Static\n\ntype {{ $rawName }}Static struct {\n    TableName string\n\tHidden []string\n\tIndexes []string\n\tWith []contracts.RelationType\n\tAppends map[string]func(model *{{ $modelName }}) any\n\n  {{- range .Fields }}\n  {{ .Name }}Getter func(model *{{ $modelName }}, raw {{ goType . }}) {{ goType . }}\n  {{ .Name }}Setter func(model *{{ $modelName }}, raw {{ goType . }}) {{ goType . }}\n  {{- end }}\n\n  Saving   func(model *{{ $modelName }}) contracts.Exception\n  Saved    func(model *{{ $modelName }})\n  Updating func(model *{{ $modelName }}, fields contracts.Fields) contracts.Exception\n  Updated  func(model *{{ $modelName }}, fields contracts.Fields)\n  Deleting func(model *{{ $modelName }}) contracts.Exception\n  Deleted  func(model *{{ $modelName }})\n  PrimaryKeyGetter func(model *{{ $modelName }}) any\n}\n\nfunc {{ $rawName }}Migrator() migrate.Migrator {\n\treturn func(executor contracts.SqlExecutor) contracts.Exception {\n\t    return migrate.Migrate({{ $define }}.TableName, {{ $define }}.Indexes, {{ $modelName }}{}, executor)\n\t}\n}\n\nfunc init() {\n    {{ $define }}.TableName = \"{{ $tableName }}\"\n    {{ $define }}.Appends = make(map[string]func(model *{{ $modelName }}) any)\n    {{- if hasMsgComment .Model \"@hidden\" }}\n    {{ $define }}.Hidden = append(\n        {{ $define }}.Hidden,\n        {{- range .Fields }}\n            {{- if hasComment .Comment \"@hidden\" }}\n            \"{{ .JSONName }}\",\n            {{- end }}\n        {{- end }}\n     )\n    {{- end }}\n\n    {{- if hasMsgComment .Model \"@with\" }}\n    {{ $define }}.With = append(\n        {{ $define }}.With,\n        {{- range .Relations }}\n            {{- if hasComment .Comment \"@with\" }}\n             {{ $rawName }}{{ .Name }}Relation,\n            {{- end }}\n        {{- end }}\n     )\n    {{- end }}\n\n    {{- if hasMsgComment .Model \"@index\" }}\n    {{ $define }}.Indexes = append(\n        {{ $define }}.Indexes,\n        {{- range .Fields }}\n            {{- if hasComment .Comment \"@index\" }}\n             \"index;{{ getIndexComment .Comment \"@index\" 0 (join .JSONName \"_idx\") }};{{ replace (getIndexComment .Comment \"@index\" 1 (join \"(\" .JSONName \")\")) \";\" \",\" }}\",\n            {{- end }}\n        {{- end }}\n     )\n    {{- end }}\n\n    {{- if hasMsgComment .Model \"@unique\" }}\n    {{ $define }}.Indexes = append(\n        {{ $define }}.Indexes,\n        {{- range .Fields }}\n            {{- if hasComment .Comment \"@unique\" }}\n             \"unique index;{{ getIndexComment .Comment \"@unique\" 0 (join .JSONName \"_idx\") }};{{ replace (getIndexComment .Comment \"@unique\" 1 (join \"(\" .JSONName \")\")) \";\" \",\" }}\",\n            {{- end }}\n        {{- end }}\n     )\n    {{- end }}\n}\n\nfunc New{{ $modelName }}(fields contracts.Fields) *{{ $modelName }} {\n  var model = {{ $modelName }}{\n    _raw: fields,\n  }\n  model.Set(fields)\n  return &model\n}\n\nfunc {{ $modelName }}SingleRelationSetter[T any](key contracts.RelationType) func(item *{{ $modelName }}, value []any) {\n    return func(item *{{ $modelName }}, values []any) {\n        var value T\n        if len(values) > 0 {\n            value = values[0].(T)\n        }\n        item.Set(contracts.Fields{\n            string(key): value,\n        })\n    }\n}\nfunc {{ $modelName }}MultiRelationSetter[T any](key contracts.RelationType) func(item *{{ $modelName }}, value []any) {\n    return func(model *{{ $modelName }}, value []any) {\n        var results []T\n        for _, item := range value {\n            results = append(results, item.(T))\n        }\n        model.Set(contracts.Fields{ string(key): results })\n    }\n}\n\nfunc {{ $modelName }}LocalKeyGetter(key string) func(item *{{ $modelName }}) any {\n    return func(item *{{ $modelName }}) any {\n        return item.Get(key)\n    }\n}\n\nfunc {{ $modelName }}RelationGetter[T any](query func() *table.Table[T], foreignKey string) func(keys []any) map[string][]any {\n    return func(keys []any) map[string][]any {\n        var results = map[string][]any{}\n        for key, values := range query().WhereIn(foreignKey, keys).Get().GroupBy(foreignKey) {\n            results[key] = collection.New(values).ToAnyArray()\n        }\n        return results\n    }\n}\n\nfunc {{ $modelName }}ThroughRelationGetter[T any](query func() *table.Table[T], midTable, firstKey, secondKey, secondLocalKey string) func(keys []any) map[string][]any {\n    return func(keys []any) map[string][]any {\n        var results = map[string][]any{}\n        groupKey := fmt.Sprintf(\"%s.%s\", midTable, firstKey)\n        for key, values := range query().\n            AddSelect(fmt.Sprintf(\"(%s) as _group_key\", groupKey)).\n            WhereIn(groupKey, keys).\n            Join(midTable, fmt.Sprintf(\"%s.%s\", midTable, secondLocalKey), \"=\", fmt.Sprintf(\"%s.%s\", query().GetTableName(), secondKey)).\n            Get().GroupBy(\"_group_key\") {\n            results[key] = collection.New(values).ToAnyArray()\n        }\n        return results\n    }\n }\n\n{{- $queryName := replace .Model.Name \"Model\" \"Query\" }}\nfunc {{ $queryName }}WithExecutor(executor contracts.SqlExecutor) *table.Table[{{ $modelName }}] {\n    return {{ $queryName }}().SetExecutor(executor)\n}\n\nfunc {{ $queryName }}() *table.Table[{{ $modelName }}] {\n  return table.NewQuery({{ $define }}.TableName, New{{ $modelName }}).\n    SetPrimaryKey(\"{{ $primaryKey }}\").\n    {{- if hasMsgComment .Model \"@timestamps\" }}\n    SetCreatedTimeColumn(\"{{ getIndexComment .Model.Comment \"@timestamps\" 0 \"created_at\" }}\").\n    SetUpdatedTimeColumn(\"{{ getIndexComment .Model.Comment \"@timestamps\" 1 \"updated_at\" }}\").\n    {{- end }}\n    {{- range $index, $item := .Relations }}\n        {{- $relationType := join $rawName  .Name \"Relation\" }}\n        {{- $relationItemType := substring (goType .) 1 }}\n        {{- $relationQuery := replace $relationItemType \"Model\" \"Query\"}}\n\n        {{- if .Repeated }}\n        {{- $relationItemType = substring (goType .) 2 }}\n        {{- $relationQuery = substring $relationQuery 2 }}\n        {{- end }}\n\n\n        {{- if hasComment .Comment \"@belongsTo\" }}\n            {{- $ownerKey := getIndexComment .Comment \"@belongsTo\" 0 \"id\" }}\n            {{- $localKey := getIndexComment .Comment \"@belongsTo\" 1 (join .JSONName \"_id\") }}\n            SetRelation( // belongsTo: {{ .Name }}\n            {{ $rawName }}{{ .Name }}Relation,\n                        {{ $modelName }}LocalKeyGetter(\"{{ $localKey }}\"),\n                        {{ $modelName }}RelationGetter({{ $relationQuery }}, \"{{ $ownerKey }}\"),\n                        {{ $modelName }}SingleRelationSetter[*{{ $relationItemType }}]({{ $relationType }}),\n            ).\n        {{- else if hasComment .Comment \"@hasOneThrough\" }}\n\n         {{- $midTable := getIndexComment .Comment \"@hasOneThrough\" 0 \"mid_table\" }}\n         {{- $firstKey := getIndexComment .Comment \"@hasOneThrough\" 1 (join (toLower $rawName) \"_id\") }}\n         {{- $secondKey := getIndexComment .Comment \"@hasOneThrough\" 2 \"id\" }}\n         {{- $localKey := getIndexComment .Comment \"@hasOneThrough\" 3 \"id\" }}\n         {{- $secondLocalKey := getIndexComment .Comment \"@hasOneThrough\" 4 (join $midTable \"_id\") }}\n\n                    SetRelation( // hasOneThrough: {{ .Name }}\n                    {{ $rawName }}{{ .Name }}Relation,\n                        {{ $modelName }}LocalKeyGetter(\"{{ $localKey }}\"),\n                        {{ $modelName }}ThroughRelationGetter({{ $relationQuery }}, \"{{ $midTable }}\", \"{{ $firstKey }}\", \"{{ $secondKey }}\", \"{{ $secondLocalKey }}\"),\n                        {{ $modelName }}SingleRelationSetter[*{{ $relationItemType }}]({{ $relationType }}),\n                    ).\n        {{- else if hasComment .Comment \"@hasOne\" }}\n         {{- $localKey := getIndexComment .Comment \"@hasOne\" 0 \"id\" }}\n         {{- $foreignKey := getIndexComment .Comment \"@hasOne\" 1 (join (toLower $rawName) \"_id\") }}\n                    SetRelation( // hasOne: {{ .Name }}\n                    {{ $rawName }}{{ .Name }}Relation,\n                        {{ $modelName }}LocalKeyGetter(\"{{ $localKey }}\"),\n                        {{ $modelName }}RelationGetter({{ $relationQuery }}, \"{{ $foreignKey }}\"),\n                        {{ $modelName }}SingleRelationSetter[*{{ $relationItemType }}]({{ $relationType }}),\n                    ).\n        {{- else if or (hasComment .Comment \"@hasManyThrough\") (hasComment .Comment \"@belongsToMany\") }}\n\n            {{- $relationName := \"hasManyThrough\" }}\n            {{- if (hasComment .Comment \"@belongsToMany\") }}\n            {{- $relationName = \"belongsToMany\" }}\n            {{- end }}\n\n         {{- $midTable := getIndexComment .Comment (join \"@\" $relationName) 0 \"mid_table\" }}\n         {{- $firstKey := getIndexComment .Comment (join \"@\" $relationName) 1 (join (toLower $rawName) \"_id\") }}\n         {{- $secondKey := getIndexComment .Comment (join \"@\" $relationName) 2 \"id\" }}\n         {{- $localKey := getIndexComment .Comment (join \"@\" $relationName) 3 \"id\" }}\n         {{- $secondLocalKey := getIndexComment .Comment (join \"@\" $relationName) 4 (join $midTable \"_id\") }}\n\n                    SetRelation( // {{- $relationName }}: {{ .Name }}\n                    {{ $rawName }}{{ .Name }}Relation,\n                        {{ $modelName }}LocalKeyGetter(\"{{ $localKey }}\"),\n                        {{ $modelName }}ThroughRelationGetter({{ $relationQuery }}, \"{{ $midTable }}\", \"{{ $firstKey }}\", \"{{ $secondKey }}\", \"{{ $secondLocalKey }}\"),\n                        {{ $modelName }}MultiRelationSetter[{{ $relationItemType }}]({{ $relationType }}),\n                    ).\n        {{- else if hasComment .Comment \"@hasMany\" }}\n         {{- $relationItemType := substring (goType .) 2 }}\n         {{- $relationQuery := replace (substring (goType .) 3) \"Model\" \"Query\"}}\n         {{- $foreignKey := getIndexComment .Comment \"@hasMany\" 0 (join (toLower $rawName) \"_id\") }}\n         {{- $localKey := getIndexComment .Comment \"@hasMany\" 1 \"id\" }}\n                    SetRelation( // hasMany: {{ .Name }}\n                    {{ $rawName }}{{ .Name }}Relation,\n                        {{ $modelName }}LocalKeyGetter(\"{{ $localKey }}\"),\n                        {{ $modelName }}RelationGetter({{ $relationQuery }}, \"{{ $foreignKey }}\"),\n                        {{ $modelName }}MultiRelationSetter[{{ $relationItemType }}]({{ $relationType }}),\n                    ).\n        {{- end }}\n\n    {{- end }}\n     SetWiths({{ $define }}.With...)\n}\n\nfunc (model *{{ $modelName }}) Hidden(fields ...string) *{{ $modelName }} {\n    for _, field := range fields {\n        if model._hidden == nil {\n            model._hidden = map[string]struct{}{\n                field: struct{}{},\n            }\n        } else {\n            model._hidden[field] = struct{}{}\n        }\n\n    }\n\n    return model\n}\n\nfunc (model *{{ $modelName }}) Exists() bool {\n  return {{ .Model.RawName }}Query().Where(\"{{ $primaryKey }}\", model.GetPrimaryKey()).Count() > 0\n}\n\nfunc (model *{{ $modelName }}) Save() contracts.Exception {\n  if model._update == nil {\n    return nil\n  }\n  if {{ $define }}.Saving != nil {\n    if err := {{ $define }}.Saving(model); err != nil {\n      return err\n    }\n  } \n  _, err := {{ .Model.RawName }}Query().Where(\"{{ $primaryKey }}\", model.GetPrimaryKey()).UpdateE(model._update)\n  if err == nil {\n    model._update = nil\n    if {{ $define }}.Saved != nil {\n      {{ $define }}.Saved(model)\n    }\n  }\n  \n  return err\n}\n\nfunc (model *{{ $modelName }}) Set(fields contracts.Fields) {\n  for key, value := range fields {\n\n    switch key {\n  {{- range .Fields }}\n      case \"{{ .JSONName }}\":\n        switch v := value.(type) {\n                case {{ goType . }}:\n                  model.Set{{ .Name }}(v)\n                case func() {{ goType . }}:\n                  model.Set{{ .Name }}(v())\n                  {{- $type := goType . }}\n                  {{- if ne $type \"string\"}}\n                case string:\n                  {{- if eq $type \"[]byte\" }}\n                  model.Set{{ .Name }}([]byte(v))\n                  {{else}}\n                  var vd {{ goType . }}\n                  err := json.Unmarshal([]byte(v), &vd)\n                  if err != nil {\n                      logs.Default().Warn(\"Failed to Parse field \"+key)\n                      continue\n                  }\n                  model.Set{{ .Name }}(vd)\n                  {{end}}\n                  {{end}}\n                  {{- if ne $type \"[]byte\"}}\n                case []byte:\n                  {{- if eq $type \"string\" }}\n                  model.Set{{ .Name }}(string(v))\n                  {{else}}\n                  var vd {{ goType . }}\n                  err := json.Unmarshal(v, &vd)\n                  if err != nil {\n                      logs.Default().Warn(\"Failed to Parse field \"+key)\n                      continue\n                  }\n                  model.Set{{ .Name }}(vd)\n                  {{end}}\n                  {{end}}\n                {{- if isBasicType . }}\n                default:\n                    model.Set{{ .Name }}(cast.{{ convertFunc (goType .) }}(v))\n                {{- end }}\n                }\n    {{- end }}\n    {{- range .Relations }}\n    {{- $relationType := join $rawName  .Name \"Relation\" }}\n    case string({{ $relationType }}):\n        model.Set{{ .Name }}(value.({{ goType . }}))\n    {{- end }}\n    }\n\n  }\n}\n\nfunc (model *{{ $modelName }}) HasField(field string) bool {\n    switch field {\n       case {{ range $index, $field := .Fields }}{{ if $index }},{{ end }}\"{{ $field.JSONName }}\"{{ end }}:\n         return true\n       default:\n         return false\n     }\n}\n\nfunc (model *{{ $modelName }}) Only(key ...string) contracts.Fields {\n  var fields = make(contracts.Fields)\n  for _, k := range key {\n  {{- range .Fields }}\n    if k == \"{{ .JSONName }}\" {\n      fields[k] = model.Get{{ .Name }}()\n      continue\n    }\n  {{- end }}\n  \n    if {{ $define }}.Appends[k] != nil {\n     fields[k] = {{ $define }}.Appends[k](model)\n    }\n  }\n  return fields\n}\n\nfunc (model *{{ $modelName }}) Get(key string) any {\n    switch key {\n        {{- range $index, $item := .Fields }}\n            case \"{{ .JSONName }}\":\n              return model.Get{{ .Name }}()\n        {{- end }}\n    }\n\n    if value, exists := model._append[key]; exists {\n      return value\n    }\n\n    if fn, exists := {{ $define }}.Appends[key]; exists {\n        model._append[key] = fn(model)\n      return model._append[key]\n    }\n\n     switch contracts.RelationType(key) {\n            {{- range $index, $item := .Relations }}\n            {{- $relationType := join $rawName  .Name \"Relation\" }}\n                case {{ $relationType }}:\n                  return model.{{ .Name }}()\n            {{- end }}\n        }\n\n  return nil\n}\n\nfunc (model *{{ $modelName }}) Except(keys ...string) contracts.Fields {\n  var excepts = map[string]struct{}{}\n  for _, k := range keys {\n    excepts[k] = struct{}{}\n  }\n  var fields = make(contracts.Fields)\n  for key, value := range model.ToFields() {\n    if _, ok := excepts[key]; ok {\n      continue\n    }\n    fields[key] = value\n  }\n  return fields\n}\n\nfunc (model *{{ $modelName }}) ToFields() contracts.Fields {\n    if model == nil {\n        return nil\n    }\n\n  model.Hidden({{ $define }}.Hidden...)\n\n  fields := contracts.Fields{}\n\n    {{- range .Fields }}\n    if _,exists := model._hidden[\"{{ .JSONName }}\"]; !exists {\n        fields[\"{{ .JSONName }}\"] = model.Get{{ .Name }}()\n    }\n    {{- end }}\n\n  for key := range {{ $define }}.Appends {\n    value := model.Get(key)\n    if fieldsProvider, ok := value.(contracts.FieldsProvider); ok {\n        fields[key] = fieldsProvider.ToFields()\n    } else {\n        fields[key] = value\n    }\n  }\n\n  for key := range model._relation_loaded {\n    switch key {\n    {{- range .Relations }}\n    {{- $relationType := join $rawName  .Name \"Relation\" }}\n    case {{ $relationType }}:\n        {{- if .Repeated }}\n        var results []contracts.Fields\n        for _, item := range model._{{ .Name }} {\n            results = append(results, item.ToFields())\n        }\n        fields[string(key)] = results\n        {{- else }}\n        fields[string(key)] = model._{{ .Name }}.ToFields()\n        {{- end }}\n    {{- end }}\n    }\n  }\n\n  for key, value := range model._raw {\n    _, hidden := model._hidden[key]\n    if _, exists := fields[key]; !exists && !hidden {\n        fields[key] = value\n    }\n  }\n\n  return fields\n}\n\nfunc (model *{{ $modelName }}) Update(fields contracts.Fields) contracts.Exception {\n\n  if {{ $define }}.Updating != nil {\n    if err := {{ $define }}.Updating(model, fields); err != nil {\n      return err\n    }\n  }\n\n  if model._update != nil {\n    utils.MergeFields(model._update, fields)\n  }\n\n\n  _, err := {{ .Model.RawName }}Query().Where(\"{{ $primaryKey }}\", model.GetPrimaryKey()).UpdateE(fields)\n\n  if err == nil {\n    model.Set(fields)\n    model._update = nil\n    if {{ $define }}.Updated != nil {\n      {{ $define }}.Updated(model, fields)\n    }\n  }\n\n  return err\n}\n\nfunc (model *{{ $modelName }}) Refresh() contracts.Exception {\n  fields, err := table.ArrayQuery(\"{{ $tableName }}\").Where(\"{{ $primaryKey }}\", model.GetPrimaryKey()).FirstE()\n  if err != nil {\n    return err\n  }\n\n  model.Set(*fields)\n  return nil\n}\n\nfunc (model *{{ $modelName }}) Delete() contracts.Exception {\n\n  if {{ $define }}.Deleting != nil {\n    if err := {{ $define }}.Deleting(model); err != nil {\n      return err\n    }\n  }\n\n  _, err := {{ .Model.RawName }}Query().Where(\"{{ $primaryKey }}\", model.GetPrimaryKey()).DeleteE()\n  if err == nil && {{ $define }}.Deleted != nil {\n    {{ $define }}.Deleted(model)\n  }\n\n  return err\n}\n\n\nfunc (model *{{ $modelName }}) GetPrimaryKey() any {\n  if {{ $define }}.PrimaryKeyGetter != nil {\n    return {{ $define }}.PrimaryKeyGetter(model)\n  }\n\n  return model.{{ toCamelCase $primaryKey }}\n}\n\n{{- if .Model.Authenticatable }}\nfunc (model *{{ $modelName }}) GetAuthenticatableKey() string {\n  return fmt.Sprintf(\"%v\", model.GetPrimaryKey())\n}\n\nfunc {{ .Model.RawName }}AuthProvider(identify string) contracts.Authenticatable {\n  return {{ .Model.RawName }}Query().Find(identify)\n}\n\n{{- end }}\n\n\n{{- range .Fields }}\n\nfunc (model *{{ $modelName }}) Get{{ .Name }}() {{ goType . }} {\n  if {{ $define }}.{{ .Name }}Getter != nil {\n    return {{ $define }}.{{ .Name }}Getter(model, model.{{ .Name }})\n  }\n  return model.{{ .Name }}\n}\n\nfunc (model *{{ $modelName }}) Set{{ .Name }}(value {{ goType . }}) {\n  if {{ $define }}.{{ .Name }}Setter != nil {\n    value = {{ $define }}.{{ .Name }}Setter(model, value)\n  }\n\n  if model._update == nil {\n    model._update = contracts.Fields{\"{{ .JSONName }}\": value}\n  } else {\n    model._update[\"{{ .JSONName }}\"] = value\n  }\n  model.{{ .Name }} = value\n}\n\n{{- if hasComment .Comment \"@carbon\" }}\nfunc (model *{{ $modelName }}) Get{{ .Name }}Carbon() carbon.Carbon {\n  return carbon.Parse(model.Get{{ .Name }}())\n}\n{{- end }}\n\n\n{{- end }}\n\n{{- range .Relations }}\n{{- $relationType := join $rawName  .Name \"Relation\" }}\n{{- $relationItemType := substring (goType .) 1 }}\n{{- $relationQueryType := substring (goType .) 1 }}\n{{- $throughName := \"\" }}\n\n{{- if .Repeated }}\n{{- $relationItemType = substring (goType .) 3 }}\n{{- $relationQueryType = substring (goType .) 3 }}\n{{- end }}\n\n\n{{- $relationQuery := replace $relationItemType \"Model\" \"Query\" }}\n{{- $foreignKey := \"\" }}\n{{- $localKey := \"\" }}\n{{- $localQuery := join .Name \"Query\" }}\n\n{{- if (hasComment .Comment \"@belongsTo\") }}\n{{- $throughName = \"@belongsTo\" }}\n{{- $foreignKey = getIndexComment .Comment \"@belongsTo\" 0 \"id\" }}\n{{ $localKey = getIndexComment .Comment \"@belongsTo\" 1 (join .JSONName \"_id\") }}\n\n{{- else if (hasComment .Comment \"@hasOne\") }}\n{{- $throughName = \"@hasOne\" }}\n\n{{- $localKey = getIndexComment .Comment \"@hasOne\" 0 \"id\" }}\n{{- $foreignKey = getIndexComment .Comment \"@hasOne\" 1 (join (toLower $rawName) \"_id\") }}\n\n{{- else if (hasComment .Comment \"@hasMany\") }}\n{{- $throughName = \"@hasMany\" }}\n\n{{- $relationQuery = replace $relationItemType \"Model\" \"Query\" }}\n{{- $foreignKey = getIndexComment .Comment \"@hasMany\" 0 (join .JSONName \"_id\") }}\n{{- $localKey = getIndexComment .Comment \"@hasMany\" 1 \"id\" }}\n{{- $relationQueryType = $relationItemType }}\n\n{{- end }}\n\n{{- if .Repeated }}\n// {{ $localQuery }} {{ $throughName }}\nfunc (model *{{ $modelName }}) {{ .Name }}() {{ goType . }} {\n    _, exists := model._relation_loaded[{{ $relationType }}]\n    if !exists {\n        value := model.{{ $localQuery }}().Get().ToArray()\n        model.Set{{ .Name }}(value)\n        return value\n    }\n    return model._{{ .Name }}\n}\n\n{{- else }}\n// {{ $localQuery }} {{ $throughName }}\nfunc (model *{{ $modelName }}) {{ .Name }}() {{ goType . }} {\n    _, exists := model._relation_loaded[{{ $relationType }}]\n    if !exists {\n        value := model.{{ $localQuery }}().First()\n        model.Set{{ .Name }}(value)\n        return value\n    }\n    return model._{{ .Name }}\n}\n{{- end }}\n\n\n{{- if or (hasComment .Comment \"@hasManyThrough\") (hasComment .Comment \"@belongsToMany\") (hasComment .Comment \"@hasOneThrough\")  }}\n\n{{- $throughName := \"@hasManyThrough\" }}\n\n{{- if (hasComment .Comment \"@belongsToMany\") }}\n{{- $throughName = \"@belongsToMany\" }}\n{{- else if (hasComment .Comment \"@hasOneThrough\") }}\n{{- $throughName = \"@hasOneThrough\" }}\n{{- end }}\n\n\n{{- $midTable := getIndexComment .Comment $throughName 0 \"mid_table\" }}\n{{- $firstKey := getIndexComment .Comment $throughName 1 (join (toLower $rawName) \"_id\") }}\n{{- $secondKey := getIndexComment .Comment $throughName 2 \"id\" }}\n{{- $localKey := getIndexComment .Comment $throughName 3 \"id\" }}\n{{- $secondLocalKey := getIndexComment .Comment $throughName 4 (join $midTable \"_id\") }}\n// {{ $localQuery }} {{ $throughName }}\nfunc (model *{{ $modelName }}) {{ $localQuery }}() contracts.QueryBuilder[{{ $relationQueryType }}] {\n    query := {{ $relationQuery }}()\n    return query.\n        Where(\"{{ $midTable }}.{{ $firstKey }}\", model.Get(\"{{ $localKey }}\")).\n        Join(\"{{ $midTable }}\", \"{{ $midTable }}.{{ $secondLocalKey }}\",  \"=\", fmt.Sprintf(\"%s.{{ $secondKey }}\", query.GetTableName()))\n}\n\n{{- else }}\n// {{ $localQuery }} {{ $throughName }}\nfunc (model *{{ $modelName }}) {{ $localQuery }}() contracts.QueryBuilder[{{ $relationQueryType }}] {\n    return {{ $relationQuery }}().Where(\"{{ $foreignKey }}\", model.Get(\"{{ $localKey }}\"))\n}\n{{- end }}\n\n// {{ $localQuery }} {{ $throughName }}\nfunc (model *{{ $modelName }}) Set{{ .Name }}(value {{ goType . }}) {\n    if model._relation_loaded == nil {\n        model._relation_loaded = make(map[contracts.RelationType]struct{})\n    }\n    model._relation_loaded[{{ $relationType }}] = struct{}{}\n    model._{{ .Name }} = value\n}\n\n{{- end }}\n\n{{ end }}\n\n\n{{- define \"data\" -}}\npackage {{ .Package }}\n\nimport (\n{{- range .Imports }}\n{{ .Alias }} \"{{ .Pkg }}\"\n{{- end }}\n)\n\ntype {{ .Model.Name }} struct {\n  {{- range .Fields }}\n  {{ .Name }} {{ goType . }} `{{ toTags . }}`\n  {{- end }}\n}\n\n{{ end }}\n\n{{- define \"request\" -}}\npackage {{ .Package }}\n\nimport (\n  {{- range .Imports }}\n  {{ .Alias }} \"{{ .Pkg }}\"\n  {{- end }}\n  \"github.com/goal-web/contracts\"\n)\n\ntype {{ .Model.Name }} struct {\n  {{- range .Fields }}\n  {{ .Name }} {{ goType . }} `{{ toTags . }}`\n  {{- end }}\n}\n\nfunc (model *{{ .Model.Name }}) ToFields() contracts.Fields {\n  if model == nil {\n    return nil\n  }\n  fields := contracts.Fields{\n  {{- range .Fields }}\n    \"{{ .JSONName }}\": model.{{ .Name }},\n  {{- end }}\n  }\n  return fields\n}\n\n{{ end }}\n\n{{- define \"result\" -}}\npackage {{ .Package }}\n\nimport (\n    \"github.com/goal-web/contracts\"\n  {{- range .Imports }}\n  {{ .Alias }} \"{{ .Pkg }}\"\n  {{- end }}\n)\n\n{{- $resultName := .Model.Name }}\n\ntype {{ $resultName }} struct {\n  {{- range .Fields }}\n  {{ .Name }} {{ goType . }} `{{ toTags . }}`\n  {{- end }}\n}\n\nfunc (result *{{ $resultName }}) ToFields() contracts.Fields {\n\n    fields := contracts.Fields{\n        {{- range .Fields }}\n            {{- if eq (fieldMsg .) nil }}\n            \"{{ .JSONName }}\": result.{{ .Name }},\n            {{- else if and (ne .Repeated true) .IsModel }}\n            \"{{ .JSONName }}\": result.{{ .Name }}.ToFields(),\n            {{- else }}\n            \"{{ .JSONName }}\": result.{{ .Name }},\n            {{- end }}\n        {{- end }}\n    }\n\n    {{- range .Fields }}\n        {{- if and .Repeated (ne (fieldMsg .) nil) (fieldMsg .).IsModel }}\n        {{ .JSONName }}List := make([]contracts.Fields, len(result.{{ .Name }}))\n        for i, item := range result.{{ .Name }} {\n            {{ .JSONName }}List[i] = item.ToFields()\n        }\n        fields[\"{{ .JSONName }}\"] = {{ .JSONName }}List\n        {{- end }}\n    {{- end }}\n\n\n    return fields\n}\n\n{{ end }}\n\n{{- define \"enum\" -}}\npackage {{ .Package }}\n\n{{- $enumName := .Name }}\ntype {{ .Name }} int\nconst (\n  {{- range .Values }}\n  {{- $FieldName := sprintf \"%s%s\" $enumName .Name }}\n\n  {{ toComments $FieldName .Comments }}\n  {{ $enumName }}{{ .Name }} {{ $enumName }} = {{ .Value }}\n  {{- end }}\n  {{ $enumName }}Unknown {{ $enumName }} = -1000\n\n)\n\n\nfunc (item {{ $enumName }}) String() string {\n    switch item {\n      {{- range .Values }}\n        case {{ $enumName }}{{ .Name }}:\n          return \"{{ .Name }}\"\n      {{- end }}\n        default:\n          return \"Unknown\"\n  }\n}\n\nfunc (item {{ $enumName }}) Message() string {\n    switch item {\n      {{- range .Values }}\n        case {{ $enumName }}{{ .Name }}:\n          return \"{{ .Message }}\"\n      {{- end }}\n        default:\n          return \"Unknown\"\n  }\n}\n\nfunc Parse{{ $enumName }}FromString(msg string) {{ $enumName }} {\n    switch msg {\n    {{- range .Values }}\n        case \"{{ .Name }}\":\n          return {{ $enumName }}{{ .Name }}\n    {{- end }}\n        default:\n          return {{ $enumName }}Unknown\n  }\n}\n\nfunc {{ $enumName }}ValueEnum() map[string]any {\n   return map[string]any{\n      {{- range .Values }}\n        \"{{ .Name }}\": \"{{ .Message }}\",\n      {{- end }}\n   }\n}\n\n\n{{ end }}\n\n\n\n{{- define \"service\" -}}\npackage {{ .Package }}\n\nimport (\n    \"github.com/goal-web/contracts\"\n  {{- range .Imports }}\n  {{ .Alias }} \"{{ .Pkg }}\"\n  {{- end }}\n)\n\n{{- $serviceName := .Name }}\n{{- $define := join .Name \"Define\" }}\n\nvar {{ $define }} {{ $serviceName }}Static\ntype  {{ $serviceName }}Static struct {\n{{- range .Methods }}\n    {{ .Name }} func (req *{{ .InputUsageName }}, ctx contracts.Context) (*{{ .OutputUsageName }}, error)\n{{- end }}\n}\n\n{{- range .Methods }}\n\nfunc {{ $serviceName }}{{ .Name }}(req *{{ .InputUsageName }}, ctx contracts.Context) (*{{ .OutputUsageName }}, error) {\n  if {{ $define }}.{{ .Name }} != nil {\n    return {{ $define }}.{{ .Name }}(req, ctx)\n  }\n  return nil, nil\n}\n{{- end }}\n{{ end }}\n\n\n{{- define \"controller\" -}}\npackage {{ .Package }}\n\nimport (\n  \"github.com/goal-web/contracts\"\n  \"github.com/goal-web/validation\"\n  \"{{ .ResponsePath }}\"\n  svc \"{{ .ImportPath }}\"\n  {{- range .Imports }}\n  {{- if notContains .Pkg \"results\" }}\n  {{ .Alias }} \"{{ .Pkg }}\"\n  {{ end -}}\n  {{- end }}\n)\n\n{{- $serviceName := .Name }}\n{{- $prefix := .Prefix }}\nfunc {{ .Name }}Router(router contracts.HttpRouter) {\n  routeGroup := router.Group(\"{{ $prefix }}\"{{ toMiddlewares .Middlewares }})\n  {{- range .Methods }}\n  {{- $controllerMethod := sprintf \"%s%s\" $serviceName .Name  }}\n  {{- $path := .Path  }}\n  {{- $middlewares := .Middlewares }}\n    {{- range .Method }}\n    routeGroup.{{ . }}(\"{{ $path }}\", {{ $controllerMethod }}{{ toMiddlewares $middlewares }})\n    {{- end }}\n  {{- end }}\n}\n\n\n{{- $usageName := .UsageName }}\n\n{{- range .Methods }}\nfunc {{ $serviceName }}{{ .Name }}(request contracts.HttpRequest) any {\n    var req {{ .InputUsageName }}\n\n    if err:= request.Parse(&req); err != nil {\n      return response.ParseReqErr(err)\n    }\n\n    if err := validation.Struct(req); err != nil {\n      return response.InvalidReq(err)\n    }\n\n    resp, err := {{ $usageName }}{{ .Name }}(&req, request)\n    if err != nil {\n      return response.BizErr(err)\n    }\n    \n    return response.Success(resp)\n}\n{{- end }}\n{{ end }}")
+var defaultTemplate = []byte("{{- define \"model\" -}}\npackage {{ .Package }}\n  \nimport (\n    \"encoding/json\"\n    \"github.com/goal-web/supports/logs\"\n    \"github.com/goal-web/contracts\"\n    \"github.com/goal-web/database/table\"\n    \"github.com/goal-web/events\"\n\t\"github.com/goal-web/migration/migrate\"\n    \"github.com/goal-web/supports/utils\"\n    \"github.com/goal-web/collection\"\n\t\"github.com/spf13/cast\"\n    \"github.com/goal-web/supports/exceptions\"\n    \"fmt\"\n    \"sync\"\n    {{- if or (.Model.HasAnnotation \"carbon\") .Model.SoftDelete }}\n    \"github.com/golang-module/carbon/v2\"\n    {{- end }}\n    {{- if .Model.Version }}\n    \"github.com/goal-web/querybuilder\"\n    {{- end }}\n    {{- if .Model.HasCast \"encrypted\" \"hashed\" }}\n    \"github.com/goal-web/application\"\n    {{- end }}\n    {{- if .Model.HasCast \"json\" }}\n    \"database/sql/driver\"\n    {{- end }}\n    {{- if eq .Model.KeyStrategy \"uuid\" }}\n    \"github.com/google/uuid\"\n    {{- else if eq .Model.KeyStrategy \"ulid\" }}\n    \"github.com/oklog/ulid/v2\"\n    {{- else if eq .Model.KeyStrategy \"snowflake\" }}\n    \"github.com/bwmarrin/snowflake\"\n    {{- end }}\n    {{- range .Imports }}\n    {{ .Alias }} \"{{ .Pkg }}\"\n    {{- end }}\n)\n\n{{- $modelName := .Model.Name }}\n{{- $rawName := .Model.RawName }}\n{{- $tableName := .Model.TableName }}\n{{- $primaryKey := .Model.PrimaryKey }}\n{{- $softDelete := .Model.SoftDelete }}\n{{- $version := .Model.Version }}\n{{- $casts := .Model.HasCast }}\n{{- $hydrate := .Model.HasCast \"encrypted\" \"hashed\" }}\n{{- $whereKey := printf \"Where(%q, model.GetPrimaryKey())\" $primaryKey }}\n{{- if .Model.CompositeKey }}\n{{- $whereKey = \"WhereFields(model.PrimaryKeyFields())\" }}\n{{- end }}\n\nvar (\n    {{- range .Relations }}\n    {{ $rawName }}{{ .Name }}Relation contracts.RelationType = \"{{ .JSONName }}\"\n    {{- end }}\n)\n\n{{ toComments .Model.Name .Model.Comments }}\ntype {{ $modelName }} struct {\n\n  {{- range .Fields }}\n  {{- if .Annotations.Has \"belongsTo\" }}\n  {{- else }}\n  {{ .Comments }}\n  {{ .Name }} {{ goType . }} `{{ toTags . }}`\n  {{- end }}\n  {{- end }}\n\n  _raw contracts.Fields\n  _update contracts.Fields\n  _append contracts.Fields\n  _hidden map[string]struct{}\n\n  _relation_loaded map[contracts.RelationType]struct{}\n  {{- range .Relations }}\n    _{{ .Name }} {{ goType . }}\n  {{- end }}\n}\n\n{{- template \"oneofs\" .Model }}\n{{- template \"modelEvents\" .Model }}\n\n{{- $define := join $rawName \"Define\" }}\nvar {{ $define }} {{ $rawName }}Static\n\ntype {{ $rawName }}Static struct {\n    TableName string\n\tHidden []string\n\tIndexes []string\n\tWith []contracts.RelationType\n\tAppends map[string]func(model *{{ $modelName }}) any\n\n  {{- range .Fields }}\n  {{ .Name }}Getter func(model *{{ $modelName }}, raw {{ goType . }}) {{ goType . }}\n  {{ .Name }}Setter func(model *{{ $modelName }}, raw {{ goType . }}) {{ goType . }}\n  {{- end }}\n\n  Saving   func(model *{{ $modelName }}) contracts.Exception\n  Saved    func(model *{{ $modelName }})\n  Updating func(model *{{ $modelName }}, fields contracts.Fields) contracts.Exception\n  Updated  func(model *{{ $modelName }}, fields contracts.Fields)\n  Deleting func(model *{{ $modelName }}) contracts.Exception\n  Deleted  func(model *{{ $modelName }})\n  PrimaryKeyGetter func(model *{{ $modelName }}) any\n  {{- if .Model.KeyStrategy }}\n  PrimaryKeyGenerator func(model *{{ $modelName }}) {{ goType .Model.PrimaryKeyField }}\n  {{- end }}\n}\n\n{{- if or .Model.CompositeKey (ne dialect \"mysql\") }}\n\n// {{ $rawName }}Schema 建表语句，migrate.Migrate 只支持 mysql 并且无法声明联合主键，表不存在时先按照建表语句创建\nvar {{ $rawName }}Schema = []string{\n    {{- range createTable .Model }}\n    {{ printf \"%q\" . }},\n    {{- end }}\n}\n{{- end }}\n\n{{- if eq dialect \"mysql\" }}\n\nfunc {{ $rawName }}Migrator() migrate.Migrator {\n\treturn func(executor contracts.SqlExecutor) contracts.Exception {\n\t    {{- if .Model.CompositeKey }}\n\t    if _, err := executor.Query(\"SELECT 1 FROM {{ $tableName }} WHERE 1 = 0\"); err != nil {\n\t        for _, statement := range {{ $rawName }}Schema {\n\t            if _, err = executor.Exec(statement); err != nil {\n\t                return err\n\t            }\n\t        }\n\t    }\n\t    {{- end }}\n\t    return migrate.Migrate({{ $define }}.TableName, {{ $define }}.Indexes, {{ $modelName }}{}, executor)\n\t}\n}\n{{- else }}\n\n// {{ $rawName }}Migrator 表不存在时按照 {{ $rawName }}Schema 建表，字段的变更需要通过 gen:migration 生成迁移文件\nfunc {{ $rawName }}Migrator() migrate.Migrator {\n\treturn func(executor contracts.SqlExecutor) contracts.Exception {\n\t    if _, err := executor.Query(\"SELECT 1 FROM {{ $tableName }} WHERE 1 = 0\"); err == nil {\n\t        return nil\n\t    }\n\t    for _, statement := range {{ $rawName }}Schema {\n\t        if _, err := executor.Exec(statement); err != nil {\n\t            return err\n\t        }\n\t    }\n\t    return nil\n\t}\n}\n{{- end }}\n\n{{- if eq .Model.KeyStrategy \"snowflake\" }}\n\n// Set{{ $rawName }}SnowflakeNode 设置生成主键的雪花算法节点，取值 0-1023，需要在保存记录之前调用\nfunc Set{{ $rawName }}SnowflakeNode(id int64) error {\n    node, err := snowflake.NewNode(id)\n    if err != nil {\n        return err\n    }\n    {{ $define }}.PrimaryKeyGenerator = func(model *{{ $modelName }}) {{ goType .Model.PrimaryKeyField }} {\n        {{- if eq (goType .Model.PrimaryKeyField) \"int64\" }}\n        return node.Generate().Int64()\n        {{- else }}\n        return {{ goType .Model.PrimaryKeyField }}(node.Generate().Int64())\n        {{- end }}\n    }\n    return nil\n}\n{{- end }}\n\nfunc init() {\n    {{ $define }}.TableName = \"{{ $tableName }}\"\n    {{ $define }}.Appends = make(map[string]func(model *{{ $modelName }}) any)\n    {{- if .Model.HasAnnotation \"hidden\" }}\n    {{ $define }}.Hidden = append(\n        {{ $define }}.Hidden,\n        {{- range .Fields }}\n            {{- if .Annotations.Has \"hidden\" }}\n            \"{{ .JSONName }}\",\n            {{- end }}\n        {{- end }}\n     )\n    {{- end }}\n\n    {{- if .Model.HasAnnotation \"with\" }}\n    {{ $define }}.With = append(\n        {{ $define }}.With,\n        {{- range .Relations }}\n            {{- if .Annotations.Has \"with\" }}\n             {{ $rawName }}{{ .Name }}Relation,\n            {{- end }}\n        {{- end }}\n     )\n    {{- end }}\n\n    {{- if .Model.HasAnnotation \"index\" }}\n    {{ $define }}.Indexes = append(\n        {{ $define }}.Indexes,\n        {{- range .Fields }}\n            {{- if .Annotations.Has \"index\" }}\n             \"index;{{ .Annotations.Arg \"index\" 0 (join .JSONName \"_idx\") }};{{ replace (.Annotations.Arg \"index\" 1 (join \"(\" .JSONName \")\")) \";\" \",\" }}\",\n            {{- end }}\n        {{- end }}\n     )\n    {{- end }}\n\n    {{- if .Model.HasAnnotation \"unique\" }}\n    {{ $define }}.Indexes = append(\n        {{ $define }}.Indexes,\n        {{- range .Fields }}\n            {{- if .Annotations.Has \"unique\" }}\n             \"unique index;{{ .Annotations.Arg \"unique\" 0 (join .JSONName \"_idx\") }};{{ replace (.Annotations.Arg \"unique\" 1 (join \"(\" .JSONName \")\")) \";\" \",\" }}\",\n            {{- end }}\n        {{- end }}\n     )\n    {{- end }}\n\n\n    {{- if eq .Model.KeyStrategy \"uuid\" }}\n    {{ $define }}.PrimaryKeyGenerator = func(model *{{ $modelName }}) string {\n        return uuid.NewString()\n    }\n    {{- else if eq .Model.KeyStrategy \"ulid\" }}\n    {{ $define }}.PrimaryKeyGenerator = func(model *{{ $modelName }}) string {\n        return ulid.Make().String()\n    }\n    {{- else if eq .Model.KeyStrategy \"snowflake\" }}\n    // 默认使用节点 1，多实例部署时通过 Set{{ $rawName }}SnowflakeNode 为每个实例分配不同的节点\n    if err := Set{{ $rawName }}SnowflakeNode(1); err != nil {\n        panic(err)\n    }\n    {{- end }}\n\n    {{- range .Fields }}\n    {{- if eq .Cast \"encrypted\" }}\n    // {{ .JSONName }} 加密存储，赋值时加密，读取时解密\n    {{ $define }}.{{ .Name }}Setter = func(model *{{ $modelName }}, raw string) string {\n        if raw == \"\" {\n            return raw\n        }\n        return application.Get(\"encryption.default\").(contracts.Encryptor).EncryptString(raw)\n    }\n    {{ $define }}.{{ .Name }}Getter = func(model *{{ $modelName }}, raw string) string {\n        if raw == \"\" {\n            return raw\n        }\n        value, err := application.Get(\"encryption.default\").(contracts.Encryptor).DecryptString(raw)\n        if err != nil {\n            logs.WithError(err).Warn(\"{{ $modelName }}: failed to decrypt {{ .JSONName }}\")\n            return \"\"\n        }\n        return value\n    }\n    {{- else if eq .Cast \"hashed\" }}\n    // {{ .JSONName }} 只保存哈希，赋值时计算哈希，序列化时隐藏，通过 Check{{ .Name }} 校验\n    {{ $define }}.Hidden = append({{ $define }}.Hidden, \"{{ .JSONName }}\")\n    {{ $define }}.{{ .Name }}Setter = func(model *{{ $modelName }}, raw string) string {\n        if raw == \"\" {\n            return raw\n        }\n        return application.Get(\"hashing\").(contracts.Hasher).Make(raw, nil)\n    }\n    {{- end }}\n    {{- end }}\n}\n\nfunc New{{ $modelName }}(fields contracts.Fields) *{{ $modelName }} {\n  var model = {{ $modelName }}{\n    _raw: fields,\n  }\n  model.Set(fields)\n  return &model\n}\n\nfunc {{ $modelName }}SingleRelationSetter[T any](key contracts.RelationType) func(item *{{ $modelName }}, value []any) {\n    return func(item *{{ $modelName }}, values []any) {\n        var value T\n        if len(values) > 0 {\n            value = values[0].(T)\n        }\n        item.Set(contracts.Fields{\n            string(key): value,\n        })\n    }\n}\nfunc {{ $modelName }}MultiRelationSetter[T any](key contracts.RelationType) func(item *{{ $modelName }}, value []any) {\n    return func(model *{{ $modelName }}, value []any) {\n        var results []T\n        for _, item := range value {\n            results = append(results, item.(T))\n        }\n        model.Set(contracts.Fields{ string(key): results })\n    }\n}\n\nfunc {{ $modelName }}LocalKeyGetter(key string) func(item *{{ $modelName }}) any {\n    return func(item *{{ $modelName }}) any {\n        return item.Get(key)\n    }\n}\n\nfunc {{ $modelName }}RelationGetter[T any](query func() *table.Table[T], foreignKey string) func(keys []any) map[string][]any {\n    return func(keys []any) map[string][]any {\n        var results = map[string][]any{}\n        for key, values := range query().WhereIn(foreignKey, keys).Get().GroupBy(foreignKey) {\n            results[key] = collection.New(values).ToAnyArray()\n        }\n        return results\n    }\n}\n\nfunc {{ $modelName }}ThroughRelationGetter[T any](query func() *table.Table[T], midTable, firstKey, secondKey, secondLocalKey string) func(keys []any) map[string][]any {\n    return func(keys []any) map[string][]any {\n        var results = map[string][]any{}\n        groupKey := fmt.Sprintf(\"%s.%s\", midTable, firstKey)\n        for key, values := range query().\n            AddSelect(fmt.Sprintf(\"(%s) as _group_key\", groupKey)).\n            WhereIn(groupKey, keys).\n            Join(midTable, fmt.Sprintf(\"%s.%s\", midTable, secondLocalKey), \"=\", fmt.Sprintf(\"%s.%s\", query().GetTableName(), secondKey)).\n            Get().GroupBy(\"_group_key\") {\n            results[key] = collection.New(values).ToAnyArray()\n        }\n        return results\n    }\n }\n\n{{- $queryName := replace .Model.Name \"Model\" \"Query\" }}\n{{- $writeQuery := $queryName }}\nfunc {{ $queryName }}WithExecutor(executor contracts.SqlExecutor) *table.Table[{{ $modelName }}] {\n    return {{ $queryName }}().SetExecutor(executor)\n}\n\n{{- if .Model.CompositeKey }}\n{{- $fields := .Fields }}\n\n// {{ $queryName }}ByKey 按照联合主键查询，Find、FindOrFail 只会按照第一个主键字段 {{ $primaryKey }} 查询\nfunc {{ $queryName }}ByKey(\n    {{- range $index, $column := .Model.PrimaryKeys }}\n    {{- range $fields }}{{ if eq .JSONName $column }}{{ if $index }}, {{ end }}{{ toLower (toCamelCase $column) }} {{ goType . }}{{ end }}{{ end }}\n    {{- end -}}\n) *table.Table[{{ $modelName }}] {\n    query := {{ $queryName }}()\n    query.WhereFields(contracts.Fields{\n        {{- range .Model.PrimaryKeys }}\n        \"{{ . }}\": {{ toLower (toCamelCase .) }},\n        {{- end }}\n    })\n    return query\n}\n{{- end }}\n\n{{- if $softDelete }}\n{{- $writeQuery = join $queryName \"WithTrashed\" }}\n\n// {{ $queryName }} 默认排除已经软删除的记录，关联查询同样如此\nfunc {{ $queryName }}() *table.Table[{{ $modelName }}] {\n    query := {{ $queryName }}WithTrashed()\n    query.WhereIsNull(fmt.Sprintf(\"%s.{{ $softDelete }}\", {{ $define }}.TableName))\n    return query\n}\n\n// {{ $queryName }}OnlyTrashed 只查询已经软删除的记录\nfunc {{ $queryName }}OnlyTrashed() *table.Table[{{ $modelName }}] {\n    query := {{ $queryName }}WithTrashed()\n    query.WhereNotNull(fmt.Sprintf(\"%s.{{ $softDelete }}\", {{ $define }}.TableName))\n    return query\n}\n\n// {{ $queryName }}WithTrashed 查询包括已经软删除的记录\nfunc {{ $queryName }}WithTrashed() *table.Table[{{ $modelName }}] {\n{{- else }}\n\nfunc {{ $queryName }}() *table.Table[{{ $modelName }}] {\n{{- end }}\n  return table.NewQuery({{ $define }}.TableName, {{ if $hydrate }}hydrate{{ else }}New{{ end }}{{ $modelName }}).\n    SetPrimaryKey(\"{{ $primaryKey }}\").\n    {{- if .Model.HasAnnotation \"timestamps\" }}\n    SetCreatedTimeColumn(\"{{ .Model.Annotations.Arg \"timestamps\" 0 \"created_at\" }}\").\n    SetUpdatedTimeColumn(\"{{ .Model.Annotations.Arg \"timestamps\" 1 \"updated_at\" }}\").\n    {{- end }}\n    {{- range $index, $item := .Relations }}\n        {{- $relationType := join $rawName  .Name \"Relation\" }}\n        {{- $relationItemType := substring (goType .) 1 }}\n        {{- $relationQuery := replace $relationItemType \"Model\" \"Query\"}}\n\n        {{- if .Repeated }}\n        {{- $relationItemType = substring (goType .) 2 }}\n        {{- $relationQuery = substring $relationQuery 2 }}\n        {{- end }}\n\n\n        {{- if .Annotations.Has \"belongsTo\" }}\n            {{- $ownerKey := .Annotations.Arg \"belongsTo\" 0 \"id\" }}\n            {{- $localKey := .Annotations.Arg \"belongsTo\" 1 (join .JSONName \"_id\") }}\n            SetRelation( // belongsTo: {{ .Name }}\n            {{ $rawName }}{{ .Name }}Relation,\n                        {{ $modelName }}LocalKeyGetter(\"{{ $localKey }}\"),\n                        {{ $modelName }}RelationGetter({{ $relationQuery }}, \"{{ $ownerKey }}\"),\n                        {{ $modelName }}SingleRelationSetter[*{{ $relationItemType }}]({{ $relationType }}),\n            ).\n        {{- else if .Annotations.Has \"hasOneThrough\" }}\n\n         {{- $midTable := .Annotations.Arg \"hasOneThrough\" 0 \"mid_table\" }}\n         {{- $firstKey := .Annotations.Arg \"hasOneThrough\" 1 (join (toLower $rawName) \"_id\") }}\n         {{- $secondKey := .Annotations.Arg \"hasOneThrough\" 2 \"id\" }}\n         {{- $localKey := .Annotations.Arg \"hasOneThrough\" 3 \"id\" }}\n         {{- $secondLocalKey := .Annotations.Arg \"hasOneThrough\" 4 (join $midTable \"_id\") }}\n\n                    SetRelation( // hasOneThrough: {{ .Name }}\n                    {{ $rawName }}{{ .Name }}Relation,\n                        {{ $modelName }}LocalKeyGetter(\"{{ $localKey }}\"),\n                        {{ $modelName }}ThroughRelationGetter({{ $relationQuery }}, \"{{ $midTable }}\", \"{{ $firstKey }}\", \"{{ $secondKey }}\", \"{{ $secondLocalKey }}\"),\n                        {{ $modelName }}SingleRelationSetter[*{{ $relationItemType }}]({{ $relationType }}),\n                    ).\n        {{- else if .Annotations.Has \"hasOne\" }}\n         {{- $localKey := .Annotations.Arg \"hasOne\" 0 \"id\" }}\n         {{- $foreignKey := .Annotations.Arg \"hasOne\" 1 (join (toLower $rawName) \"_id\") }}\n                    SetRelation( // hasOne: {{ .Name }}\n                    {{ $rawName }}{{ .Name }}Relation,\n                        {{ $modelName }}LocalKeyGetter(\"{{ $localKey }}\"),\n                        {{ $modelName }}RelationGetter({{ $relationQuery }}, \"{{ $foreignKey }}\"),\n                        {{ $modelName }}SingleRelationSetter[*{{ $relationItemType }}]({{ $relationType }}),\n                    ).\n        {{- else if or (.Annotations.Has \"hasManyThrough\") (.Annotations.Has \"belongsToMany\") }}\n\n            {{- $relationName := \"hasManyThrough\" }}\n            {{- if (.Annotations.Has \"belongsToMany\") }}\n            {{- $relationName = \"belongsToMany\" }}\n            {{- end }}\n\n         {{- $midTable := .Annotations.Arg $relationName 0 \"mid_table\" }}\n         {{- $firstKey := .Annotations.Arg $relationName 1 (join (toLower $rawName) \"_id\") }}\n         {{- $secondKey := .Annotations.Arg $relationName 2 \"id\" }}\n         {{- $localKey := .Annotations.Arg $relationName 3 \"id\" }}\n         {{- $secondLocalKey := .Annotations.Arg $relationName 4 (join $midTable \"_id\") }}\n\n                    SetRelation( // {{- $relationName }}: {{ .Name }}\n                    {{ $rawName }}{{ .Name }}Relation,\n                        {{ $modelName }}LocalKeyGetter(\"{{ $localKey }}\"),\n                        {{ $modelName }}ThroughRelationGetter({{ $relationQuery }}, \"{{ $midTable }}\", \"{{ $firstKey }}\", \"{{ $secondKey }}\", \"{{ $secondLocalKey }}\"),\n                        {{ $modelName }}MultiRelationSetter[{{ $relationItemType }}]({{ $relationType }}),\n                    ).\n        {{- else if .Annotations.Has \"hasMany\" }}\n         {{- $relationItemType := substring (goType .) 2 }}\n         {{- $relationQuery := replace (substring (goType .) 3) \"Model\" \"Query\"}}\n         {{- $foreignKey := .Annotations.Arg \"hasMany\" 0 (join (toLower $rawName) \"_id\") }}\n         {{- $localKey := .Annotations.Arg \"hasMany\" 1 \"id\" }}\n                    SetRelation( // hasMany: {{ .Name }}\n                    {{ $rawName }}{{ .Name }}Relation,\n                        {{ $modelName }}LocalKeyGetter(\"{{ $localKey }}\"),\n                        {{ $modelName }}RelationGetter({{ $relationQuery }}, \"{{ $foreignKey }}\"),\n                        {{ $modelName }}MultiRelationSetter[{{ $relationItemType }}]({{ $relationType }}),\n                    ).\n        {{- end }}\n\n    {{- end }}\n     SetWiths({{ $define }}.With...)\n}\n\n// {{ $rawName }}Columns {{ $tableName }} 表的字段名，字段改名之后引用的地方会编译失败\nvar {{ $rawName }}Columns = struct {\n  {{- range .Fields }}\n  {{- if isColumn . }}\n  {{ .Name }} string\n  {{- end }}\n  {{- end }}\n}{\n  {{- range .Fields }}\n  {{- if isColumn . }}\n  {{ .Name }}: \"{{ .JSONName }}\",\n  {{- end }}\n  {{- end }}\n}\n\n// {{ $queryName }}Builder 根据字段生成的强类型查询条件\ntype {{ $queryName }}Builder struct {\n  *table.Table[{{ $modelName }}]\n}\n\nfunc New{{ $queryName }}Builder(query *table.Table[{{ $modelName }}]) *{{ $queryName }}Builder {\n  return &{{ $queryName }}Builder{Table: query}\n}\n\nfunc {{ $rawName }}Builder() *{{ $queryName }}Builder {\n  return New{{ $queryName }}Builder({{ $queryName }}())\n}\n\n{{- range .Fields }}\n{{- if isQueryable . }}\n{{- $type := goType . }}\n\nfunc (builder *{{ $queryName }}Builder) Where{{ .Name }}(value {{ $type }}) *{{ $queryName }}Builder {\n  builder.Where({{ $rawName }}Columns.{{ .Name }}, value)\n  return builder\n}\n\nfunc (builder *{{ $queryName }}Builder) Where{{ .Name }}In(values ...{{ $type }}) *{{ $queryName }}Builder {\n  args := make([]any, 0, len(values))\n  for _, value := range values {\n    args = append(args, value)\n  }\n  builder.WhereIn({{ $rawName }}Columns.{{ .Name }}, args)\n  return builder\n}\n\nfunc (builder *{{ $queryName }}Builder) OrderBy{{ .Name }}(desc bool) *{{ $queryName }}Builder {\n  if desc {\n    builder.OrderByDesc({{ $rawName }}Columns.{{ .Name }})\n  } else {\n    builder.OrderBy({{ $rawName }}Columns.{{ .Name }})\n  }\n  return builder\n}\n\nfunc (builder *{{ $queryName }}Builder) Pluck{{ .Name }}() []{{ $type }} {\n  builder.Select({{ $rawName }}Columns.{{ .Name }})\n  var results []{{ $type }}\n  for _, item := range builder.Get().ToArray() {\n    results = append(results, item.{{ .Name }})\n  }\n  return results\n}\n{{- end }}\n{{- end }}\n\n{{- $factoryRelations := factoryRelations .Model }}\n\n// {{ $rawName }}RelatedFactory 可以通过 {{ $rawName }}Factory().With 一起创建的关联模型工厂\ntype {{ $rawName }}RelatedFactory interface {\n  FactoryModel() string\n  CreateFields(fields contracts.Fields) ([]contracts.Fields, contracts.Exception)\n}\n\n// {{ $rawName }}FactoryBuilder 生成 {{ $tableName }} 表的测试数据，相同序号生成的数据总是相同\ntype {{ $rawName }}FactoryBuilder struct {\n  count     int\n  states    []contracts.Fields\n  relations []{{ $rawName }}RelatedFactory\n}\n\nfunc {{ $rawName }}Factory() *{{ $rawName }}FactoryBuilder {\n  return &{{ $rawName }}FactoryBuilder{count: 1}\n}\n\n// Count 设置生成的数量\nfunc (factory *{{ $rawName }}FactoryBuilder) Count(count int) *{{ $rawName }}FactoryBuilder {\n  factory.count = count\n  return factory\n}\n\n// State 覆盖默认生成的字段，多次调用时后面的优先\nfunc (factory *{{ $rawName }}FactoryBuilder) State(fields contracts.Fields) *{{ $rawName }}FactoryBuilder {\n  factory.states = append(factory.states, fields)\n  return factory\n}\n\n// With 创建时一起创建关联的模型，belongsTo 的模型先创建，hasOne、hasMany 的模型后创建\nfunc (factory *{{ $rawName }}FactoryBuilder) With(related ...{{ $rawName }}RelatedFactory) *{{ $rawName }}FactoryBuilder {\n  factory.relations = append(factory.relations, related...)\n  return factory\n}\n\nfunc (factory *{{ $rawName }}FactoryBuilder) FactoryModel() string {\n  return \"{{ $modelName }}\"\n}\n\n// Definition 第 index 条数据的默认字段\nfunc (factory *{{ $rawName }}FactoryBuilder) Definition(index int) contracts.Fields {\n  return contracts.Fields{\n    {{- range .Fields }}\n    {{- $value := fakeValue . }}\n    {{- if $value }}\n    \"{{ .JSONName }}\": {{ $value }},\n    {{- end }}\n    {{- end }}\n  }\n}\n\nfunc (factory *{{ $rawName }}FactoryBuilder) fields(index int) contracts.Fields {\n  fields := factory.Definition(index)\n  for _, state := range factory.states {\n    utils.MergeFields(fields, state)\n  }\n  return fields\n}\n\n// Make 只生成模型，不写入数据库\nfunc (factory *{{ $rawName }}FactoryBuilder) Make() []*{{ $modelName }} {\n  models := make([]*{{ $modelName }}, 0, factory.count)\n  for index := 0; index < factory.count; index++ {\n    models = append(models, New{{ $modelName }}(factory.fields(index)))\n  }\n  return models\n}\n\n// Create 生成模型并写入数据库\nfunc (factory *{{ $rawName }}FactoryBuilder) Create() ([]*{{ $modelName }}, contracts.Exception) {\n  return factory.create(nil)\n}\n\n// CreateFields 作为其他模型的关联创建数据，fields 为关联的外键\nfunc (factory *{{ $rawName }}FactoryBuilder) CreateFields(fields contracts.Fields) ([]contracts.Fields, contracts.Exception) {\n  models, err := factory.create(fields)\n  results := make([]contracts.Fields, 0, len(models))\n  for _, model := range models {\n    results = append(results, model.ToFields())\n  }\n  return results, err\n}\n\nfunc (factory *{{ $rawName }}FactoryBuilder) create(overrides contracts.Fields) ([]*{{ $modelName }}, contracts.Exception) {\n  for _, related := range factory.relations {\n    switch related.FactoryModel() {\n    {{- if $factoryRelations }}\n    case {{ range $index, $relation := $factoryRelations }}{{ if $index }}, {{ end }}\"{{ $relation.Model }}\"{{ end }}:\n    {{- end }}\n    default:\n      return nil, exceptions.New(fmt.Sprintf(\"{{ $modelName }} 与 %s 之间没有可以自动创建的关联关系\", related.FactoryModel()))\n    }\n  }\n\n  models := make([]*{{ $modelName }}, 0, factory.count)\n  for index := 0; index < factory.count; index++ {\n    fields := factory.fields(index)\n    utils.MergeFields(fields, overrides)\n    {{- range $factoryRelations }}\n    {{- if .BelongsTo }}\n    for _, related := range factory.relations {\n      if related.FactoryModel() != \"{{ .Model }}\" {\n        continue\n      }\n      owners, err := related.CreateFields(nil)\n      if err != nil {\n        return models, err\n      }\n      if len(owners) > 0 {\n        fields[\"{{ .LocalKey }}\"] = owners[0][\"{{ .RelatedKey }}\"]\n      }\n    }\n    {{- end }}\n    {{- end }}\n    {{- if .Model.KeyStrategy }}\n    if _, exists := fields[\"{{ $primaryKey }}\"]; !exists {\n      fields[\"{{ $primaryKey }}\"] = {{ $define }}.PrimaryKeyGenerator(New{{ $modelName }}(fields))\n    }\n    {{- end }}\n\n    {{- if $casts }}\n    // 加密、哈希以及 json 字段转换成存储的值\n    fields = (&{{ $modelName }}{}).castFields(fields)\n    {{- end }}\n\n    model, err := {{ $writeQuery }}().CreateE(fields)\n    if err != nil {\n      return models, err\n    }\n    models = append(models, model)\n    {{- range $factoryRelations }}\n    {{- if not .BelongsTo }}\n    for _, related := range factory.relations {\n      if related.FactoryModel() != \"{{ .Model }}\" {\n        continue\n      }\n      if _, err := related.CreateFields(contracts.Fields{\"{{ .RelatedKey }}\": model.Get(\"{{ .LocalKey }}\")}); err != nil {\n        return models, err\n      }\n    }\n    {{- end }}\n    {{- end }}\n  }\n  return models, nil\n}\n\nfunc (model *{{ $modelName }}) Hidden(fields ...string) *{{ $modelName }} {\n    for _, field := range fields {\n        if model._hidden == nil {\n            model._hidden = map[string]struct{}{\n                field: struct{}{},\n            }\n        } else {\n            model._hidden[field] = struct{}{}\n        }\n\n    }\n\n    return model\n}\n\n{{- if $softDelete }}\n\n// Exists 记录是否存在，与 Restore、ForceDelete 一样包括已经软删除的记录\n{{- end }}\nfunc (model *{{ $modelName }}) Exists() bool {\n  return {{ $writeQuery }}().{{ $whereKey }}.Count() > 0\n}\n\nfunc (model *{{ $modelName }}) Save() contracts.Exception {\n  {{- if .Model.KeyStrategy }}\n  {{- $keyField := .Model.PrimaryKeyField }}\n  if model.{{ $keyField.Name }} == {{ if eq (goType $keyField) \"string\" }}\"\"{{ else }}0{{ end }} {\n    return model.insert()\n  }\n  {{- end }}\n  if model._update == nil {\n    return nil\n  }\n  if {{ $define }}.Saving != nil {\n    if err := {{ $define }}.Saving(model); err != nil {\n      return err\n    }\n  }\n  if err := dispatch{{ $rawName }}Event(&{{ $rawName }}Saving{Model: model}); err != nil {\n    return err\n  }\n  {{- if $version }}\n  expected := model.{{ .Model.VersionField.Name }}\n  rows, err := {{ $writeQuery }}().{{ $whereKey }}.Where(\"{{ $version }}\", expected).UpdateE(model.versionedFields(model._update))\n  if err == nil && rows == 0 {\n    err = &{{ $rawName }}VersionConflictException{Key: model.GetPrimaryKey(), Version: expected}\n  }\n  {{- else }}\n  _, err := {{ $writeQuery }}().{{ $whereKey }}.UpdateE(model._update)\n  {{- end }}\n  if err == nil {\n    {{- if $version }}\n    model.{{ .Model.VersionField.Name }} = expected + 1\n    {{- end }}\n    model._update = nil\n    if {{ $define }}.Saved != nil {\n      {{ $define }}.Saved(model)\n    }\n    dispatch{{ $rawName }}Event(&{{ $rawName }}Saved{Model: model})\n  }\n  \n  return err\n}\n\n{{- if .Model.KeyStrategy }}\n{{- $keyField := .Model.PrimaryKeyField }}\n{{- $createdAt := \"\" }}\n{{- $updatedAt := \"\" }}\n{{- if .Model.Annotations.Has \"timestamps\" }}\n{{- $createdAt = .Model.Annotations.Arg \"timestamps\" 0 \"created_at\" }}\n{{- $updatedAt = .Model.Annotations.Arg \"timestamps\" 1 \"updated_at\" }}\n{{- end }}\n\n// insert 生成主键之后插入新的记录，时间字段交给数据库以及 table 处理\nfunc (model *{{ $modelName }}) insert() contracts.Exception {\n  model.{{ $keyField.Name }} = {{ $define }}.PrimaryKeyGenerator(model)\n  if {{ $define }}.Saving != nil {\n    if err := {{ $define }}.Saving(model); err != nil {\n      model.{{ $keyField.Name }} = {{ if eq (goType $keyField) \"string\" }}\"\"{{ else }}0{{ end }}\n      return err\n    }\n  }\n  if err := dispatch{{ $rawName }}Event(&{{ $rawName }}Saving{Model: model}); err != nil {\n    model.{{ $keyField.Name }} = {{ if eq (goType $keyField) \"string\" }}\"\"{{ else }}0{{ end }}\n    return err\n  }\n\n  fields := contracts.Fields{\n    {{- range .Fields }}\n    {{- if not (or (eq .JSONName $softDelete) (eq .JSONName $createdAt) (eq .JSONName $updatedAt)) }}\n    \"{{ .JSONName }}\": {{ if eq .Cast \"json\" }}{{ $rawName }}{{ .Name }}JSON(model.{{ .Name }}){{ else }}model.{{ .Name }}{{ end }},\n    {{- end }}\n    {{- end }}\n  }\n  _, err := {{ $writeQuery }}().CreateE(fields)\n  if err != nil {\n    model.{{ $keyField.Name }} = {{ if eq (goType $keyField) \"string\" }}\"\"{{ else }}0{{ end }}\n    return err\n  }\n\n  model._update = nil\n  if {{ $define }}.Saved != nil {\n    {{ $define }}.Saved(model)\n  }\n  dispatch{{ $rawName }}Event(&{{ $rawName }}Saved{Model: model})\n  return nil\n}\n{{- end }}\n\nfunc (model *{{ $modelName }}) Set(fields contracts.Fields) {\n  for key, value := range fields {\n\n    switch key {\n  {{- range .Fields }}\n      case \"{{ .JSONName }}\":\n        switch v := value.(type) {\n                case {{ goType . }}:\n                  model.Set{{ .Name }}(v)\n                case func() {{ goType . }}:\n                  model.Set{{ .Name }}(v())\n                  {{- if eq .Cast \"json\" }}\n                case {{ $rawName }}{{ .Name }}JSON:\n                  model.Set{{ .Name }}({{ goType . }}(v))\n                  {{- end }}\n                  {{- $type := goType . }}\n                  {{- if ne $type \"string\"}}\n                case string:\n                  {{- if eq $type \"[]byte\" }}\n                  model.Set{{ .Name }}([]byte(v))\n                  {{else if .Oneof }}\n                  vd, err := Unmarshal{{ $type }}([]byte(v))\n                  if err != nil {\n                      logs.Default().Warn(\"Failed to Parse field \"+key)\n                      continue\n                  }\n                  model.Set{{ .Name }}(vd)\n                  {{else}}\n                  var vd {{ goType . }}\n                  err := json.Unmarshal([]byte(v), &vd)\n                  if err != nil {\n                      logs.Default().Warn(\"Failed to Parse field \"+key)\n                      continue\n                  }\n                  model.Set{{ .Name }}(vd)\n                  {{end}}\n                  {{end}}\n                  {{- if ne $type \"[]byte\"}}\n                case []byte:\n                  {{- if eq $type \"string\" }}\n                  model.Set{{ .Name }}(string(v))\n                  {{else if .Oneof }}\n                  vd, err := Unmarshal{{ $type }}(v)\n                  if err != nil {\n                      logs.Default().Warn(\"Failed to Parse field \"+key)\n                      continue\n                  }\n                  model.Set{{ .Name }}(vd)\n                  {{else}}\n                  var vd {{ goType . }}\n                  err := json.Unmarshal(v, &vd)\n                  if err != nil {\n                      logs.Default().Warn(\"Failed to Parse field \"+key)\n                      continue\n                  }\n                  model.Set{{ .Name }}(vd)\n                  {{end}}\n                  {{end}}\n                {{- if isBasicType . }}\n                default:\n                    model.Set{{ .Name }}(cast.{{ convertFunc (goType .) }}(v))\n                {{- end }}\n                }\n    {{- end }}\n    {{- range .Relations }}\n    {{- $relationType := join $rawName  .Name \"Relation\" }}\n    case string({{ $relationType }}):\n        model.Set{{ .Name }}(value.({{ goType . }}))\n    {{- end }}\n    }\n\n  }\n}\n\nfunc (model *{{ $modelName }}) HasField(field string) bool {\n    switch field {\n       case {{ range $index, $field := .Fields }}{{ if $index }},{{ end }}\"{{ $field.JSONName }}\"{{ end }}:\n         return true\n       default:\n         return false\n     }\n}\n\nfunc (model *{{ $modelName }}) Only(key ...string) contracts.Fields {\n  var fields = make(contracts.Fields)\n  for _, k := range key {\n  {{- range .Fields }}\n    if k == \"{{ .JSONName }}\" {\n      fields[k] = model.Get{{ .Name }}()\n      continue\n    }\n  {{- end }}\n  \n    if {{ $define }}.Appends[k] != nil {\n     fields[k] = {{ $define }}.Appends[k](model)\n    }\n  }\n  return fields\n}\n\nfunc (model *{{ $modelName }}) Get(key string) any {\n    switch key {\n        {{- range $index, $item := .Fields }}\n            case \"{{ .JSONName }}\":\n              return model.Get{{ .Name }}()\n        {{- end }}\n    }\n\n    if value, exists := model._append[key]; exists {\n      return value\n    }\n\n    if fn, exists := {{ $define }}.Appends[key]; exists {\n        model._append[key] = fn(model)\n      return model._append[key]\n    }\n\n     switch contracts.RelationType(key) {\n            {{- range $index, $item := .Relations }}\n            {{- $relationType := join $rawName  .Name \"Relation\" }}\n                case {{ $relationType }}:\n                  return model.{{ .Name }}()\n            {{- end }}\n        }\n\n  return nil\n}\n\nfunc (model *{{ $modelName }}) Except(keys ...string) contracts.Fields {\n  var excepts = map[string]struct{}{}\n  for _, k := range keys {\n    excepts[k] = struct{}{}\n  }\n  var fields = make(contracts.Fields)\n  for key, value := range model.ToFields() {\n    if _, ok := excepts[key]; ok {\n      continue\n    }\n    fields[key] = value\n  }\n  return fields\n}\n\nfunc (model *{{ $modelName }}) ToFields() contracts.Fields {\n    if model == nil {\n        return nil\n    }\n\n  model.Hidden({{ $define }}.Hidden...)\n\n  fields := contracts.Fields{}\n\n    {{- range .Fields }}\n    if _,exists := model._hidden[\"{{ .JSONName }}\"]; !exists {\n        fields[\"{{ .JSONName }}\"] = model.Get{{ .Name }}()\n    }\n    {{- end }}\n\n  for key := range {{ $define }}.Appends {\n    value := model.Get(key)\n    if fieldsProvider, ok := value.(contracts.FieldsProvider); ok {\n        fields[key] = fieldsProvider.ToFields()\n    } else {\n        fields[key] = value\n    }\n  }\n\n  for key := range model._relation_loaded {\n    switch key {\n    {{- range .Relations }}\n    {{- $relationType := join $rawName  .Name \"Relation\" }}\n    case {{ $relationType }}:\n        {{- if .Repeated }}\n        var results []contracts.Fields\n        for _, item := range model._{{ .Name }} {\n            results = append(results, item.ToFields())\n        }\n        fields[string(key)] = results\n        {{- else }}\n        fields[string(key)] = model._{{ .Name }}.ToFields()\n        {{- end }}\n    {{- end }}\n    }\n  }\n\n  for key, value := range model._raw {\n    _, hidden := model._hidden[key]\n    if _, exists := fields[key]; !exists && !hidden {\n        fields[key] = value\n    }\n  }\n\n  return fields\n}\n\nfunc (model *{{ $modelName }}) Update(fields contracts.Fields) contracts.Exception {\n\n  if {{ $define }}.Updating != nil {\n    if err := {{ $define }}.Updating(model, fields); err != nil {\n      return err\n    }\n  }\n  if err := dispatch{{ $rawName }}Event(&{{ $rawName }}Updating{Model: model, Fields: fields}); err != nil {\n    return err\n  }\n  {{- $updateFields := \"fields\" }}\n  {{- if $casts }}\n  {{- $updateFields = \"values\" }}\n  values := model.castFields(fields)\n  {{- end }}\n\n  if model._update != nil {\n    utils.MergeFields(model._update, {{ $updateFields }})\n  }\n\n\n  {{- if $version }}\n  {{- $versionField := .Model.VersionField }}\n  expected := model.{{ $versionField.Name }}\n  if value, exists := fields[\"{{ $version }}\"]; exists {\n    expected = cast.{{ convertFunc (goType $versionField) }}(value)\n  }\n  rows, err := {{ $writeQuery }}().{{ $whereKey }}.Where(\"{{ $version }}\", expected).UpdateE(model.versionedFields({{ $updateFields }}))\n  if err == nil && rows == 0 {\n    err = &{{ $rawName }}VersionConflictException{Key: model.GetPrimaryKey(), Version: expected}\n  }\n  {{- else }}\n\n  _, err := {{ $writeQuery }}().{{ $whereKey }}.UpdateE({{ $updateFields }})\n  {{- end }}\n\n  if err == nil {\n    model.{{ if $hydrate }}hydrate{{ else }}Set{{ end }}({{ $updateFields }})\n    {{- if $version }}\n    model.{{ .Model.VersionField.Name }} = expected + 1\n    {{- end }}\n    model._update = nil\n    if {{ $define }}.Updated != nil {\n      {{ $define }}.Updated(model, fields)\n    }\n    dispatch{{ $rawName }}Event(&{{ $rawName }}Updated{Model: model, Fields: fields})\n  }\n\n  return err\n}\n\n{{- if $version }}\n{{- $versionType := goType .Model.VersionField }}\n\n// {{ $rawName }}VersionConflictException 乐观锁冲突，记录已经被其他请求修改\ntype {{ $rawName }}VersionConflictException struct {\n  Key     any\n  Version {{ $versionType }}\n}\n\nfunc (e *{{ $rawName }}VersionConflictException) Error() string {\n  return fmt.Sprintf(\"{{ $tableName }} 的记录 %v 已经被修改，版本 %d 已过期\", e.Key, e.Version)\n}\n\nfunc (e *{{ $rawName }}VersionConflictException) GetPrevious() contracts.Exception {\n  return nil\n}\n\n// versionedFields 版本号不能由调用方设置，更新时在数据库中自增\nfunc (model *{{ $modelName }}) versionedFields(fields contracts.Fields) contracts.Fields {\n  values := contracts.Fields{}\n  for key, value := range fields {\n    if key != \"{{ $version }}\" {\n      values[key] = value\n    }\n  }\n  values[\"{{ $version }}\"] = querybuilder.Expression(\"{{ $version }} + 1\")\n  return values\n}\n{{- end }}\n\nfunc (model *{{ $modelName }}) Refresh() contracts.Exception {\n  fields, err := table.ArrayQuery(\"{{ $tableName }}\").{{ $whereKey }}.FirstE()\n  if err != nil {\n    return err\n  }\n\n  model.{{ if $hydrate }}hydrate{{ else }}Set{{ end }}(*fields)\n  return nil\n}\n\n{{- if $casts }}\n\n// castFields 把 Update 传入的值转换成数据库中存储的值，加密以及哈希字段经过 Setter，json 字段使用 Value 序列化\nfunc (model *{{ $modelName }}) castFields(fields contracts.Fields) contracts.Fields {\n  values := contracts.Fields{}\n  for key, value := range fields {\n    switch key {\n    {{- range .Fields }}\n    {{- if eq .Cast \"json\" }}\n    case \"{{ .JSONName }}\":\n      if v, ok := value.({{ goType . }}); ok {\n        value = {{ $rawName }}{{ .Name }}JSON(v)\n      }\n    {{- else if .Cast }}\n    case \"{{ .JSONName }}\":\n      if {{ $define }}.{{ .Name }}Setter != nil {\n        value = {{ $define }}.{{ .Name }}Setter(model, cast.ToString(value))\n      }\n    {{- end }}\n    {{- end }}\n    }\n    values[key] = value\n  }\n  return values\n}\n{{- end }}\n\n{{- if $hydrate }}\n\n// hydrate{{ $modelName }} 通过数据库中的记录创建模型，加密以及哈希字段已经是存储的值，不再经过 Setter\nfunc hydrate{{ $modelName }}(fields contracts.Fields) *{{ $modelName }} {\n  var model = {{ $modelName }}{\n    _raw: fields,\n  }\n  model.hydrate(fields)\n  return &model\n}\n\nfunc (model *{{ $modelName }}) hydrate(fields contracts.Fields) {\n  values := contracts.Fields{}\n  for key, value := range fields {\n    switch key {\n    {{- range .Fields }}\n    {{- if and .Cast (ne .Cast \"json\") }}\n    case \"{{ .JSONName }}\":\n      model.{{ .Name }} = cast.ToString(value)\n    {{- end }}\n    {{- end }}\n    default:\n      values[key] = value\n    }\n  }\n  model.Set(values)\n}\n{{- end }}\n\n{{- if $softDelete }}\n{{- $softDeleteField := .Model.SoftDeleteField.Name }}\n\n// Delete 软删除，只设置 {{ $softDelete }}，需要真正删除时使用 ForceDelete\nfunc (model *{{ $modelName }}) Delete() contracts.Exception {\n\n  if {{ $define }}.Deleting != nil {\n    if err := {{ $define }}.Deleting(model); err != nil {\n      return err\n    }\n  }\n  if err := dispatch{{ $rawName }}Event(&{{ $rawName }}Deleting{Model: model}); err != nil {\n    return err\n  }\n\n  deletedAt := carbon.Now().ToDateTimeString()\n  _, err := {{ $writeQuery }}().{{ $whereKey }}.UpdateE(contracts.Fields{\"{{ $softDelete }}\": deletedAt})\n  if err == nil {\n    model.{{ $softDeleteField }} = deletedAt\n    if {{ $define }}.Deleted != nil {\n      {{ $define }}.Deleted(model)\n    }\n    dispatch{{ $rawName }}Event(&{{ $rawName }}Deleted{Model: model})\n  }\n\n  return err\n}\n\n// Restore 恢复已经软删除的记录\nfunc (model *{{ $modelName }}) Restore() contracts.Exception {\n  _, err := {{ $writeQuery }}().{{ $whereKey }}.UpdateE(contracts.Fields{\"{{ $softDelete }}\": nil})\n  if err == nil {\n    model.{{ $softDeleteField }} = \"\"\n  }\n\n  return err\n}\n\n// Trashed 是否已经被软删除\nfunc (model *{{ $modelName }}) Trashed() bool {\n  return model.{{ $softDeleteField }} != \"\"\n}\n{{- end }}\n\n{{ if $softDelete }}// ForceDelete 从数据库中删除记录，不经过软删除\nfunc (model *{{ $modelName }}) ForceDelete() contracts.Exception {\n{{- else -}}\nfunc (model *{{ $modelName }}) Delete() contracts.Exception {\n{{- end }}\n\n  if {{ $define }}.Deleting != nil {\n    if err := {{ $define }}.Deleting(model); err != nil {\n      return err\n    }\n  }\n  if err := dispatch{{ $rawName }}Event(&{{ $rawName }}Deleting{Model: model}); err != nil {\n    return err\n  }\n\n  _, err := {{ $writeQuery }}().{{ $whereKey }}.DeleteE()\n  if err == nil {\n    if {{ $define }}.Deleted != nil {\n      {{ $define }}.Deleted(model)\n    }\n    dispatch{{ $rawName }}Event(&{{ $rawName }}Deleted{Model: model})\n  }\n\n  return err\n}\n\n\nfunc (model *{{ $modelName }}) GetPrimaryKey() any {\n  if {{ $define }}.PrimaryKeyGetter != nil {\n    return {{ $define }}.PrimaryKeyGetter(model)\n  }\n  {{- if .Model.CompositeKey }}\n\n  return []any{ {{- range $index, $column := .Model.PrimaryKeys }}{{ if $index }}, {{ end }}model.{{ toCamelCase $column }}{{ end -}} }\n}\n\n// PrimaryKeyFields 联合主键的全部字段，用于定位当前记录\nfunc (model *{{ $modelName }}) PrimaryKeyFields() contracts.Fields {\n  return contracts.Fields{\n    {{- range .Model.PrimaryKeys }}\n    \"{{ . }}\": model.{{ toCamelCase . }},\n    {{- end }}\n  }\n}\n  {{- else }}\n\n  return model.{{ toCamelCase $primaryKey }}\n}\n  {{- end }}\n\n{{- if .Model.Authenticatable }}\nfunc (model *{{ $modelName }}) GetAuthenticatableKey() string {\n  return fmt.Sprintf(\"%v\", model.GetPrimaryKey())\n}\n\nfunc {{ .Model.RawName }}AuthProvider(identify string) contracts.Authenticatable {\n  return {{ .Model.RawName }}Query().Find(identify)\n}\n\n{{- end }}\n\n\n{{- range .Fields }}\n\nfunc (model *{{ $modelName }}) Get{{ .Name }}() {{ goType . }} {\n  if {{ $define }}.{{ .Name }}Getter != nil {\n    return {{ $define }}.{{ .Name }}Getter(model, model.{{ .Name }})\n  }\n  return model.{{ .Name }}\n}\n\nfunc (model *{{ $modelName }}) Set{{ .Name }}(value {{ goType . }}) {\n  if {{ $define }}.{{ .Name }}Setter != nil {\n    value = {{ $define }}.{{ .Name }}Setter(model, value)\n  }\n\n  {{- if eq .Cast \"json\" }}\n\n  if model._update == nil {\n    model._update = contracts.Fields{\"{{ .JSONName }}\": {{ $rawName }}{{ .Name }}JSON(value)}\n  } else {\n    model._update[\"{{ .JSONName }}\"] = {{ $rawName }}{{ .Name }}JSON(value)\n  }\n  {{- else }}\n\n  if model._update == nil {\n    model._update = contracts.Fields{\"{{ .JSONName }}\": value}\n  } else {\n    model._update[\"{{ .JSONName }}\"] = value\n  }\n  {{- end }}\n  model.{{ .Name }} = value\n}\n\n{{- if eq .Cast \"hashed\" }}\n\n// Check{{ .Name }} 校验明文与保存的 {{ .JSONName }} 哈希是否一致\nfunc (model *{{ $modelName }}) Check{{ .Name }}(value string) bool {\n  return application.Get(\"hashing\").(contracts.Hasher).Check(value, model.{{ .Name }}, nil)\n}\n{{- else if eq .Cast \"json\" }}\n{{- $jsonType := join $rawName .Name \"JSON\" }}\n\n// {{ $jsonType }} {{ .JSONName }} 在数据库中以 json 保存\ntype {{ $jsonType }} {{ goType . }}\n\nfunc (value {{ $jsonType }}) Value() (driver.Value, error) {\n  return json.Marshal({{ goType . }}(value))\n}\n\nfunc (value *{{ $jsonType }}) Scan(src any) error {\n  switch v := src.(type) {\n  case nil:\n    var zero {{ $jsonType }}\n    *value = zero\n    return nil\n  case []byte:\n    return json.Unmarshal(v, value)\n  case string:\n    return json.Unmarshal([]byte(v), value)\n  }\n  return fmt.Errorf(\"{{ $jsonType }}: 不支持从 %T 读取\", src)\n}\n{{- end }}\n\n{{- if .Annotations.Has \"carbon\" }}\nfunc (model *{{ $modelName }}) Get{{ .Name }}Carbon() carbon.Carbon {\n  return carbon.Parse(model.Get{{ .Name }}())\n}\n{{- end }}\n\n\n{{- end }}\n\n{{- range .Relations }}\n{{- $relationType := join $rawName  .Name \"Relation\" }}\n{{- $relationItemType := substring (goType .) 1 }}\n{{- $relationQueryType := substring (goType .) 1 }}\n{{- $throughName := \"\" }}\n\n{{- if .Repeated }}\n{{- $relationItemType = substring (goType .) 3 }}\n{{- $relationQueryType = substring (goType .) 3 }}\n{{- end }}\n\n\n{{- $relationQuery := replace $relationItemType \"Model\" \"Query\" }}\n{{- $foreignKey := \"\" }}\n{{- $localKey := \"\" }}\n{{- $localQuery := join .Name \"Query\" }}\n\n{{- if (.Annotations.Has \"belongsTo\") }}\n{{- $throughName = \"@belongsTo\" }}\n{{- $foreignKey = .Annotations.Arg \"belongsTo\" 0 \"id\" }}\n{{ $localKey = .Annotations.Arg \"belongsTo\" 1 (join .JSONName \"_id\") }}\n\n{{- else if (.Annotations.Has \"hasOne\") }}\n{{- $throughName = \"@hasOne\" }}\n\n{{- $localKey = .Annotations.Arg \"hasOne\" 0 \"id\" }}\n{{- $foreignKey = .Annotations.Arg \"hasOne\" 1 (join (toLower $rawName) \"_id\") }}\n\n{{- else if (.Annotations.Has \"hasMany\") }}\n{{- $throughName = \"@hasMany\" }}\n\n{{- $relationQuery = replace $relationItemType \"Model\" \"Query\" }}\n{{- $foreignKey = .Annotations.Arg \"hasMany\" 0 (join .JSONName \"_id\") }}\n{{- $localKey = .Annotations.Arg \"hasMany\" 1 \"id\" }}\n{{- $relationQueryType = $relationItemType }}\n\n{{- end }}\n\n{{- if .Repeated }}\n// {{ $localQuery }} {{ $throughName }}\nfunc (model *{{ $modelName }}) {{ .Name }}() {{ goType . }} {\n    _, exists := model._relation_loaded[{{ $relationType }}]\n    if !exists {\n        value := model.{{ $localQuery }}().Get().ToArray()\n        model.Set{{ .Name }}(value)\n        return value\n    }\n    return model._{{ .Name }}\n}\n\n{{- else }}\n// {{ $localQuery }} {{ $throughName }}\nfunc (model *{{ $modelName }}) {{ .Name }}() {{ goType . }} {\n    _, exists := model._relation_loaded[{{ $relationType }}]\n    if !exists {\n        value := model.{{ $localQuery }}().First()\n        model.Set{{ .Name }}(value)\n        return value\n    }\n    return model._{{ .Name }}\n}\n{{- end }}\n\n\n{{- if or (.Annotations.Has \"hasManyThrough\") (.Annotations.Has \"belongsToMany\") (.Annotations.Has \"hasOneThrough\")  }}\n\n{{- $throughName := \"@hasManyThrough\" }}\n\n{{- if (.Annotations.Has \"belongsToMany\") }}\n{{- $throughName = \"@belongsToMany\" }}\n{{- else if (.Annotations.Has \"hasOneThrough\") }}\n{{- $throughName = \"@hasOneThrough\" }}\n{{- end }}\n\n\n{{- $midTable := .Annotations.Arg $throughName 0 \"mid_table\" }}\n{{- $firstKey := .Annotations.Arg $throughName 1 (join (toLower $rawName) \"_id\") }}\n{{- $secondKey := .Annotations.Arg $throughName 2 \"id\" }}\n{{- $localKey := .Annotations.Arg $throughName 3 \"id\" }}\n{{- $secondLocalKey := .Annotations.Arg $throughName 4 (join $midTable \"_id\") }}\n// {{ $localQuery }} {{ $throughName }}\nfunc (model *{{ $modelName }}) {{ $localQuery }}() contracts.QueryBuilder[{{ $relationQueryType }}] {\n    query := {{ $relationQuery }}()\n    return query.\n        Where(\"{{ $midTable }}.{{ $firstKey }}\", model.Get(\"{{ $localKey }}\")).\n        Join(\"{{ $midTable }}\", \"{{ $midTable }}.{{ $secondLocalKey }}\",  \"=\", fmt.Sprintf(\"%s.{{ $secondKey }}\", query.GetTableName()))\n}\n\n{{- else }}\n// {{ $localQuery }} {{ $throughName }}\nfunc (model *{{ $modelName }}) {{ $localQuery }}() contracts.QueryBuilder[{{ $relationQueryType }}] {\n    return {{ $relationQuery }}().Where(\"{{ $foreignKey }}\", model.Get(\"{{ $localKey }}\"))\n}\n{{- end }}\n\n// {{ $localQuery }} {{ $throughName }}\nfunc (model *{{ $modelName }}) Set{{ .Name }}(value {{ goType . }}) {\n    if model._relation_loaded == nil {\n        model._relation_loaded = make(map[contracts.RelationType]struct{})\n    }\n    model._relation_loaded[{{ $relationType }}] = struct{}{}\n    model._{{ .Name }} = value\n}\n\n{{- end }}\n\n{{ end }}\n\n\n{{- define \"data\" -}}\npackage {{ .Package }}\n\nimport (\n{{- if .Model.Oneofs }}\n\"encoding/json\"\n\"fmt\"\n{{- end }}\n{{- range .Imports }}\n{{ .Alias }} \"{{ .Pkg }}\"\n{{- end }}\n)\n\ntype {{ .Model.Name }} struct {\n  {{- range .Fields }}\n  {{ .Name }} {{ goType . }} `{{ toTags . }}`\n  {{- end }}\n}\n\n{{- template \"oneofs\" .Model }}\n\n{{ end }}\n\n{{- define \"request\" -}}\npackage {{ .Package }}\n\nimport (\n  {{- if .Model.Oneofs }}\n  \"encoding/json\"\n  \"fmt\"\n  {{- end }}\n  {{- if .Model.HasRule \"regex\" }}\n  \"regexp\"\n  \"github.com/goal-web/supports/exceptions\"\n  {{- end }}\n  {{- range .Imports }}\n  {{ .Alias }} \"{{ .Pkg }}\"\n  {{- end }}\n  \"github.com/goal-web/contracts\"\n)\n\ntype {{ .Model.Name }} struct {\n  {{- range .Fields }}\n  {{ .Name }} {{ goType . }} `{{ toTags . }}`\n  {{- end }}\n}\n\n{{- template \"oneofs\" .Model }}\n\n{{- if .Model.Version }}\n\n// WithVersion 将客户端传回的版本号加入到更新的字段中，配合模型的乐观锁使用\nfunc (model *{{ .Model.Name }}) WithVersion(fields contracts.Fields) contracts.Fields {\n  fields[\"{{ .Model.Version }}\"] = model.{{ .Model.VersionField.Name }}\n  return fields\n}\n{{- end }}\n\n{{- if .Model.HasRule \"regex\" }}\n{{- $modelName := .Model.Name }}\n\nvar (\n  {{- range .Fields }}\n  {{- if .Rule \"regex\" }}\n  pattern{{ $modelName }}{{ .Name }} = regexp.MustCompile({{ printf \"%q\" (.Rule \"regex\").Value }})\n  {{- end }}\n  {{- end }}\n)\n\n// ValidatePatterns 校验 @validate 中声明的正则表达式，空值交给 required 处理\nfunc (model *{{ $modelName }}) ValidatePatterns() contracts.Exception {\n  {{- range .Fields }}\n  {{- if .Rule \"regex\" }}\n  {{- if eq (substring (goType .) 0 1) \"*\" }}\n  if model.{{ .Name }} != nil && *model.{{ .Name }} != \"\" && !pattern{{ $modelName }}{{ .Name }}.MatchString(*model.{{ .Name }}) {\n  {{- else }}\n  if model.{{ .Name }} != \"\" && !pattern{{ $modelName }}{{ .Name }}.MatchString(model.{{ .Name }}) {\n  {{- end }}\n    return exceptions.New(\"{{ .JSONName }} 的格式不正确\")\n  }\n  {{- end }}\n  {{- end }}\n  return nil\n}\n{{- end }}\n\nfunc (model *{{ .Model.Name }}) ToFields() contracts.Fields {\n  if model == nil {\n    return nil\n  }\n  fields := contracts.Fields{\n  {{- range .Fields }}\n    \"{{ .JSONName }}\": model.{{ .Name }},\n  {{- end }}\n  }\n  return fields\n}\n\n{{ end }}\n\n{{- define \"result\" -}}\npackage {{ .Package }}\n\nimport (\n    \"github.com/goal-web/contracts\"\n  {{- if .Model.Oneofs }}\n    \"encoding/json\"\n    \"fmt\"\n  {{- end }}\n  {{- range .Imports }}\n  {{ .Alias }} \"{{ .Pkg }}\"\n  {{- end }}\n)\n\n{{- $resultName := .Model.Name }}\n\ntype {{ $resultName }} struct {\n  {{- range .Fields }}\n  {{ .Name }} {{ goType . }} `{{ toTags . }}`\n  {{- end }}\n}\n\n{{- template \"oneofs\" .Model }}\n\nfunc (result *{{ $resultName }}) ToFields() contracts.Fields {\n\n    fields := contracts.Fields{\n        {{- range .Fields }}\n            {{- if eq (fieldMsg .) nil }}\n            \"{{ .JSONName }}\": result.{{ .Name }},\n            {{- else if and (ne .Repeated true) .IsModel }}\n            \"{{ .JSONName }}\": result.{{ .Name }}.ToFields(),\n            {{- else }}\n            \"{{ .JSONName }}\": result.{{ .Name }},\n            {{- end }}\n        {{- end }}\n    }\n\n    {{- range .Fields }}\n        {{- if and .Repeated (ne (fieldMsg .) nil) (fieldMsg .).IsModel }}\n        {{ .JSONName }}List := make([]contracts.Fields, len(result.{{ .Name }}))\n        for i, item := range result.{{ .Name }} {\n            {{ .JSONName }}List[i] = item.ToFields()\n        }\n        fields[\"{{ .JSONName }}\"] = {{ .JSONName }}List\n        {{- end }}\n    {{- end }}\n\n\n    return fields\n}\n\n{{ end }}\n\n{{- define \"modelEvents\" }}\n{{- $rawName := .RawName }}\n{{- $modelName := .Name }}\n\n// {{ $rawName }} 模型生命周期事件的名称，事件都是同步分发的，监听器在操作返回之前执行完毕\nconst (\n  {{ $rawName }}SavingEvent = \"{{ .TableName }}.saving\"\n  {{ $rawName }}SavedEvent = \"{{ .TableName }}.saved\"\n  {{ $rawName }}UpdatingEvent = \"{{ .TableName }}.updating\"\n  {{ $rawName }}UpdatedEvent = \"{{ .TableName }}.updated\"\n  {{ $rawName }}DeletingEvent = \"{{ .TableName }}.deleting\"\n  {{ $rawName }}DeletedEvent = \"{{ .TableName }}.deleted\"\n)\n\n// {{ $rawName }}EventAbort -ing 事件的监听器通过 Abort 取消本次操作，多个监听器时以第一个错误为准\ntype {{ $rawName }}EventAbort struct {\n  mutex sync.Mutex\n  err   contracts.Exception\n}\n\nfunc (abort *{{ $rawName }}EventAbort) Abort(err contracts.Exception) {\n  abort.mutex.Lock()\n  defer abort.mutex.Unlock()\n  if abort.err == nil {\n    abort.err = err\n  }\n}\n\nfunc (abort *{{ $rawName }}EventAbort) Err() contracts.Exception {\n  abort.mutex.Lock()\n  defer abort.mutex.Unlock()\n  return abort.err\n}\n\n\n// {{ $rawName }}Saving 保存之前触发，监听器可以调用 Abort 取消保存\ntype {{ $rawName }}Saving struct {\n  {{ $rawName }}EventAbort\n  Model *{{ $modelName }}\n}\n\nfunc (event *{{ $rawName }}Saving) Event() string {\n  return {{ $rawName }}SavingEvent\n}\n\nfunc (event *{{ $rawName }}Saving) Sync() bool {\n  return true\n}\n\n// {{ $rawName }}Saved 保存之后触发\ntype {{ $rawName }}Saved struct {\n  Model *{{ $modelName }}\n}\n\nfunc (event *{{ $rawName }}Saved) Event() string {\n  return {{ $rawName }}SavedEvent\n}\n\nfunc (event *{{ $rawName }}Saved) Sync() bool {\n  return true\n}\n\n// {{ $rawName }}Updating 更新之前触发，监听器可以调用 Abort 取消更新\ntype {{ $rawName }}Updating struct {\n  {{ $rawName }}EventAbort\n  Model *{{ $modelName }}\n  Fields contracts.Fields\n}\n\nfunc (event *{{ $rawName }}Updating) Event() string {\n  return {{ $rawName }}UpdatingEvent\n}\n\nfunc (event *{{ $rawName }}Updating) Sync() bool {\n  return true\n}\n\n// {{ $rawName }}Updated 更新之后触发\ntype {{ $rawName }}Updated struct {\n  Model *{{ $modelName }}\n  Fields contracts.Fields\n}\n\nfunc (event *{{ $rawName }}Updated) Event() string {\n  return {{ $rawName }}UpdatedEvent\n}\n\nfunc (event *{{ $rawName }}Updated) Sync() bool {\n  return true\n}\n\n// {{ $rawName }}Deleting 删除之前触发，监听器可以调用 Abort 取消删除\ntype {{ $rawName }}Deleting struct {\n  {{ $rawName }}EventAbort\n  Model *{{ $modelName }}\n}\n\nfunc (event *{{ $rawName }}Deleting) Event() string {\n  return {{ $rawName }}DeletingEvent\n}\n\nfunc (event *{{ $rawName }}Deleting) Sync() bool {\n  return true\n}\n\n// {{ $rawName }}Deleted 删除之后触发\ntype {{ $rawName }}Deleted struct {\n  Model *{{ $modelName }}\n}\n\nfunc (event *{{ $rawName }}Deleted) Event() string {\n  return {{ $rawName }}DeletedEvent\n}\n\nfunc (event *{{ $rawName }}Deleted) Sync() bool {\n  return true\n}\n\n// {{ $rawName }}EventListener 函数形式的事件监听器\ntype {{ $rawName }}EventListener func(event contracts.Event)\n\nfunc (listener {{ $rawName }}EventListener) Handle(event contracts.Event) {\n  listener(event)\n}\n\n// On{{ $rawName }}Saving 注册 {{ $rawName }}Saving 事件的监听器\nfunc On{{ $rawName }}Saving(dispatcher contracts.EventDispatcher, listener func(event *{{ $rawName }}Saving)) {\n  dispatcher.Register({{ $rawName }}SavingEvent, {{ $rawName }}EventListener(func(event contracts.Event) {\n    listener(event.(*{{ $rawName }}Saving))\n  }))\n}\n\n// On{{ $rawName }}Saved 注册 {{ $rawName }}Saved 事件的监听器\nfunc On{{ $rawName }}Saved(dispatcher contracts.EventDispatcher, listener func(event *{{ $rawName }}Saved)) {\n  dispatcher.Register({{ $rawName }}SavedEvent, {{ $rawName }}EventListener(func(event contracts.Event) {\n    listener(event.(*{{ $rawName }}Saved))\n  }))\n}\n\n// On{{ $rawName }}Updating 注册 {{ $rawName }}Updating 事件的监听器\nfunc On{{ $rawName }}Updating(dispatcher contracts.EventDispatcher, listener func(event *{{ $rawName }}Updating)) {\n  dispatcher.Register({{ $rawName }}UpdatingEvent, {{ $rawName }}EventListener(func(event contracts.Event) {\n    listener(event.(*{{ $rawName }}Updating))\n  }))\n}\n\n// On{{ $rawName }}Updated 注册 {{ $rawName }}Updated 事件的监听器\nfunc On{{ $rawName }}Updated(dispatcher contracts.EventDispatcher, listener func(event *{{ $rawName }}Updated)) {\n  dispatcher.Register({{ $rawName }}UpdatedEvent, {{ $rawName }}EventListener(func(event contracts.Event) {\n    listener(event.(*{{ $rawName }}Updated))\n  }))\n}\n\n// On{{ $rawName }}Deleting 注册 {{ $rawName }}Deleting 事件的监听器\nfunc On{{ $rawName }}Deleting(dispatcher contracts.EventDispatcher, listener func(event *{{ $rawName }}Deleting)) {\n  dispatcher.Register({{ $rawName }}DeletingEvent, {{ $rawName }}EventListener(func(event contracts.Event) {\n    listener(event.(*{{ $rawName }}Deleting))\n  }))\n}\n\n// On{{ $rawName }}Deleted 注册 {{ $rawName }}Deleted 事件的监听器\nfunc On{{ $rawName }}Deleted(dispatcher contracts.EventDispatcher, listener func(event *{{ $rawName }}Deleted)) {\n  dispatcher.Register({{ $rawName }}DeletedEvent, {{ $rawName }}EventListener(func(event contracts.Event) {\n    listener(event.(*{{ $rawName }}Deleted))\n  }))\n}\n\n// dispatch{{ $rawName }}Event 通过事件服务分发模型事件，没有注册事件服务时忽略，返回 -ing 事件中监听器取消操作的原因\nfunc dispatch{{ $rawName }}Event(event contracts.Event) contracts.Exception {\n  events.Dispatch(event)\n  if abortable, ok := event.(interface{ Err() contracts.Exception }); ok {\n    return abortable.Err()\n  }\n  return nil\n}\n{{- end }}\n\n{{- define \"oneofs\" -}}\n{{- range .Oneofs }}\n{{- $oneof := . }}\n\n// {{ .TypeName }} oneof {{ .JSONName }}，只能设置其中一个值\ntype {{ .TypeName }} interface {\n  is{{ .TypeName }}()\n}\n{{- range .Cases }}\n{{- $caseName := join $oneof.TypeName .Name }}\n\ntype {{ $caseName }} struct {\n  {{ .Name }} {{ goType . }} `json:\"{{ .JSONName }}\"`\n}\n\nfunc (*{{ $caseName }}) is{{ $oneof.TypeName }}() {}\n\n// MarshalJSON 通过 $case 标记设置的是哪个值\nfunc (value *{{ $caseName }}) MarshalJSON() ([]byte, error) {\n  type plain {{ $caseName }}\n  return json.Marshal(struct {\n    Case string `json:\"$case\"`\n    *plain\n  }{\"{{ .JSONName }}\", (*plain)(value)})\n}\n{{- end }}\n\n// Unmarshal{{ .TypeName }} 解析 oneof {{ .JSONName }}，同时设置多个值时返回错误\nfunc Unmarshal{{ .TypeName }}(data []byte) ({{ .TypeName }}, error) {\n  if len(data) == 0 {\n    return nil, nil\n  }\n  var fields map[string]json.RawMessage\n  if err := json.Unmarshal(data, &fields); err != nil || fields == nil {\n    return nil, err\n  }\n\n  var value {{ .TypeName }}\n  var cases []string\n  {{- range .Cases }}\n  if raw, exists := fields[\"{{ .JSONName }}\"]; exists {\n    var item {{ $oneof.TypeName }}{{ .Name }}\n    if err := json.Unmarshal(raw, &item.{{ .Name }}); err != nil {\n      return nil, err\n    }\n    value = &item\n    cases = append(cases, \"{{ .JSONName }}\")\n  }\n  {{- end }}\n\n  if len(cases) > 1 {\n    return nil, fmt.Errorf(\"oneof {{ .JSONName }} 只能设置一个值，实际设置了 %v\", cases)\n  }\n  if raw, exists := fields[\"$case\"]; exists {\n    var name string\n    if err := json.Unmarshal(raw, &name); err != nil {\n      return nil, err\n    }\n    if len(cases) == 0 || cases[0] != name {\n      return nil, fmt.Errorf(\"oneof {{ .JSONName }} 的 $case 为 %s，但是没有设置对应的值\", name)\n    }\n  }\n  return value, nil\n}\n{{- end }}\n\n{{- if .Oneofs }}\n\n// UnmarshalJSON oneof 字段需要根据 $case 解析成具体的类型\nfunc (model *{{ .Name }}) UnmarshalJSON(data []byte) error {\n  type plain {{ .Name }}\n  var raw struct {\n    *plain\n    {{- range .Oneofs }}\n    {{ .Name }} json.RawMessage `json:\"{{ .JSONName }}\"`\n    {{- end }}\n  }\n  raw.plain = (*plain)(model)\n  if err := json.Unmarshal(data, &raw); err != nil {\n    return err\n  }\n\n  var err error\n  {{- range .Oneofs }}\n  if model.{{ .Name }}, err = Unmarshal{{ .TypeName }}(raw.{{ .Name }}); err != nil {\n    return err\n  }\n  {{- end }}\n  return nil\n}\n{{- end }}\n{{- end }}\n\n{{- define \"enum\" -}}\npackage {{ .Package }}\n\n{{- $enumName := .Name }}\ntype {{ .Name }} int\nconst (\n  {{- range .Values }}\n  {{- $FieldName := sprintf \"%s%s\" $enumName .Name }}\n\n  {{ toComments $FieldName .Comments }}\n  {{ $enumName }}{{ .Name }} {{ $enumName }} = {{ .Value }}\n  {{- end }}\n  {{ $enumName }}Unknown {{ $enumName }} = -1000\n\n)\n\n\nfunc (item {{ $enumName }}) String() string {\n    switch item {\n      {{- range .Values }}\n        case {{ $enumName }}{{ .Name }}:\n          return \"{{ .Name }}\"\n      {{- end }}\n        default:\n          return \"Unknown\"\n  }\n}\n\nfunc (item {{ $enumName }}) Message() string {\n    switch item {\n      {{- range .Values }}\n        case {{ $enumName }}{{ .Name }}:\n          return \"{{ .Message }}\"\n      {{- end }}\n        default:\n          return \"Unknown\"\n  }\n}\n\nfunc Parse{{ $enumName }}FromString(msg string) {{ $enumName }} {\n    switch msg {\n    {{- range .Values }}\n        case \"{{ .Name }}\":\n          return {{ $enumName }}{{ .Name }}\n    {{- end }}\n        default:\n          return {{ $enumName }}Unknown\n  }\n}\n\nfunc {{ $enumName }}ValueEnum() map[string]any {\n   return map[string]any{\n      {{- range .Values }}\n        \"{{ .Name }}\": \"{{ .Message }}\",\n      {{- end }}\n   }\n}\n\n\n{{ end }}\n\n\n\n{{- define \"service\" -}}\npackage {{ .Package }}\n\nimport (\n    \"github.com/goal-web/contracts\"\n  {{- range .Imports }}\n  {{ .Alias }} \"{{ .Pkg }}\"\n  {{- end }}\n)\n\n{{- $serviceName := .Name }}\n{{- $define := join .Name \"Define\" }}\n\nvar {{ $define }} {{ $serviceName }}Static\ntype  {{ $serviceName }}Static struct {\n{{- range .Methods }}\n    {{ .Name }} func (req *{{ .InputUsageName }}, ctx contracts.Context) (*{{ .OutputUsageName }}, error)\n{{- end }}\n}\n\n{{- range .Methods }}\n\nfunc {{ $serviceName }}{{ .Name }}(req *{{ .InputUsageName }}, ctx contracts.Context) (*{{ .OutputUsageName }}, error) {\n  if {{ $define }}.{{ .Name }} != nil {\n    return {{ $define }}.{{ .Name }}(req, ctx)\n  }\n  return nil, nil\n}\n{{- end }}\n{{ end }}\n\n\n{{- define \"controller\" -}}\npackage {{ .Package }}\n\nimport (\n  \"github.com/goal-web/contracts\"\n  \"github.com/goal-web/validation\"\n  \"{{ .ResponsePath }}\"\n  svc \"{{ .ImportPath }}\"\n  {{- range .Imports }}\n  {{- if notContains .Pkg \"results\" }}\n  {{ .Alias }} \"{{ .Pkg }}\"\n  {{ end -}}\n  {{- end }}\n)\n\n{{- $serviceName := .Name }}\n{{- $prefix := .Prefix }}\nfunc {{ .Name }}Router(router contracts.HttpRouter) {\n  routeGroup := router.Group(\"{{ $prefix }}\"{{ toMiddlewares .Middlewares }})\n  {{- range .Methods }}\n  {{- $controllerMethod := sprintf \"%s%s\" $serviceName .Name  }}\n  {{- $path := .Path  }}\n  {{- $middlewares := .Middlewares }}\n    {{- range .Method }}\n    routeGroup.{{ . }}(\"{{ $path }}\", {{ $controllerMethod }}{{ toMiddlewares $middlewares }})\n    {{- end }}\n  {{- end }}\n}\n\n\n{{- $usageName := .UsageName }}\n\n{{- range .Methods }}\nfunc {{ $serviceName }}{{ .Name }}(request contracts.HttpRequest) any {\n    var req {{ .InputUsageName }}\n\n    if err:= request.Parse(&req); err != nil {\n      return response.ParseReqErr(err)\n    }\n\n    if err := validation.Struct(req); err != nil {\n      return response.InvalidReq(err)\n    }\n    {{- if .Input.HasRule \"regex\" }}\n\n    if err := req.ValidatePatterns(); err != nil {\n      return response.InvalidReq(err)\n    }\n    {{- end }}\n\n    resp, err := {{ $usageName }}{{ .Name }}(&req, request)\n    if err != nil {\n      return response.BizErr(err)\n    }\n    \n    return response.Success(resp)\n}\n{{- end }}\n{{ end }}")
 
-func GetTemplate(path string) *template.Template {
-	// 读取模板文件
+// templateContent 读取模板文件，文件不存在时使用默认模板
+func templateContent(path string) []byte {
 	tmplContent, err := os.ReadFile(path)
 	if err != nil {
 		logs.Default().WithField("path", path).Warn("模板文件不存在，将使用默认模板")
-		tmplContent = defaultTemplate
+		return defaultTemplate
 	}
+	return tmplContent
+}
 
-	// 初始化模板，并添加函数映射
+// parseTemplate 解析模板，依赖类型注册表的函数绑定到当前生成器上
+func (g *Generator) parseTemplate(path string, tmplContent []byte) (*template.Template, error) {
 	tmpl, err := template.New("codegen").Funcs(template.FuncMap{
-		"sub":             Sub,
-		"convertFunc":     ConvertFunc,
-		"isBasicType":     IsBasicType,
-		"goType":          GoType,
-		"toLower":         strings.ToLower,
-		"toCamelCase":     ToCamelCase,
-		"toSnake":         ToSnakeCase,
-		"toTags":          ToTags,
-		"tsType":          TsType,
-		"replace":         strings.ReplaceAll,
-		"toComments":      ToComments,
-		"sprintf":         fmt.Sprintf,
-		"contains":        strings.Contains,
-		"notContains":     NotContains,
-		"toMiddlewares":   ToMiddlewares,
-		"getComment":      GetComment,
-		"join":            StringJoin,
-		"getIndexComment": GetIndexComment,
-		"hasComment":      HasComment,
-		"substring":       SubString,
-		"fieldMsg":        FieldMsg,
-		"hasMsgComment":   HasMsgComment,
+		"sub":              Sub,
+		"convertFunc":      ConvertFunc,
+		"isBasicType":      IsBasicType,
+		"isQueryable":      g.IsQueryable,
+		"isColumn":         g.IsColumn,
+		"fakeValue":        g.FakeValue,
+		"factoryRelations": g.FactoryRelations,
+		"goType":           g.GoType,
+		"toLower":          strings.ToLower,
+		"toCamelCase":      ToCamelCase,
+		"toSnake":          ToSnakeCase,
+		"toTags":           g.ToTags,
+		"createTable":      g.CreateTable,
+		"dialect":          func() string { return g.config.Dialect },
+		"tsType":           TsType,
+		"replace":          strings.ReplaceAll,
+		"toComments":       ToComments,
+		"sprintf":          fmt.Sprintf,
+		"contains":         strings.Contains,
+		"notContains":      NotContains,
+		"toMiddlewares":    ToMiddlewares,
+		"getComment":       GetComment,
+		"join":             StringJoin,
+		"getIndexComment":  GetIndexComment,
+		"hasComment":       HasComment,
+		"substring":        SubString,
+		"fieldMsg":         g.FieldMsg,
+		"hasMsgComment":    HasMsgComment,
 	}).Parse(string(tmplContent))
 	if err != nil {
-		log.Fatal(err)
+		return nil, NewDiagnostic(scanner.Position{Filename: path}, "template", err)
 	}
-	return tmpl
+	return tmpl, nil
 }
@@ -3,28 +3,11 @@ package gen
 import (
 	"bufio"
 	"fmt"
-	"github.com/emicklei/proto"
 	"os"
 	"path/filepath"
 	"strings"
 )
 
-func getComments(comment *proto.Comment, name string, defaultValue string) []string {
-	var list []string
-	if comment != nil {
-		for _, line := range comment.Lines {
-			if strings.HasPrefix(line, name) {
-				value := strings.TrimPrefix(strings.TrimPrefix(line, name), ":")
-				if value == "" {
-					value = defaultValue
-				}
-				list = append(list, value)
-			}
-		}
-	}
-	return list
-}
-
 // 提取数据的函数
 
 func trim(paths ...string) []string {
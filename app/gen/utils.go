@@ -5,6 +5,7 @@ import (
 	"github.com/emicklei/proto"
 	"github.com/goal-web/collection"
 	"github.com/goal-web/supports/utils"
+	"reflect"
 	"regexp"
 	"strings"
 	"unicode"
@@ -54,6 +55,29 @@ func pluralize(word string) string {
 	}
 }
 
+// singularize pluralize 的逆操作，用于根据表名推导模型名
+func singularize(word string) string {
+	switch {
+	case strings.HasSuffix(word, "ies"):
+		return strings.TrimSuffix(word, "ies") + "y"
+	case strings.HasSuffix(word, "ves"):
+		return strings.TrimSuffix(word, "ves") + "f"
+	case strings.HasSuffix(word, "ses") || strings.HasSuffix(word, "xes") || strings.HasSuffix(word, "zes"):
+		return strings.TrimSuffix(word, "es")
+	case strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss"):
+		return strings.TrimSuffix(word, "s")
+	default:
+		return word
+	}
+}
+
+// ModelName 表名对应的模型名，例如 user_roles 对应 UserRole
+func ModelName(table string) string {
+	words := strings.Split(strings.ToLower(table), "_")
+	words[len(words)-1] = singularize(words[len(words)-1])
+	return ToCamelCase(strings.Join(words, "_"))
+}
+
 func replaceSuffix(content string, trim ...string) string {
 	for _, s := range trim {
 		content = strings.TrimSuffix(content, s)
@@ -82,38 +106,75 @@ func ToCamelCase(s string) string {
 }
 
 // GoType 将 Proto 类型映射为 Go 类型
-func GoType(field *Field) string {
+func (g *Generator) GoType(field *Field) string {
 
 	if field.GoType != "" {
 		return field.GoType
 	}
 
+	if field.Map != nil {
+		return fmt.Sprintf("map[%s]%s", g.GoType(field.Map.Key), g.GoType(field.Map.Value))
+	}
+
 	str := field.UsageName
 	if str == "" {
 		str = field.Type
 	}
 
-	if field.Ptr || field.IsModel || HasComment(field.Comment, "@nullable") {
+	nullable := false
+	if field.WellKnown != nil {
+		str = field.WellKnown.GoType
+		nullable = field.WellKnown.Nullable
+	} else if scalar, exists := goScalarTypes[field.Type]; exists {
+		str = scalar
+	}
+
+	if !nullable && (field.Ptr || field.IsModel || field.Annotations.Has("nullable")) {
 		str = "*" + str
 	}
 	if field.Repeated {
 		str = "[]" + str
 	}
 
-	if msg, exists := usagePackageMap[field.Type]; exists && HasComment(msg.Comment, "@goType") {
-		return GetComment(msg.Comment, "@goType", str)
+	if msg := g.registry.Lookup(field.Type); msg != nil && msg.Annotations.Has("goType") {
+		return msg.Annotations.Value("goType", str)
 	}
 
 	return str
 }
 
+// goScalarTypes proto 中没有同名 go 类型的数值类型，map 的 key 也会用到
+var goScalarTypes = map[string]string{
+	"double":   "float64",
+	"float":    "float32",
+	"sint32":   "int32",
+	"sint64":   "int64",
+	"fixed32":  "uint32",
+	"fixed64":  "uint64",
+	"sfixed32": "int32",
+	"sfixed64": "int64",
+}
+
 // TsType 将 Proto 类型映射为 typescript 类型
 func TsType(field *Field) string {
-	tsTag := GetComment(field.Comment, "@tsType", "")
+	tsTag := field.Annotations.Value("tsType", "")
 	if tsTag != "" {
 		return tsTag
 	}
 
+	if field.Oneof != nil {
+		// discriminated union，通过 $case 区分取值
+		var cases []string
+		for _, item := range field.Oneof.Cases {
+			cases = append(cases, fmt.Sprintf(`{ $case: "%s"; %s: %s }`, item.JSONName, item.JSONName, TsType(item)))
+		}
+		return strings.Join(cases, " | ")
+	}
+
+	if field.Map != nil {
+		return fmt.Sprintf("Record<%s, %s>", tsMapKey(field.Map.Key.Type), TsType(field.Map.Value))
+	}
+
 	types := map[string]string{
 		"int64": "number",
 		"int32": "number",
@@ -122,20 +183,35 @@ func TsType(field *Field) string {
 	var str string
 	if v, exists := types[field.Type]; exists {
 		str = v
+	} else if numericTypes[field.Type] {
+		str = "number"
+	} else if field.WellKnown != nil {
+		str = field.WellKnown.TsType
 	} else {
 		str = field.Type
 	}
 
 	if field.Repeated {
+		if strings.Contains(str, "|") {
+			str = "(" + str + ")"
+		}
 		str = str + "[]"
 	}
 
 	return str
 }
 
+// tsMapKey map 的 key 只能是整数、string 或者 bool，Record 的 key 只能是 string 或者 number，bool 在 json 中是字符串
+func tsMapKey(keyType string) string {
+	if keyType == "string" || keyType == "bool" {
+		return "string"
+	}
+	return "number"
+}
+
 // FieldMsg 将 Proto 类型映射为 Go 类型
-func FieldMsg(field *Field) *Message {
-	return usagePackageMap[field.Type]
+func (g *Generator) FieldMsg(field *Field) *Message {
+	return g.registry.Lookup(field.Type)
 }
 
 // SubString 切割字符串
@@ -155,10 +231,11 @@ func SubString(str string, start int, nums ...int) string {
 	return string(runes[start : start+num])
 }
 
+// HasComment 注释中是否声明了指定的注解，注解名称需要完全一致，@hasOne 不会匹配 @hasOneThrough
 func HasComment(comment *proto.Comment, name string) bool {
 	if comment != nil {
 		for _, line := range comment.Lines {
-			if strings.HasPrefix(line, name) {
+			if annotation, ok := parseAnnotationLine(line); ok && annotation.Name == strings.TrimPrefix(name, "@") {
 				return true
 			}
 		}
@@ -171,7 +248,12 @@ func Sub(v, t int) int {
 }
 
 func IsBasicType(field *Field) bool {
-	if field.IsModel || field.Repeated {
+	if field.IsModel || field.Repeated || field.Oneof != nil || field.Map != nil || field.Cast != "" {
+		return false
+	}
+
+	// 指针类型不能直接使用 cast 转换
+	if field.Ptr || field.Annotations.Has("nullable") {
 		return false
 	}
 
@@ -182,6 +264,26 @@ func IsBasicType(field *Field) bool {
 	return true
 }
 
+// IsColumn 字段是否保存在表中，oneof 以及 db:"-" 的字段不是表的字段
+func (g *Generator) IsColumn(field *Field) bool {
+	if field.IsModel || field.Parent == nil {
+		return false
+	}
+	db := reflect.StructTag(g.ToTags(field)).Get("db")
+	return db != "" && db != "-"
+}
+
+// IsQueryable 字段是否为可以直接比较的列，模型会为这些字段生成强类型的查询方法
+func (g *Generator) IsQueryable(field *Field) bool {
+	if field.Repeated || field.Oneof != nil || field.Map != nil || !g.IsColumn(field) {
+		return false
+	}
+	if field.WellKnown != nil && field.WellKnown.Nullable {
+		return false
+	}
+	return g.baseDBType(field) != "json"
+}
+
 func ConvertFunc(t string) string {
 	return fmt.Sprintf("To%s", CapitalizeFirstLetter(t))
 }
@@ -217,16 +319,15 @@ func HasMsgComment(msg *Message, name string) bool {
 	return false
 }
 
+// GetComment 注解的原始参数，没有声明或者没有参数时返回默认值
 func GetComment(comment *proto.Comment, name string, defaultValue string) string {
 	if comment != nil {
 		for _, line := range comment.Lines {
-			line = strings.TrimPrefix(line, " ")
-			if strings.HasPrefix(line, name) {
-				value := strings.TrimPrefix(strings.TrimPrefix(line, name), ":")
-				if value == "" {
-					value = defaultValue
+			if annotation, ok := parseAnnotationLine(line); ok && annotation.Name == strings.TrimPrefix(name, "@") {
+				if annotation.Value == "" {
+					return defaultValue
 				}
-				return value
+				return annotation.Value
 			}
 		}
 	}
@@ -250,9 +351,14 @@ func GetIndexComment(comment *proto.Comment, name string, index int, defaultValu
 }
 
 // ToTags 生成 tag
-func ToTags(f *Field) string {
+func (g *Generator) ToTags(f *Field) string {
+	if f.Oneof != nil {
+		// oneof 通过 UnmarshalJSON 解析，不支持 query 和 form，也不会保存到数据库
+		return fmt.Sprintf(`json:"%s" query:"-" form:"-"%s`, f.JSONName, utils.IfString(f.Parent != nil, ` db:"-"`, ""))
+	}
+
 	var tags = []string{
-		GetComment(f.Comment, "@goTag", ""),
+		f.Annotations.Value("goTag", ""),
 	}
 
 	if !strings.Contains(tags[0], "json:") {
@@ -267,23 +373,31 @@ func ToTags(f *Field) string {
 		tags = append(tags, fmt.Sprintf(`form:"%s"`, f.JSONName))
 	}
 
-	if !strings.Contains(tags[0], "db:") && f.Parent != nil {
-		createdAt := GetIndexComment(f.Parent.Comment, "@timestamps", 0, "created_at")
-		updatedAt := GetIndexComment(f.Parent.Comment, "@timestamps", 1, "updated_at")
+	if validate := f.ValidateTag(); validate != "" && !strings.Contains(tags[0], "validate:") {
+		tags = append(tags, fmt.Sprintf(`validate:"%s"`, validate))
+	}
 
-		if HasComment(f.Parent.Comment, "@timestamps") && (f.JSONName == createdAt || f.JSONName == updatedAt) {
-			if f.JSONName == createdAt {
-				tags = append(tags, fmt.Sprintf(`db:"%s;type:timestamp;default CURRENT_TIMESTAMP;"`, f.JSONName))
-			}
-			if f.JSONName == updatedAt {
-				tags = append(tags, fmt.Sprintf(`db:"%s;type:timestamp;DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP;"`, f.JSONName))
-			}
+	if !strings.Contains(tags[0], "db:") && f.Parent != nil {
+		dialect := g.config.Dialect
+		createdAt := f.Parent.Annotations.Arg("timestamps", 0, "created_at")
+		updatedAt := f.Parent.Annotations.Arg("timestamps", 1, "updated_at")
+
+		if f.Parent.SoftDelete != "" && f.JSONName == f.Parent.SoftDelete {
+			tags = append(tags, fmt.Sprintf(`db:"%s;type:%s;%s"`, f.JSONName, g.nullableType(columnType(dialect, "timestamp")), utils.IfString(dialect == DialectClickHouse, "", "null;")))
+		} else if f.Parent.Annotations.Has("timestamps") && (f.JSONName == createdAt || f.JSONName == updatedAt) {
+			tags = append(tags, fmt.Sprintf(`db:"%s;type:%s;%s"`, f.JSONName, columnType(dialect, "timestamp"), currentTimestamp(dialect, f.JSONName == updatedAt)))
 		} else {
+			dbType, notNull := g.DBType(f), "not null;"
+			if (f.WellKnown != nil && f.WellKnown.Nullable) || f.Annotations.Has("nullable") {
+				dbType, notNull = g.nullableType(dbType), ""
+			}
 			tags = append(tags, fmt.Sprintf(
-				`db:"%s;type:%s;not null;%s"`,
+				`db:"%s;type:%s;%s%s%s"`,
 				f.JSONName,
-				DBType(f),
-				utils.IfString(HasComment(f.Comment, "@pk") || (f.Parent != nil && !HasMsgComment(f.Parent, "@pk") && f.Index == 0), "primary key;AUTO_INCREMENT;", ""),
+				dbType,
+				notNull,
+				f.keyConstraint(dialect),
+				utils.IfString(f.Parent.Version == f.JSONName, "default 0;", ""),
 			),
 			)
 		}
@@ -292,14 +406,42 @@ func ToTags(f *Field) string {
 	return strings.TrimPrefix(strings.Join(tags, " "), " ")
 }
 
-func DBType(f *Field) string {
-	if usagePackageMap[f.Type] != nil {
+// DBType 字段在目标数据库中的类型
+func (g *Generator) DBType(f *Field) string {
+	return columnType(g.config.Dialect, g.baseDBType(f))
+}
+
+// nullableType clickhouse 的字段默认不能为空，需要使用 Nullable 类型
+func (g *Generator) nullableType(dbType string) string {
+	if g.config.Dialect == DialectClickHouse {
+		return "Nullable(" + dbType + ")"
+	}
+	return dbType
+}
+
+// baseDBType 字段在 mysql 中的类型，其他数据库的类型由此转换
+func (g *Generator) baseDBType(f *Field) string {
+	if f.Map != nil || f.Cast == "json" {
+		return "json"
+	} else if f.Cast == "encrypted" {
+		// 密文比明文长，使用 TEXT 避免截断
+		return "TEXT"
+	} else if f.Parent != nil && f.Parent.KeyStrategy != "" && f.JSONName == f.Parent.PrimaryKey && keyStrategies[f.Parent.KeyStrategy].DBType != "" {
+		return keyStrategies[f.Parent.KeyStrategy].DBType
+	} else if f.WellKnown != nil {
+		if f.Repeated {
+			return "json"
+		}
+		return f.WellKnown.DBType
+	} else if g.registry.Lookup(f.Type) != nil {
 		return "json"
-	} else if HasComment(f.Comment, "@carbon") {
+	} else if g.registry.LookupEnum(f.Type) != nil {
+		return "INT"
+	} else if f.Annotations.Has("carbon") || (f.Parent != nil && f.Parent.SoftDelete != "" && f.JSONName == f.Parent.SoftDelete) {
 		return "timestamp"
-	} else if f.Parent != nil && HasComment(f.Parent.Comment, "@timestamps") {
-		createdAt := GetIndexComment(f.Parent.Comment, "@timestamps", 0, "created_at")
-		updatedAt := GetIndexComment(f.Parent.Comment, "@timestamps", 1, "updated_at")
+	} else if f.Parent != nil && f.Parent.Annotations.Has("timestamps") {
+		createdAt := f.Parent.Annotations.Arg("timestamps", 0, "created_at")
+		updatedAt := f.Parent.Annotations.Arg("timestamps", 1, "updated_at")
 		if f.JSONName == createdAt || f.JSONName == updatedAt {
 			return "timestamp"
 		}
@@ -328,11 +470,6 @@ func DBType(f *Field) string {
 	return "varchar(255)"
 }
 
-var (
-	// 记录类型到包的映射
-	usagePackageMap = make(map[string]*Message)
-)
-
 func ToComments(name string, comments []string) string {
 	if len(comments) == 0 {
 		return ""
@@ -0,0 +1,234 @@
+package gen
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ValidationRule @validate 声明的校验规则，除了生成 validate 标签之外，ts sdk、openapi 之类的模板也可以直接使用
+type ValidationRule struct {
+	Name  string // required、min、max、len、email、url、regex、in、enum、eqfield 等
+	Value string // 规则的参数，例如 min=1 中的 1
+	Field string // 跨字段比较时对方字段的 go 名称
+	Tag   string // 对应的 validate 标签，regex 没有对应的标签，通过生成的 ValidatePatterns 方法校验
+}
+
+// crossFieldRules 跨字段比较的规则，参数是同一个消息中另一个字段的 proto 名称
+var crossFieldRules = map[string]bool{
+	"eqfield":  true,
+	"nefield":  true,
+	"gtfield":  true,
+	"gtefield": true,
+	"ltfield":  true,
+	"ltefield": true,
+}
+
+var numericTypes = map[string]bool{
+	"double": true, "float": true,
+	"int32": true, "int64": true, "uint32": true, "uint64": true,
+	"sint32": true, "sint64": true, "fixed32": true, "fixed64": true,
+	"sfixed32": true, "sfixed64": true,
+}
+
+// parseValidationRules 拆分 @validate 的参数，regex 会吃掉这一行剩余的全部内容，所以正则中可以包含逗号
+func parseValidationRules(value string) []*ValidationRule {
+	var rules []*ValidationRule
+	for value != "" {
+		var item string
+		if strings.HasPrefix(strings.TrimSpace(value), "regex=") {
+			item, value = strings.TrimSpace(value), ""
+		} else {
+			item, value, _ = strings.Cut(value, ",")
+			item = strings.TrimSpace(item)
+		}
+		if item == "" {
+			continue
+		}
+		name, param, _ := strings.Cut(item, "=")
+		rules = append(rules, &ValidationRule{Name: strings.TrimSpace(name), Value: param})
+	}
+	return rules
+}
+
+// fieldKind 字段用于校验的类型：number、string、bytes、bool、enum、message、list
+func (g *Generator) fieldKind(field *Field) string {
+	switch {
+	case field.Repeated || field.Map != nil:
+		return "list"
+	case field.Oneof != nil:
+		return "message"
+	case field.WellKnown != nil:
+		if field.WellKnown.GoType == "string" || field.WellKnown.GoType == "*string" {
+			return "string"
+		}
+		return "message"
+	case numericTypes[field.Type]:
+		return "number"
+	case field.Type == "string":
+		return "string"
+	case field.Type == "bytes":
+		return "bytes"
+	case field.Type == "bool":
+		return "bool"
+	case g.registry.LookupEnum(field.Type) != nil:
+		return "enum"
+	}
+	return "message"
+}
+
+// extractValidationRules 解析消息中全部字段的 @validate，规则与字段类型不匹配时返回错误
+func (g *Generator) extractValidationRules(message *Message) error {
+	var diagnostics Diagnostics
+	fields := map[string]*Field{}
+	for _, field := range message.Fields {
+		fields[field.JSONName] = field
+	}
+
+	for _, field := range messageFields(message) {
+		subject := fmt.Sprintf("field %s.%s", message.Name, field.JSONName)
+		for _, annotation := range field.Annotations.All("validate") {
+			kind := g.fieldKind(field)
+			for _, rule := range parseValidationRules(annotation.Arg(0, "")) {
+				if err := g.checkValidationRule(message, field, kind, rule, fields); err != nil {
+					diagnostics.Errorf(annotation.Position, subject, "@validate %s：%v", rule.Name, err)
+					continue
+				}
+				field.Rules = append(field.Rules, rule)
+			}
+		}
+	}
+	return diagnostics.Err()
+}
+
+// checkValidationRule 检查规则是否适用于字段的类型，并生成对应的 validate 标签
+func (g *Generator) checkValidationRule(message *Message, field *Field, kind string, rule *ValidationRule, fields map[string]*Field) error {
+	requireKind := func(kinds ...string) error {
+		for _, item := range kinds {
+			if item == kind {
+				return nil
+			}
+		}
+		return fmt.Errorf("不能用于 %s 类型的字段", field.Type)
+	}
+	requireParam := func(integer bool) error {
+		if rule.Value == "" {
+			return fmt.Errorf("缺少参数")
+		}
+		if _, err := strconv.ParseFloat(rule.Value, 64); err != nil || (integer && strings.ContainsAny(rule.Value, ".eE")) {
+			return fmt.Errorf("参数 %s 不是合法的数字", rule.Value)
+		}
+		return nil
+	}
+
+	name := rule.Name
+	switch {
+	case name == "required":
+		rule.Tag = "required"
+	case name == "min" || name == "max":
+		if err := requireKind("number", "string", "bytes", "list"); err != nil {
+			return err
+		}
+		if err := requireParam(kind != "number"); err != nil {
+			return err
+		}
+		rule.Tag = name + "=" + rule.Value
+	case name == "len":
+		if err := requireKind("string", "bytes", "list"); err != nil {
+			return err
+		}
+		if err := requireParam(true); err != nil {
+			return err
+		}
+		rule.Tag = "len=" + rule.Value
+	case name == "email" || name == "url":
+		if err := requireKind("string"); err != nil {
+			return err
+		}
+		rule.Tag = name
+	case name == "regex":
+		if err := requireKind("string"); err != nil {
+			return err
+		}
+		if message.Template != "request" {
+			return fmt.Errorf("只能用于请求消息")
+		}
+		if rule.Value == "" {
+			return fmt.Errorf("缺少正则表达式")
+		}
+		if _, err := regexp.Compile(rule.Value); err != nil {
+			return fmt.Errorf("正则表达式不合法：%v", err)
+		}
+	case name == "in":
+		if err := requireKind("number", "string", "enum"); err != nil {
+			return err
+		}
+		if rule.Value == "" {
+			return fmt.Errorf("缺少可选值，例如 in=a|b|c")
+		}
+		rule.Tag = "oneof=" + strings.ReplaceAll(rule.Value, "|", " ")
+	case name == "enum":
+		if err := requireKind("enum"); err != nil {
+			return err
+		}
+		var values []string
+		for _, value := range g.registry.LookupEnum(field.Type).Values {
+			values = append(values, strconv.Itoa(value.Value))
+		}
+		rule.Value = strings.Join(values, "|")
+		rule.Tag = "oneof=" + strings.Join(values, " ")
+	case crossFieldRules[name]:
+		other, exists := fields[rule.Value]
+		if !exists {
+			return fmt.Errorf("%s 中没有字段 %s", message.Name, rule.Value)
+		}
+		if other == field {
+			return fmt.Errorf("不能和字段自身比较")
+		}
+		if otherKind := g.fieldKind(other); otherKind != kind {
+			return fmt.Errorf("%s 是 %s 类型，不能和 %s 比较", rule.Value, other.Type, field.Type)
+		}
+		if name != "eqfield" && name != "nefield" {
+			if err := requireKind("number", "string"); err != nil {
+				return err
+			}
+		}
+		rule.Field = other.Name
+		rule.Tag = name + "=" + other.Name
+	default:
+		return fmt.Errorf("未知的校验规则")
+	}
+	return nil
+}
+
+// ValidateTag 字段的 validate 标签内容
+func (field *Field) ValidateTag() string {
+	var tags []string
+	for _, rule := range field.Rules {
+		if rule.Tag != "" {
+			tags = append(tags, rule.Tag)
+		}
+	}
+	return strings.Join(tags, ",")
+}
+
+// Rule 指定名称的校验规则，不存在时返回 nil
+func (field *Field) Rule(name string) *ValidationRule {
+	for _, rule := range field.Rules {
+		if rule.Name == name {
+			return rule
+		}
+	}
+	return nil
+}
+
+// HasRule 消息中是否有字段声明了指定的校验规则
+func (message *Message) HasRule(name string) bool {
+	for _, field := range messageFields(message) {
+		if field.Rule(name) != nil {
+			return true
+		}
+	}
+	return false
+}
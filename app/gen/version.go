@@ -0,0 +1,41 @@
+package gen
+
+import (
+	"fmt"
+	"slices"
+)
+
+var versionTypes = []string{"int32", "int64", "uint32", "uint64"}
+
+// extractVersion 解析 @version 声明的乐观锁字段，模型据此在更新时校验并自增版本号，请求和响应用来把版本号带给客户端
+func (g *Generator) extractVersion(message *Message) error {
+	var diagnostics Diagnostics
+	for _, field := range message.Fields {
+		annotation := field.Annotations.Get("version")
+		if annotation == nil {
+			continue
+		}
+		subject := fmt.Sprintf("field %s.%s", message.Name, field.JSONName)
+		switch {
+		case message.Version != "":
+			diagnostics.Errorf(annotation.Position, subject, "@version 已经声明在字段 %s 上，一个消息只能有一个版本号", message.Version)
+		case field.Repeated || !slices.Contains(versionTypes, field.Type):
+			diagnostics.Errorf(annotation.Position, subject, "@version 只能用于 int32、int64、uint32、uint64 类型的字段，实际是 %s", field.Type)
+		case field.IsPrimaryKey():
+			diagnostics.Errorf(annotation.Position, subject, "@version 不能用于主键")
+		default:
+			message.Version = field.JSONName
+		}
+	}
+	return diagnostics.Err()
+}
+
+// VersionField 乐观锁版本号对应的字段，没有声明 @version 时返回 nil
+func (message *Message) VersionField() *Field {
+	for _, field := range message.Fields {
+		if message.Version != "" && field.JSONName == message.Version {
+			return field
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package gen
+
+import (
+	"strings"
+)
+
+// WellKnownType google/protobuf 内置类型在各个目标中的映射
+type WellKnownType struct {
+	Name     string // 完整的类型名，例如：google.protobuf.Timestamp
+	GoType   string
+	GoImport string // GoType 依赖的包
+	TsType   string
+	DBType   string
+	Nullable bool // 包装类型，值可以为空
+}
+
+// wellKnownFiles 内置的 proto 文件以及其中定义的类型，引用这些文件时不需要在 include 路径中存在
+var wellKnownFiles = map[string][]*WellKnownType{
+	"google/protobuf/timestamp.proto": {
+		{Name: "Timestamp", GoType: "time.Time", GoImport: "time", TsType: "string", DBType: "timestamp"},
+	},
+	"google/protobuf/duration.proto": {
+		{Name: "Duration", GoType: "time.Duration", GoImport: "time", TsType: "string", DBType: "BIGINT"},
+	},
+	"google/protobuf/wrappers.proto": {
+		{Name: "DoubleValue", GoType: "*float64", TsType: "number | null", DBType: "DOUBLE", Nullable: true},
+		{Name: "FloatValue", GoType: "*float32", TsType: "number | null", DBType: "FLOAT", Nullable: true},
+		{Name: "Int64Value", GoType: "*int64", TsType: "number | null", DBType: "BIGINT", Nullable: true},
+		{Name: "UInt64Value", GoType: "*uint64", TsType: "number | null", DBType: "BIGINT UNSIGNED", Nullable: true},
+		{Name: "Int32Value", GoType: "*int32", TsType: "number | null", DBType: "INT", Nullable: true},
+		{Name: "UInt32Value", GoType: "*uint32", TsType: "number | null", DBType: "INT UNSIGNED", Nullable: true},
+		{Name: "BoolValue", GoType: "*bool", TsType: "boolean | null", DBType: "BOOLEAN", Nullable: true},
+		{Name: "StringValue", GoType: "*string", TsType: "string | null", DBType: "VARCHAR(255)", Nullable: true},
+		{Name: "BytesValue", GoType: "[]byte", TsType: "string | null", DBType: "BLOB", Nullable: true},
+	},
+	"google/protobuf/struct.proto": {
+		{Name: "Struct", GoType: "map[string]any", TsType: "Record<string, any>", DBType: "json", Nullable: true},
+		{Name: "Value", GoType: "any", TsType: "any", DBType: "json", Nullable: true},
+		{Name: "ListValue", GoType: "[]any", TsType: "any[]", DBType: "json", Nullable: true},
+		{Name: "NullValue", GoType: "any", TsType: "null", DBType: "json", Nullable: true},
+	},
+	"google/protobuf/any.proto": {
+		{Name: "Any", GoType: "any", TsType: "any", DBType: "json", Nullable: true},
+	},
+	"google/protobuf/empty.proto": {
+		{Name: "Empty", GoType: "struct{}", TsType: "Record<string, never>", DBType: "json"},
+	},
+}
+
+var wellKnownTypes = make(map[string]*WellKnownType)
+
+func init() {
+	for _, types := range wellKnownFiles {
+		for _, wkt := range types {
+			wkt.Name = "google.protobuf." + wkt.Name
+			wellKnownTypes[wkt.Name] = wkt
+		}
+	}
+}
+
+// IsWellKnownFile 是否是内置的 google/protobuf 文件
+func IsWellKnownFile(filename string) bool {
+	_, exists := wellKnownFiles[filename]
+	return exists
+}
+
+// LookupWellKnownType 查找内置类型，支持以 . 开头的完整类型名，不存在时返回 nil
+func LookupWellKnownType(typeName string) *WellKnownType {
+	return wellKnownTypes[strings.TrimPrefix(typeName, ".")]
+}
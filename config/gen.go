@@ -0,0 +1,25 @@
+package config
+
+import (
+	"github.com/goal-web/contracts"
+	"github.com/goal-web/supports/utils"
+	"strings"
+)
+
+// Gen 项目 env.toml 中 [gen] 的配置
+type Gen struct {
+	Types   map[string]string // [gen.types] make:model 中数据库类型对应的 proto 类型，例如 decimal = "double"
+	Dialect string            // gen.dialect 生成代码时的目标数据库，gen --dialect 优先
+}
+
+func init() {
+	configs["gen"] = func(env contracts.Env) any {
+		types := map[string]string{}
+		for key, value := range env.Load() {
+			if name, ok := strings.CutPrefix(key, "gen.types."); ok {
+				types[strings.ToLower(name)] = utils.ToString(value, "")
+			}
+		}
+		return Gen{Types: types, Dialect: env.GetString("gen.dialect")}
+	}
+}
@@ -0,0 +1,46 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/emicklei/proto"
+	"github.com/goal-web/goal-cli/app/gen"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnnotationParse(t *testing.T) {
+	registry := gen.NewAnnotationRegistry()
+	comment := &proto.Comment{Lines: []string{
+		" 拥有者",
+		"@hasOneThrough:user_accounts,second_local_key=owner_id",
+		"@goType:map[string, any]",
+	}}
+
+	annotations, err := registry.Parse(comment, gen.TargetField, "field AccountModel.owner")
+	assert.Nil(t, err)
+	assert.True(t, annotations.Has("hasOneThrough"))
+	assert.False(t, annotations.Has("hasOne"))
+	assert.Equal(t, "user_accounts", annotations.Arg("hasOneThrough", 0, "mid_table"))
+	assert.Equal(t, "id", annotations.Arg("@hasOneThrough", 2, "id"))
+	assert.Equal(t, "owner_id", annotations.Get("hasOneThrough").Get("second_local_key", ""))
+	assert.Equal(t, "map[string, any]", annotations.Value("goType", ""))
+}
+
+func TestAnnotationDiagnostics(t *testing.T) {
+	registry := gen.NewAnnotationRegistry()
+	comment := &proto.Comment{
+		Lines: []string{"@hasMnay", "@table:users", "@index:a,b,c"},
+	}
+
+	annotations, err := registry.Parse(comment, gen.TargetField, "field UserModel.posts")
+	diagnostics, ok := err.(gen.Diagnostics)
+	assert.True(t, ok)
+	assert.Len(t, diagnostics, 3)
+	assert.Equal(t, gen.SeverityWarning, diagnostics[0].Severity)
+	assert.Contains(t, diagnostics[0].Error(), "是否想使用 @hasMany")
+	assert.Contains(t, diagnostics[1].Error(), "@table 只能用于 message")
+	assert.Contains(t, diagnostics[2].Error(), "@index 最多接受 2 个参数")
+
+	// 未知的注解仍然保留给自定义模板使用
+	assert.True(t, annotations.Has("hasMnay"))
+}
@@ -0,0 +1,49 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/goal-web/goal-cli/app/gen"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryBuilder(t *testing.T) {
+	writeProject(t, map[string]string{
+		"pro/post.proto": `
+message UserModel {
+  uint64 id = 1;
+}
+message Meta {
+  string source = 1;
+}
+message PostModel {
+  uint64 id = 1;
+  string title = 2;
+  //@belongsTo
+  UserModel user = 3;
+  uint64 user_id = 4;
+  Meta meta = 5;
+  repeated string tags = 6;
+  oneof body {
+    string text = 7;
+    string html = 8;
+  }
+  //@goTag:db:"-"
+  string preview = 9;
+}`,
+	})
+
+	model := renderFiles(t, gen.Config{}, "pro/post.proto")["Post_gen.go"]
+	// 只包含保存在表中的字段，关联、oneof 以及 db:"-" 的字段不是表的字段
+	assert.Contains(t, model, "var PostColumns = struct {\n\tId     string\n\tTitle  string\n\tUserId string\n\tMeta   string\n\tTags   string\n}{\n\tId:     \"id\",\n\tTitle:  \"title\",\n\tUserId: \"user_id\",\n\tMeta:   \"meta\",\n\tTags:   \"tags\",\n}")
+
+	assert.Contains(t, model, "func PostBuilder() *PostQueryBuilder {\n\treturn NewPostQueryBuilder(PostQuery())\n}")
+	assert.Contains(t, model, "func (builder *PostQueryBuilder) WhereTitle(value string) *PostQueryBuilder {\n\tbuilder.Where(PostColumns.Title, value)")
+	assert.Contains(t, model, "func (builder *PostQueryBuilder) WhereUserIdIn(values ...uint64) *PostQueryBuilder {")
+	assert.Contains(t, model, "func (builder *PostQueryBuilder) OrderByTitle(desc bool) *PostQueryBuilder {")
+	assert.Contains(t, model, "func (builder *PostQueryBuilder) PluckUserId() []uint64 {\n\tbuilder.Select(PostColumns.UserId)")
+	// json 字段、重复字段以及不是表的字段不能直接比较
+	for _, name := range []string{"User", "Meta", "Tags", "Body", "Preview"} {
+		assert.NotContains(t, model, "func (builder *PostQueryBuilder) Where"+name+"(")
+	}
+}
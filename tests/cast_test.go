@@ -0,0 +1,77 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/goal-web/goal-cli/app/gen"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCastType(t *testing.T) {
+	writeProtos(t, map[string]string{
+		"pro/customer.proto": `
+message CustomerModel {
+  uint64 id = 1;
+  //@cast:encrypted
+  int64 ssn = 2;
+  //@cast:json
+  string tags = 3;
+  //@cast:base64
+  string avatar = 4;
+}`,
+	})
+
+	generator, err := gen.NewGenerator(gen.Config{Mode: gen.ModeSDK, OutputDir: "."})
+	assert.Nil(t, err)
+
+	_, err = generator.RenderSDK([]string{"pro/customer.proto"})
+	diagnostics, ok := err.(gen.Diagnostics)
+	assert.True(t, ok)
+	assert.Len(t, diagnostics, 3)
+	assert.Contains(t, diagnostics[0].Error(), "@cast:encrypted 只能用于 string 类型的字段")
+	assert.Contains(t, diagnostics[1].Error(), "@cast:json 只能用于消息、repeated 以及 map 类型的字段，实际是 string")
+	assert.Contains(t, diagnostics[2].Error(), "未知的 @cast 类型 base64")
+}
+
+func TestCastModel(t *testing.T) {
+	writeProject(t, map[string]string{
+		"pro/customer.proto": `
+message Address {
+  string city = 1;
+}
+message CustomerModel {
+  uint64 id = 1;
+  //@cast:encrypted
+  string ssn = 2;
+  //@cast:hashed
+  string password = 3;
+  //@cast:json
+  Address address = 4;
+}`,
+	})
+
+	model := renderFiles(t, gen.Config{}, "pro/customer.proto")["Customer_gen.go"]
+	// 密文比明文长，json 字段使用 json 类型保存
+	assert.Contains(t, model, "db:\"ssn;type:TEXT;not null;\"`")
+	assert.Contains(t, model, "db:\"address;type:json;not null;\"`")
+
+	// 加密字段赋值时加密，读取时解密
+	assert.Contains(t, model, "CustomerDefine.SsnSetter = func(model *CustomerModel, raw string) string {\n\t\tif raw == \"\" {\n\t\t\treturn raw\n\t\t}\n\t\treturn application.Get(\"encryption.default\").(contracts.Encryptor).EncryptString(raw)\n\t}")
+	assert.Contains(t, model, "value, err := application.Get(\"encryption.default\").(contracts.Encryptor).DecryptString(raw)")
+
+	// 哈希字段只保存哈希，序列化时隐藏，通过 CheckPassword 校验
+	assert.Contains(t, model, "CustomerDefine.Hidden = append(CustomerDefine.Hidden, \"password\")")
+	assert.Contains(t, model, "return application.Get(\"hashing\").(contracts.Hasher).Make(raw, nil)")
+	assert.Contains(t, model, "func (model *CustomerModel) CheckPassword(value string) bool {\n\treturn application.Get(\"hashing\").(contracts.Hasher).Check(value, model.Password, nil)\n}")
+	assert.NotContains(t, model, "CheckSsn")
+
+	// 从数据库读取的记录已经是存储的值，不再经过 Setter
+	assert.Contains(t, model, "return table.NewQuery(CustomerDefine.TableName, hydrateCustomerModel).")
+	assert.Contains(t, model, "case \"ssn\":\n\t\t\tmodel.Ssn = cast.ToString(value)\n\t\tcase \"password\":\n\t\t\tmodel.Password = cast.ToString(value)")
+
+	// json 字段通过 Value 和 Scan 序列化
+	assert.Contains(t, model, "model._update = contracts.Fields{\"address\": CustomerAddressJSON(value)}")
+	assert.Contains(t, model, "func (value CustomerAddressJSON) Value() (driver.Value, error) {\n\treturn json.Marshal(pro.Address(value))\n}")
+	assert.Contains(t, model, "func (value *CustomerAddressJSON) Scan(src any) error {")
+	assert.Contains(t, model, "case \"address\":\n\t\t\tif v, ok := value.(pro.Address); ok {\n\t\t\t\tvalue = CustomerAddressJSON(v)\n\t\t\t}")
+}
@@ -0,0 +1,55 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/goal-web/console/inputs"
+	"github.com/goal-web/contracts"
+	"github.com/goal-web/goal-cli/app/console/commands"
+	"github.com/goal-web/goal-cli/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// commandApplication 只提供命令需要的配置
+type commandApplication struct {
+	contracts.Application
+}
+
+type commandConfig struct {
+	contracts.Config
+}
+
+func (commandConfig) Get(key string) any {
+	return config.Gen{}
+}
+
+func (commandApplication) Get(key string, args ...any) any {
+	return commandConfig{}
+}
+
+// runCommand 按照命令行的方式解析参数并执行命令
+func runCommand(t *testing.T, provider contracts.CommandProvider, args ...string) {
+	command, handlerProvider := provider()
+	handler := handlerProvider(commandApplication{})
+	input := inputs.String(append([]string{command.GetName()}, args...)...)
+	assert.Nil(t, handler.InjectArguments(command.GetArgs(), input.GetArguments()))
+	handler.Handle()
+}
+
+func TestGenCommandProtoPath(t *testing.T) {
+	writeProject(t, map[string]string{
+		"third-party/common/user.proto": "package common;\nmessage Profile {\n  string name = 1;\n}",
+		"pro/post.proto": `import "common/user.proto";
+message GetPostReq {
+  Profile profile = 1;
+}`,
+	})
+
+	// 目录中的 - 需要原样保留
+	runCommand(t, commands.NewGen, "--dir=pro", "--proto_path=third-party")
+	request, err := os.ReadFile(filepath.Join("requests", "pro", "GetPost_gen.go"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(request), "\"example.com/app/models/third-party/common\"")
+}
@@ -0,0 +1,59 @@
+package tests
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/goal-web/contracts"
+	"github.com/goal-web/database/drivers"
+	"github.com/goal-web/goal-cli/app/gen"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffDatabase(t *testing.T) {
+	connection := drivers.SqliteConnector(contracts.Fields{"database": filepath.Join(t.TempDir(), "test.db")}, nil)
+	_, exception := connection.Exec(`CREATE TABLE users (
+  id BIGINT UNSIGNED NOT NULL PRIMARY KEY,
+  email VARCHAR(100) NOT NULL,
+  nickname VARCHAR(255) NOT NULL,
+  age TEXT
+)`)
+	assert.Nil(t, exception)
+	_, exception = connection.Exec("CREATE INDEX users_email ON users (email)")
+	assert.Nil(t, exception)
+
+	schema := &gen.Schema{Tables: []*gen.TableSchema{
+		{
+			Name: "users",
+			Columns: []*gen.ColumnSchema{
+				{Name: "id", Type: "BIGINT UNSIGNED", Constraints: "not null primary key AUTO_INCREMENT"},
+				{Name: "email", Type: "VARCHAR(255)", Constraints: "not null"},
+				{Name: "nickname", Type: "varchar(255)", Constraints: "not null"},
+				{Name: "age", Type: "INTEGER", Constraints: "not null"},
+				{Name: "created_at", Type: "timestamp", Constraints: "not null"},
+			},
+			Indexes: []*gen.IndexSchema{
+				{Name: "email_unique", Unique: true, Columns: "(email)"},
+				{Name: "users_pk", Unique: true, Columns: "(id)"},
+			},
+		},
+		{Name: "posts", Columns: []*gen.ColumnSchema{{Name: "id", Type: "BIGINT", Constraints: "not null"}}},
+	}}
+
+	inspector, err := gen.NewInspector(connection)
+	assert.Nil(t, err)
+	diff, err := gen.DiffDatabase(schema, inspector)
+	assert.Nil(t, err)
+	assert.True(t, diff.HasDrift())
+	assert.Equal(t, []string{"posts"}, diff.MissingTables)
+	assert.Equal(t, []*gen.ColumnDrift{{Table: "users", Column: "created_at", Expected: "timestamp"}}, diff.MissingColumns)
+	// sqlite 不检查长度，VARCHAR(100) 与 VARCHAR(255) 视为一致
+	assert.Equal(t, []*gen.ColumnDrift{{Table: "users", Column: "age", Expected: "INTEGER", Actual: "TEXT"}}, diff.TypeMismatches)
+	assert.Equal(t, []*gen.ColumnDrift{{Table: "users", Column: "age", Expected: "not null", Actual: "null"}}, diff.NullableMismatches)
+	assert.Equal(t, []*gen.IndexDrift{{Table: "users", Name: "email_unique", Unique: true, Columns: "(email)"}}, diff.MissingIndexes)
+
+	var report bytes.Buffer
+	diff.Report(&report)
+	assert.Contains(t, report.String(), "类型不一致：users.age 模型为 INTEGER，数据库为 TEXT")
+}
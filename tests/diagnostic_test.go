@@ -0,0 +1,67 @@
+package tests
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"text/scanner"
+
+	"github.com/goal-web/goal-cli/app/gen"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiagnostics(t *testing.T) {
+	warning := gen.NewDiagnostic(scanner.Position{Filename: "pro/user.proto", Line: 3, Column: 1}, "message UserModel", errors.New("未知的注解 @tabel"))
+	warning.Severity = gen.SeverityWarning
+
+	var diagnostics gen.Diagnostics
+	diagnostics.Add(nil)
+	diagnostics.Add(warning)
+	assert.Nil(t, gen.Diagnostics{}.Err())
+	assert.Equal(t, "pro/user.proto:3:1: warning: message UserModel: 未知的注解 @tabel", diagnostics.Error())
+	// 只有警告时不算失败
+	assert.False(t, diagnostics.HasErrors())
+
+	// 嵌套的 Diagnostics 会被展开，重复的诊断只保留一条，普通 error 没有位置
+	var nested gen.Diagnostics
+	nested.Add(warning)
+	nested.Errorf(scanner.Position{Filename: "pro/user.proto", Line: 5, Column: 3}, "field UserModel.name", "不支持的类型 %s", "any")
+	diagnostics.Add(nested)
+	diagnostics.Add(errors.New("读取模板失败"))
+	assert.Len(t, diagnostics, 3)
+	assert.Equal(t, gen.SeverityError, diagnostics[1].Severity)
+	assert.Equal(t, "pro/user.proto:5:3: error: field UserModel.name: 不支持的类型 any", diagnostics[1].Error())
+	assert.Equal(t, "error: 读取模板失败", diagnostics[2].Error())
+	assert.True(t, diagnostics.HasErrors())
+
+	var report strings.Builder
+	diagnostics.Report(&report)
+	assert.True(t, strings.HasSuffix(report.String(), "共 2 个错误，1 个警告\n"))
+}
+
+func TestDiagnosticsCollectAllFiles(t *testing.T) {
+	writeProtos(t, map[string]string{
+		"pro/post.proto": `
+message PostModel {
+  uint64 id = 1;
+`,
+		"pro/user.proto": `
+message UserModel {
+  uint64 id = 1;
+}
+service UserService {
+  rpc Get(GetUserReq) returns (UserModel);
+}`,
+	})
+
+	// 一个文件出错不会中断其他文件，所有的诊断信息一起返回
+	generator, err := gen.NewGenerator(gen.Config{Mode: gen.ModeSDK, OutputDir: "."})
+	assert.Nil(t, err)
+
+	_, err = generator.RenderSDK([]string{"pro/post.proto", "pro/user.proto"})
+	diagnostics, ok := err.(gen.Diagnostics)
+	assert.True(t, ok)
+	assert.Len(t, diagnostics, 2)
+	assert.Equal(t, "pro/post.proto", diagnostics[0].Position.Filename)
+	assert.Equal(t, "pro/user.proto:7:3: error: rpc UserService.Get: 未知的请求类型 GetUserReq", diagnostics[1].Error())
+}
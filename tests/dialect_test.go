@@ -0,0 +1,115 @@
+package tests
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/goal-web/contracts"
+	"github.com/goal-web/database/drivers"
+	"github.com/goal-web/goal-cli/app/gen"
+	"github.com/stretchr/testify/assert"
+)
+
+const dialectProto = `
+//@timestamps
+//@softDelete
+message PostModel {
+  uint64 id = 1;
+  //@index
+  string title = 2;
+  //@nullable
+  string summary = 3;
+  map<string, string> meta = 4;
+  string created_at = 5;
+  string updated_at = 6;
+  string deleted_at = 7;
+}`
+
+func dialectSchema(t *testing.T, dialect string) *gen.Schema {
+	writeProtos(t, map[string]string{"pro/post.proto": dialectProto})
+	generator, err := gen.NewGenerator(gen.Config{Mode: gen.ModeSDK, OutputDir: ".", Dialect: dialect})
+	assert.Nil(t, err)
+	schema, err := generator.Schema([]string{"pro/post.proto"})
+	assert.Nil(t, err)
+	return schema
+}
+
+func TestDialectSchema(t *testing.T) {
+	schema := dialectSchema(t, "postgres")
+	posts := schema.Table("posts")
+	assert.Equal(t, &gen.ColumnSchema{Name: "id", Type: "BIGINT", Constraints: "not null primary key GENERATED BY DEFAULT AS IDENTITY"}, posts.Column("id"))
+	assert.Equal(t, "JSONB", posts.Column("meta").Type)
+	assert.Equal(t, "default CURRENT_TIMESTAMP", posts.Column("updated_at").Constraints)
+	assert.True(t, posts.Column("updated_at").AutoUpdate)
+
+	diff, err := gen.DiffSchema(&gen.Schema{}, schema)
+	assert.Nil(t, err)
+	up := strings.Join(diff.Up, "\n")
+	assert.Contains(t, up, `CREATE INDEX "posts_title_idx" ON "posts" (title)`)
+	assert.Contains(t, up, `CREATE TRIGGER "posts_set_updated_at" BEFORE UPDATE ON "posts"`)
+
+	schema = dialectSchema(t, "clickhouse")
+	posts = schema.Table("posts")
+	assert.Equal(t, "Nullable(String)", posts.Column("summary").Type)
+	assert.Equal(t, "Nullable(DateTime)", posts.Column("deleted_at").Type)
+	diff, err = gen.DiffSchema(&gen.Schema{}, schema)
+	assert.Nil(t, err)
+	assert.Contains(t, diff.Up[0], "ENGINE = ReplacingMergeTree(updated_at) ORDER BY (id)")
+	assert.Len(t, diff.Warnings, 1)
+
+	_, err = gen.NewGenerator(gen.Config{Mode: gen.ModeSDK, OutputDir: ".", Dialect: "oracle"})
+	assert.EqualError(t, err, "不支持的数据库 oracle，可选值：mysql、postgres、sqlite、clickhouse")
+}
+
+func TestSqliteMigration(t *testing.T) {
+	schema := dialectSchema(t, "sqlite")
+	connection := drivers.SqliteConnector(contracts.Fields{"database": "test.db"}, nil)
+	migrations, err := gen.DiffSchema(&gen.Schema{}, schema)
+	assert.Nil(t, err)
+	// 与 migrate 命令一样，每个文件只执行一条语句
+	for _, migration := range migrations.Migrations {
+		_, exception := connection.Exec(migration.Up)
+		assert.Nil(t, exception, migration.Up)
+	}
+
+	_, exception := connection.Exec("INSERT INTO posts (title, meta, updated_at) VALUES ('a', '{}', '2000-01-01 00:00:00')")
+	assert.Nil(t, exception)
+	_, exception = connection.Exec("UPDATE posts SET title = 'b'")
+	assert.Nil(t, exception)
+	var count []struct {
+		Count int64 `db:"count"`
+	}
+	assert.Nil(t, connection.Select(&count, "SELECT count(*) AS count FROM posts WHERE updated_at > '2000-01-01 00:00:00'"))
+	assert.Equal(t, int64(1), count[0].Count)
+
+	// 迁移之后的数据库与模型一致
+	inspector, err := gen.NewInspector(connection)
+	assert.Nil(t, err)
+	diff, err := gen.DiffDatabase(schema, inspector)
+	assert.Nil(t, err)
+	assert.False(t, diff.HasDrift())
+
+	// 按照相反的顺序回滚之后表和触发器都已经删除
+	for _, migration := range slices.Backward(migrations.Migrations) {
+		_, exception = connection.Exec(migration.Down)
+		assert.Nil(t, exception, migration.Down)
+	}
+	tables, err := inspector.Tables()
+	assert.Nil(t, err)
+	assert.Empty(t, tables)
+}
+
+func TestDialectMigrator(t *testing.T) {
+	writeProject(t, map[string]string{"pro/post.proto": dialectProto})
+
+	// migrate.Migrate 只支持 mysql，其他数据库按照建表语句创建表
+	model := renderFiles(t, gen.Config{Dialect: "sqlite"}, "pro/post.proto")["Post_gen.go"]
+	assert.Contains(t, model, "var PostSchema = []string{\n\t\"CREATE TABLE \\\"posts\\\" (")
+	assert.Contains(t, model, "if _, err := executor.Query(\"SELECT 1 FROM posts WHERE 1 = 0\"); err == nil {\n\t\t\treturn nil\n\t\t}\n\t\tfor _, statement := range PostSchema {")
+	assert.NotContains(t, model, "migrate.Migrate(")
+
+	model = renderFiles(t, gen.Config{}, "pro/post.proto")["Post_gen.go"]
+	assert.NotContains(t, model, "PostSchema")
+	assert.Contains(t, model, "return migrate.Migrate(PostDefine.TableName, PostDefine.Indexes, PostModel{}, executor)")
+}
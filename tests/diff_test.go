@@ -0,0 +1,35 @@
+package tests
+
+import (
+	"github.com/goal-web/goal-cli/app/gen"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	from := []byte("a\nb\nc\nd\ne\nf\ng\nh\n")
+	to := []byte("a\nb\nc\nD\ne\nf\ng\nh\ni\n")
+
+	assert.Equal(t, "", gen.UnifiedDiff("a/x", "b/x", from, from))
+	assert.Equal(t, `--- a/x
++++ b/x
+@@ -1,8 +1,9 @@
+ a
+ b
+ c
+-d
++D
+ e
+ f
+ g
+ h
++i
+`, gen.UnifiedDiff("a/x", "b/x", from, to))
+
+	assert.Equal(t, `--- /dev/null
++++ b/x
+@@ -0,0 +1,2 @@
++a
++b
+`, gen.UnifiedDiff("/dev/null", "b/x", nil, []byte("a\nb\n")))
+}
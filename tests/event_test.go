@@ -0,0 +1,39 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/goal-web/goal-cli/app/gen"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModelEvents(t *testing.T) {
+	writeProject(t, map[string]string{
+		"pro/user.proto": `
+message UserModel {
+  uint64 id = 1;
+  string name = 2;
+}`,
+	})
+
+	model := renderFiles(t, gen.Config{}, "pro/user.proto")["User_gen.go"]
+	assert.Contains(t, model, "UserSavingEvent   = \"users.saving\"")
+	assert.Contains(t, model, "UserDeletedEvent  = \"users.deleted\"")
+
+	// -ing 事件可以取消操作，多个监听器时以第一个错误为准
+	assert.Contains(t, model, "type UserSaving struct {\n\tUserEventAbort\n\tModel *UserModel\n}")
+	assert.Contains(t, model, "type UserUpdating struct {\n\tUserEventAbort\n\tModel  *UserModel\n\tFields contracts.Fields\n}")
+	assert.Contains(t, model, "type UserSaved struct {\n\tModel *UserModel\n}")
+	assert.Contains(t, model, "func (abort *UserEventAbort) Abort(err contracts.Exception) {\n\tabort.mutex.Lock()\n\tdefer abort.mutex.Unlock()\n\tif abort.err == nil {\n\t\tabort.err = err\n\t}\n}")
+
+	// 注册监听器的辅助函数按照事件类型转换
+	assert.Contains(t, model, "func OnUserSaving(dispatcher contracts.EventDispatcher, listener func(event *UserSaving)) {\n\tdispatcher.Register(UserSavingEvent, UserEventListener(func(event contracts.Event) {\n\t\tlistener(event.(*UserSaving))\n\t}))\n}")
+	assert.Contains(t, model, "func OnUserDeleted(dispatcher contracts.EventDispatcher, listener func(event *UserDeleted)) {")
+
+	// 分发之后返回监听器取消操作的原因
+	assert.Contains(t, model, "func dispatchUserEvent(event contracts.Event) contracts.Exception {\n\tevents.Dispatch(event)\n\tif abortable, ok := event.(interface{ Err() contracts.Exception }); ok {\n\t\treturn abortable.Err()\n\t}\n\treturn nil\n}")
+	assert.Contains(t, model, "if err := dispatchUserEvent(&UserSaving{Model: model}); err != nil {\n\t\treturn err\n\t}")
+	assert.Contains(t, model, "if err := dispatchUserEvent(&UserUpdating{Model: model, Fields: fields}); err != nil {\n\t\treturn err\n\t}")
+	assert.Contains(t, model, "if err := dispatchUserEvent(&UserDeleting{Model: model}); err != nil {\n\t\treturn err\n\t}")
+	assert.Contains(t, model, "dispatchUserEvent(&UserDeleted{Model: model})")
+}
@@ -0,0 +1,69 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/goal-web/goal-cli/app/gen"
+	"github.com/stretchr/testify/assert"
+)
+
+// 只输出工厂默认值以及关联关系的 sdk 模板
+const factoryTemplate = `{{- define "model" -}}
+{{- range .Model.Fields }}{{ .JSONName }}: {{ fakeValue . }}
+{{ end }}{{ range factoryRelations .Model }}{{ .Model }} {{ .BelongsTo }} {{ .LocalKey }} {{ .RelatedKey }}
+{{ end }}{{- end }}
+{{- define "data" }}{{ end }}{{ define "result" }}{{ end }}{{ define "request" }}{{ end }}
+{{- define "enum" }}{{ end }}{{ define "service" }}{{ end }}{{ define "controller" }}{{ end }}`
+
+func TestFactoryDefinition(t *testing.T) {
+	writeProtos(t, map[string]string{
+		"sdk.tmpl": factoryTemplate,
+		"pro/user.proto": `
+enum Gender { UNKNOWN = 0; MALE = 1; FEMALE = 2; }
+//@softDelete
+message UserModel {
+  uint64 id = 1;
+  string email = 2;
+  string nickname = 3;
+  Gender gender = 4;
+  bool active = 5;
+  string deleted_at = 6;
+  //@hasMany
+  repeated PostModel posts = 7;
+}
+message PostModel {
+  uint64 id = 1;
+  //@belongsTo:id,user_id
+  UserModel user = 2;
+}`,
+	})
+
+	generator, err := gen.NewGenerator(gen.Config{Mode: gen.ModeSDK, OutputDir: ".", Template: "sdk.tmpl"})
+	assert.Nil(t, err)
+
+	output, err := generator.RenderSDK([]string{"pro/user.proto"})
+	assert.Nil(t, err)
+
+	var user, post string
+	for _, file := range output.Files {
+		switch {
+		case strings.Contains(file.Path, "User"):
+			user = string(file.Content)
+		case strings.Contains(file.Path, "Post"):
+			post = string(file.Content)
+		}
+	}
+
+	assert.Equal(t, strings.Join([]string{
+		"id: ",
+		`email: fmt.Sprintf("user%d@example.com", index+1)`,
+		`nickname: fmt.Sprintf("User %d", index+1)`,
+		"gender: []Gender{0, 1, 2}[index%3]",
+		"active: index%2 == 0",
+		"deleted_at: ",
+		"PostModel false id user_id",
+		"",
+	}, "\n"), user)
+	assert.Equal(t, "id: \nUserModel true user_id id\n", post)
+}
@@ -0,0 +1,64 @@
+package tests
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/goal-web/goal-cli/app/gen"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGeneratorIsolation(t *testing.T) {
+	// 两个目录声明了同名但是字段不同的模型，每个生成器只能看到自己的注册表
+	files := map[string]string{}
+	var protoFiles [2][]string
+	for i, dir := range []string{"a", "b"} {
+		for j := 0; j < 8; j++ {
+			file := fmt.Sprintf("%s/model%d.proto", dir, j)
+			files[file] = fmt.Sprintf(`
+message Model%dModel {
+  uint64 id = 1;
+  string %s_name = 2;
+}`, j, dir)
+			protoFiles[i] = append(protoFiles[i], file)
+		}
+		files[dir+"/post.proto"] = fmt.Sprintf(`
+import "%s/model0.proto";
+
+message PostModel {
+  uint64 id = 1;
+  uint64 model0_id = 2;
+  //@belongsTo
+  Model0Model model0 = 3;
+  string %s_title = 4;
+}`, dir, dir)
+		protoFiles[i] = append(protoFiles[i], dir+"/post.proto")
+	}
+	writeProject(t, files)
+
+	var wg sync.WaitGroup
+	var results [2]map[string]string
+	for i := range protoFiles {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = renderFiles(t, gen.Config{Workers: 4}, protoFiles[i]...)
+		}()
+	}
+	wg.Wait()
+
+	for i, dir := range []string{"a", "b"} {
+		other := []string{"b", "a"}[i]
+		assert.Len(t, results[i], 9)
+		for j := 0; j < 8; j++ {
+			model := results[i][fmt.Sprintf("Model%d_gen.go", j)]
+			assert.Contains(t, model, fmt.Sprintf("db:\"%s_name;", dir))
+			assert.NotContains(t, model, other+"_name")
+		}
+		post := results[i]["Post_gen.go"]
+		assert.Contains(t, post, fmt.Sprintf("db:\"%s_title;", dir))
+		assert.Contains(t, post, "func (model *PostModel) Model0() *Model0Model {")
+		assert.NotContains(t, post, other+"_title")
+	}
+}
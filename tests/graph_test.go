@@ -0,0 +1,34 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/goal-web/goal-cli/app/gen"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImportGraphOrder(t *testing.T) {
+	writeProtos(t, map[string]string{
+		"pro/a.proto":      `import "pro/b.proto"; import "pro/c.proto";`,
+		"pro/b.proto":      `import "pro/common.proto";`,
+		"pro/c.proto":      `import "pro/common.proto"; import "google/protobuf/timestamp.proto";`,
+		"pro/common.proto": ``,
+	})
+
+	graph := newSDKGenerator(t, gen.Config{}).BuildImportGraph([]string{"pro/a.proto"})
+	assert.Equal(t, []string{"pro/common.proto", "pro/b.proto", "pro/c.proto", "pro/a.proto"}, graph.Order)
+	assert.Nil(t, graph.Errors("pro/a.proto"))
+}
+
+func TestImportGraphCycle(t *testing.T) {
+	writeProtos(t, map[string]string{
+		"pro/a.proto": `import "pro/b.proto";`,
+		"pro/b.proto": `import "pro/a.proto";`,
+	})
+
+	graph := newSDKGenerator(t, gen.Config{}).BuildImportGraph([]string{"pro/a.proto"})
+	assert.True(t, graph.Nodes["pro/a.proto"].Broken)
+	assert.True(t, graph.Nodes["pro/b.proto"].Broken)
+	assert.Equal(t, "pro/b.proto:2:1: error: import pro/a.proto: 循环引用：pro/a.proto -> pro/b.proto -> pro/a.proto",
+		graph.Errors("pro/a.proto").Error())
+}
@@ -0,0 +1,143 @@
+package tests
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/goal-web/goal-cli/app/console/commands"
+	"github.com/goal-web/goal-cli/app/gen"
+	"github.com/stretchr/testify/assert"
+)
+
+// moduleRoot goal-cli 的根目录，测试切换到临时目录之前读取
+var moduleRoot, _ = filepath.Abs("..")
+
+// writeProtos 在临时目录中写入 proto 文件并切换到该目录
+func writeProtos(t *testing.T, files map[string]string) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	for name, content := range files {
+		assert.Nil(t, os.MkdirAll(filepath.Dir(name), os.ModePerm))
+		assert.Nil(t, os.WriteFile(name, []byte("syntax = \"proto3\";\n"+content), 0644))
+	}
+}
+
+// writeProject 在临时目录中创建 go module，pro 模式需要读取模块名
+func writeProject(t *testing.T, files map[string]string) {
+	writeProtos(t, files)
+	assert.Nil(t, os.WriteFile("go.mod", []byte("module example.com/app\n\ngo 1.25\n"), 0644))
+}
+
+// writeModule 与 writeProject 一样创建 go module，依赖使用 goal-cli 的版本，生成的代码可以直接编译
+func writeModule(t *testing.T, files map[string]string) {
+	writeProtos(t, files)
+	goMod, err := os.ReadFile(filepath.Join(moduleRoot, "go.mod"))
+	assert.Nil(t, err)
+	goMod = regexp.MustCompile(`(?m)^module .*$`).ReplaceAll(goMod, []byte("module example.com/app"))
+	// 生成的模型还依赖 goal-cli 本身不需要的模块
+	goMod = append(goMod, "\nrequire github.com/spf13/cast v1.10.0\n"...)
+	assert.Nil(t, os.WriteFile("go.mod", goMod, 0644))
+	goSum, err := os.ReadFile(filepath.Join(moduleRoot, "go.sum"))
+	assert.Nil(t, err)
+	assert.Nil(t, os.WriteFile("go.sum", goSum, 0644))
+}
+
+// appConfig 生成的项目使用的配置，数据库使用 sqlite
+const appConfig = `package config
+
+import (
+	"github.com/goal-web/application"
+	"github.com/goal-web/contracts"
+	"github.com/goal-web/database"
+	"github.com/goal-web/encryption"
+)
+
+func GetConfigProviders() map[string]contracts.ConfigProvider {
+	return map[string]contracts.ConfigProvider{
+		"app": func(env contracts.Env) any {
+			return application.Config{Key: env.GetString("app.key")}
+		},
+		"database": func(env contracts.Env) any {
+			return database.Config{
+				Default: "sqlite",
+				Connections: map[string]contracts.Fields{
+					"sqlite": {"driver": "sqlite", "database": env.GetString("db.sqlite.database")},
+				},
+			}
+		},
+		"encryption": func(env contracts.Env) any {
+			return encryption.Config{Default: "AES"}
+		},
+		"hashing": func(env contracts.Env) any {
+			return contracts.Fields{"driver": "bcrypt", "cost": 4}
+		},
+	}
+}
+`
+
+// writeAppConfig 写入项目的 config 包以及 env.toml，生成的模型可以连接 sqlite 执行
+func writeAppConfig(t *testing.T) {
+	assert.Nil(t, os.MkdirAll("config", os.ModePerm))
+	assert.Nil(t, os.WriteFile(filepath.Join("config", "config.go"), []byte(appConfig), 0644))
+	assert.Nil(t, os.WriteFile("env.toml", []byte("[app]\nkey = \"dQcxsKvBZKNfWivwnhKlDwvseguknBZP\"\n\n[db.sqlite]\ndatabase = \"app.db\"\n"), 0644))
+}
+
+// goCommand 在当前目录执行 go 命令并返回输出，依赖从本地的模块缓存中读取，不需要访问网络
+func goCommand(t *testing.T, args ...string) string {
+	modCache, err := exec.Command("go", "env", "GOMODCACHE").Output()
+	assert.Nil(t, err)
+	command := exec.Command("go", args...)
+	command.Env = append(os.Environ(), "GOWORK=off", "GOFLAGS=-mod=mod", "GOSUMDB=off",
+		"GOPROXY=file://"+filepath.Join(strings.TrimSpace(string(modCache)), "cache", "download"))
+	output, err := command.CombinedOutput()
+	assert.Nil(t, err, "%s", output)
+	return string(output)
+}
+
+// generateModule 通过 gen 命令生成 pro 目录中的 proto，生成的包需要通过 go vet
+func generateModule(t *testing.T, args ...string) {
+	runCommand(t, commands.NewGen, append([]string{"--dir=pro"}, args...)...)
+	goCommand(t, "vet", "./...")
+}
+
+// renderFiles 在 pro 模式下渲染 proto 文件，返回文件名到生成内容的映射
+func renderFiles(t *testing.T, config gen.Config, protoFiles ...string) map[string]string {
+	config.OutputDir, config.Mode = ".", gen.ModePro
+	generator, err := gen.NewGenerator(config)
+	assert.Nil(t, err)
+	outputs, err := generator.Render(protoFiles)
+	assert.False(t, gen.HasErrors(err), "%v", err)
+
+	files := map[string]string{}
+	for _, output := range outputs {
+		if output == nil {
+			continue
+		}
+		for _, file := range output.Files {
+			files[filepath.Base(file.Path)] = string(file.Content)
+		}
+	}
+	return files
+}
+
+// newSDKGenerator 创建输出到当前目录的 sdk 模式生成器
+func newSDKGenerator(t *testing.T, config gen.Config) *gen.Generator {
+	config.OutputDir, config.Mode = ".", gen.ModeSDK
+	generator, err := gen.NewGenerator(config)
+	assert.Nil(t, err)
+	return generator
+}
+
+// renderSDK 在 sdk 模式下渲染 proto 文件，返回文件名到生成内容的映射以及诊断信息
+func renderSDK(t *testing.T, config gen.Config, protoFiles ...string) (map[string]string, error) {
+	output, err := newSDKGenerator(t, config).RenderSDK(protoFiles)
+	files := map[string]string{}
+	for _, file := range output.Files {
+		files[filepath.Base(file.Path)] = string(file.Content)
+	}
+	return files, err
+}
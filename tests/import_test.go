@@ -0,0 +1,37 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/goal-web/goal-cli/app/gen"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImportProtoPaths(t *testing.T) {
+	writeProject(t, map[string]string{
+		"vendor/common/user.proto": "package common;\nmessage Profile {\n  string vendor_name = 1;\n}",
+		"third/common/user.proto":  "package common;\nmessage Profile {\n  string third_name = 1;\n}",
+		"third/common/page.proto":  "package common;\nmessage Page {\n  int32 size = 1;\n}",
+		"pro/post.proto": `import "common/user.proto";
+import "common/page.proto";
+message GetPostReq {
+  Profile profile = 1;
+  Page page = 2;
+}`,
+	})
+
+	// 按照 include 路径的顺序查找，先找到的文件优先，与 protoc 一致
+	for _, paths := range [][]string{{"vendor", "third"}, {"third", "vendor"}} {
+		graph := newSDKGenerator(t, gen.Config{ProtoPaths: paths}).BuildImportGraph([]string{"pro/post.proto"})
+		assert.Nil(t, graph.Errors("pro/post.proto"))
+		assert.Equal(t, []string{paths[0] + "/common/user.proto", "third/common/page.proto", "pro/post.proto"}, graph.Order)
+
+		request := renderFiles(t, gen.Config{ProtoPaths: paths}, "pro/post.proto")["GetPost_gen.go"]
+		assert.Contains(t, request, "common \"example.com/app/models/"+paths[0]+"/common\"")
+	}
+
+	// 默认只在当前目录中查找
+	assert.Equal(t, "pro/post.proto:2:1: error: import common/user.proto: 在 include 路径 . 中找不到被引用的文件\n"+
+		"pro/post.proto:3:1: error: import common/page.proto: 在 include 路径 . 中找不到被引用的文件",
+		newSDKGenerator(t, gen.Config{}).BuildImportGraph([]string{"pro/post.proto"}).Errors("pro/post.proto").Error())
+}
@@ -0,0 +1,216 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/goal-web/contracts"
+	"github.com/goal-web/database/drivers"
+	"github.com/goal-web/goal-cli/app/gen"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSqliteInspector(t *testing.T) {
+	connection := drivers.SqliteConnector(contracts.Fields{"database": filepath.Join(t.TempDir(), "test.db")}, nil)
+	_, exception := connection.Exec(`CREATE TABLE users (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  email VARCHAR(255) NOT NULL UNIQUE,
+  nickname TEXT,
+  tenant_id BIGINT NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL
+)`)
+	assert.Nil(t, exception)
+	_, exception = connection.Exec("CREATE INDEX tenant_nickname ON users (tenant_id, nickname)")
+	assert.Nil(t, exception)
+
+	inspector, err := gen.NewInspector(connection)
+	assert.Nil(t, err)
+
+	_, err = inspector.Table("posts")
+	assert.EqualError(t, err, "表 posts 不存在")
+
+	table, err := inspector.Table("users")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"id"}, table.PrimaryKeys())
+	content, err := table.ModelProto("User", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, `syntax = "proto3";
+
+option go_package = ".";
+
+//@timestamps
+//@table: users
+message UserModel {
+    //@pk
+    int64 id = 1;
+
+    //@unique
+    string email = 2;
+
+    //@nullable
+    string nickname = 3;
+
+    //@index:tenant_nickname,(tenant_id;nickname)
+    int64 tenant_id = 4;
+
+    string created_at = 5;
+
+    string updated_at = 6;
+}
+`, content)
+}
+
+func TestLinkTables(t *testing.T) {
+	connection := drivers.SqliteConnector(contracts.Fields{"database": filepath.Join(t.TempDir(), "test.db")}, nil)
+	for _, statement := range []string{
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL)",
+		"CREATE TABLE roles (id INTEGER PRIMARY KEY, name TEXT NOT NULL)",
+		"CREATE TABLE posts (id INTEGER PRIMARY KEY, user_id INTEGER NOT NULL REFERENCES users (id), editor_id INTEGER REFERENCES users)",
+		"CREATE TABLE role_user (user_id INTEGER NOT NULL REFERENCES users (id), role_id INTEGER NOT NULL REFERENCES roles (id), created_at TIMESTAMP)",
+	} {
+		_, exception := connection.Exec(statement)
+		assert.Nil(t, exception)
+	}
+
+	inspector, err := gen.NewInspector(connection)
+	assert.Nil(t, err)
+	names, err := inspector.Tables()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"posts", "role_user", "roles", "users"}, names)
+
+	tables := map[string]*gen.TableInfo{}
+	var list []*gen.TableInfo
+	for _, name := range names {
+		table, err := inspector.Table(name)
+		assert.Nil(t, err)
+		tables[name] = table
+		list = append(list, table)
+	}
+	gen.LinkTables(list)
+
+	assert.True(t, tables["role_user"].Pivot)
+	assert.Equal(t, []*gen.RelationInfo{
+		{Kind: "belongsTo", Name: "editor", Model: "UserModel", Args: []string{"id", "editor_id"}},
+		{Kind: "belongsTo", Name: "user", Model: "UserModel", Args: []string{"id", "user_id"}},
+	}, tables["posts"].Relations)
+	assert.Equal(t, []*gen.RelationInfo{
+		{Kind: "hasMany", Name: "posts_by_editor", Model: "PostModel", Args: []string{"editor_id", "id"}},
+		{Kind: "hasMany", Name: "posts", Model: "PostModel", Args: []string{"user_id", "id"}},
+		{Kind: "belongsToMany", Name: "roles", Model: "RoleModel", Args: []string{"role_user", "user_id", "id", "id", "role_id"}},
+	}, tables["users"].Relations)
+	assert.Equal(t, []*gen.RelationInfo{
+		{Kind: "belongsToMany", Name: "users", Model: "UserModel", Args: []string{"role_user", "role_id", "id", "id", "user_id"}},
+	}, tables["roles"].Relations)
+	assert.Equal(t, "Post", gen.ModelName("posts"))
+	content, err := tables["posts"].ModelProto("Post", nil)
+	assert.Nil(t, err)
+	assert.Contains(t, content, "\n    //@belongsTo:id,user_id\n    UserModel user = 5;\n")
+}
+
+func TestProtoTypes(t *testing.T) {
+	mysql := &gen.ProtoTypes{Dialect: "mysql", Overrides: map[string]string{"decimal": "double", "int(11) unsigned": "int64"}}
+	postgres := &gen.ProtoTypes{Dialect: "postgres"}
+	sqlite := &gen.ProtoTypes{Dialect: "sqlite3"}
+	clickhouse := &gen.ProtoTypes{Dialect: "clickhouse"}
+	for _, item := range []struct {
+		types    *gen.ProtoTypes
+		sqlType  string
+		expected string
+	}{
+		{mysql, "tinyint(1)", "bool"},
+		{mysql, "tinyint(4)", "int32"},
+		{mysql, "smallint unsigned", "uint32"},
+		{mysql, "bigint(20) unsigned", "uint64"},
+		{mysql, "int(11) unsigned", "int64"},
+		{mysql, "DECIMAL(10,2)", "double"},
+		{mysql, "datetime", "string"},
+		{mysql, "char(36)", "string"},
+		{mysql, "varchar(64)", "string"},
+		{mysql, "longblob", "bytes"},
+		{postgres, "character varying(255)", "string"},
+		{postgres, "timestamp(6) without time zone", "string"},
+		{postgres, "numeric(10,2)", "string"},
+		{postgres, "double precision", "double"},
+		{sqlite, "INTEGER", "int64"},
+		{sqlite, "INT UNSIGNED", "uint32"},
+		{sqlite, "NVARCHAR(100)", "string"},
+		{sqlite, "BOOLEAN", "bool"},
+		{clickhouse, "Nullable(UInt8)", "uint32"},
+		{clickhouse, "LowCardinality(String)", "string"},
+		{clickhouse, "DateTime64(3)", "string"},
+	} {
+		protoType, known := item.types.Lookup(item.sqlType)
+		assert.True(t, known, item.sqlType)
+		assert.Equal(t, item.expected, protoType, item.sqlType)
+	}
+
+	protoType, known := postgres.Lookup("point")
+	assert.False(t, known)
+	assert.Equal(t, "string", protoType)
+	assert.Equal(t, []string{"draft", "it's, done"}, gen.ParseSQLType("enum('draft','it''s, done')").EnumValues())
+}
+
+func TestModelProtoEnum(t *testing.T) {
+	table := &gen.TableInfo{Name: "orders", Dialect: "mysql", Columns: []*gen.ColumnInfo{
+		{Name: "id", Type: "bigint unsigned", PrimaryKey: true},
+		{Name: "status", Type: "enum('pending','in_progress')"},
+		{Name: "location", Type: "geometry"},
+	}}
+	content, err := table.ModelProto("Order", nil)
+	assert.Contains(t, content, "    OrderStatusEnum status = 2;\n")
+	assert.Contains(t, content, `
+enum OrderStatusEnum {
+    OrderStatusUnspecified = 0;
+    //@msg:pending
+    OrderStatusPending = 1;
+    //@msg:in_progress
+    OrderStatusInProgress = 2;
+}
+`)
+	assert.Contains(t, content, "    string location = 3;\n")
+	diagnostics, ok := err.(gen.Diagnostics)
+	assert.True(t, ok)
+	assert.Len(t, diagnostics, 1)
+	assert.Equal(t, gen.SeverityWarning, diagnostics[0].Severity)
+	assert.Contains(t, diagnostics[0].Error(), "column orders.location: 无法识别的数据库类型 geometry")
+}
+
+func TestModelProtoEnumScope(t *testing.T) {
+	table := &gen.TableInfo{Name: "orders", Dialect: "mysql", Columns: []*gen.ColumnInfo{
+		{Name: "id", Type: "bigint unsigned", PrimaryKey: true},
+		{Name: "status", Type: "enum('pending','paid','unspecified')"},
+		{Name: "refund_status", Type: "enum('pending','paid')"},
+	}}
+	content, err := table.ModelProto("Order", nil)
+	assert.Nil(t, err)
+	// 两个枚举的值处于同一个作用域，名称不能重复
+	assert.Contains(t, content, `
+enum OrderStatusEnum {
+    OrderStatusUnspecified = 0;
+    //@msg:pending
+    OrderStatusPending = 1;
+    //@msg:paid
+    OrderStatusPaid = 2;
+    //@msg:unspecified
+    OrderStatusUnspecified3 = 3;
+}
+`)
+	assert.Contains(t, content, `
+enum OrderRefundStatusEnum {
+    OrderRefundStatusUnspecified = 0;
+    //@msg:pending
+    OrderRefundStatusPending = 1;
+    //@msg:paid
+    OrderRefundStatusPaid = 2;
+}
+`)
+
+	// 生成的 proto 可以直接用于生成代码
+	writeProject(t, map[string]string{})
+	assert.Nil(t, os.WriteFile("order.proto", []byte(content), 0644))
+	files := renderFiles(t, gen.Config{}, "order.proto")
+	assert.Contains(t, files, "OrderStatus_gen.go")
+	assert.Contains(t, files, "OrderRefundStatus_gen.go")
+}
@@ -0,0 +1,51 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/goal-web/goal-cli/app/gen"
+	"github.com/stretchr/testify/assert"
+)
+
+// generate 生成之后把模型文件改成 stale，再次生成时没有被覆盖说明跳过了
+func generate(t *testing.T, config gen.Config) string {
+	config.OutputDir, config.Mode = ".", gen.ModePro
+	generator, err := gen.NewGenerator(config)
+	assert.Nil(t, err)
+	err = generator.Generate([]string{"pro/user.proto"})
+	assert.False(t, gen.HasErrors(err))
+
+	content, err := os.ReadFile(filepath.Join("models", "User_gen.go"))
+	assert.Nil(t, err)
+	assert.Nil(t, os.WriteFile(filepath.Join("models", "User_gen.go"), []byte("stale"), 0644))
+	return string(content)
+}
+
+func TestGenerateSkipsUnchanged(t *testing.T) {
+	template, err := os.ReadFile("../template.tmpl")
+	assert.Nil(t, err)
+	writeProject(t, map[string]string{"pro/user.proto": `
+//@foo
+message UserModel {
+  uint64 id = 1;
+  string name = 2;
+}`})
+	assert.Nil(t, os.WriteFile("custom.tmpl", template, 0644))
+
+	// 未知注解只是警告，不影响跳过
+	assert.Contains(t, generate(t, gen.Config{Template: "custom.tmpl"}), "type UserModel struct")
+	assert.Equal(t, "stale", generate(t, gen.Config{Template: "custom.tmpl"}))
+
+	// --force 忽略清单
+	assert.Contains(t, generate(t, gen.Config{Template: "custom.tmpl", Force: true}), "type UserModel struct")
+
+	// 切换数据库会重新生成
+	assert.Contains(t, generate(t, gen.Config{Template: "custom.tmpl", Dialect: gen.DialectPostgres}), "GENERATED BY DEFAULT AS IDENTITY")
+	assert.Equal(t, "stale", generate(t, gen.Config{Template: "custom.tmpl", Dialect: gen.DialectPostgres}))
+
+	// 修改模板会重新生成
+	assert.Nil(t, os.WriteFile("custom.tmpl", append(template, "\n{{/* changed */}}"...), 0644))
+	assert.Contains(t, generate(t, gen.Config{Template: "custom.tmpl", Dialect: gen.DialectPostgres}), "type UserModel struct")
+}
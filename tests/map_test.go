@@ -0,0 +1,101 @@
+package tests
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/goal-web/goal-cli/app/gen"
+	"github.com/stretchr/testify/assert"
+)
+
+// 只输出字段 ts 类型的 sdk 模板
+const tsTypeTemplate = `{{- define "model" -}}
+{{- range .Model.Fields }}{{ .JSONName }}: {{ tsType . }}
+{{ end }}{{- end }}
+{{- define "data" -}}
+{{- range .Model.Fields }}{{ .JSONName }}: {{ tsType . }}
+{{ end }}{{- end }}
+{{- define "result" }}{{ end }}{{ define "request" }}{{ end }}
+{{- define "enum" }}{{ end }}{{ define "service" }}{{ end }}{{ define "controller" }}{{ end }}`
+
+const mapProto = `
+message Label {
+  string text = 1;
+}
+message TagModel {
+  uint64 id = 1;
+  map<string, int32> counts = 2;
+  map<int64, Label> labels = 3;
+  map<uint64, string> names = 4;
+  map<sint32, bool> flags = 5;
+  map<fixed64, int64> totals = 6;
+  map<sfixed32, string> codes = 7;
+}`
+
+// go 中生成 map，value 是消息时使用消息的类型
+const mapMain = `package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"example.com/app/models"
+	"example.com/app/models/pro"
+)
+
+func main() {
+	tag := models.TagModel{
+		Counts: map[string]int32{"a": 1},
+		Labels: map[int64]pro.Label{2: {Text: "b"}},
+		Totals: map[uint64]int64{3: 4},
+	}
+	for _, value := range []any{tag.Counts, tag.Labels, tag.Totals} {
+		encoded, err := json.Marshal(value)
+		fmt.Println(string(encoded), err)
+	}
+}
+`
+
+func TestMapFields(t *testing.T) {
+	writeModule(t, map[string]string{
+		"sdk.tmpl":      tsTypeTemplate,
+		"pro/tag.proto": mapProto,
+		"sdk/tag.proto": strings.TrimSuffix(mapProto, "}") + "  map<bool, string> toggles = 8;\n}",
+	})
+
+	// ts 中 map 生成 Record，全部整数类型的 key 都是 number，bool 的 key 在 json 中是字符串
+	files, err := renderSDK(t, gen.Config{Template: "sdk.tmpl"}, "sdk/tag.proto")
+	assert.Nil(t, err)
+	types := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(files["Tag_gen.ts"]), "\n") {
+		name, tsType, _ := strings.Cut(line, ": ")
+		types[name] = tsType
+	}
+	assert.Equal(t, map[string]string{
+		"id":      "number",
+		"counts":  "Record<string, number>",
+		"labels":  "Record<number, Label>",
+		"names":   "Record<number, string>",
+		"flags":   "Record<number, boolean>",
+		"totals":  "Record<number, number>",
+		"codes":   "Record<number, string>",
+		"toggles": "Record<string, string>",
+	}, types)
+
+	generateModule(t)
+	assert.Nil(t, os.WriteFile("main.go", []byte(mapMain), 0644))
+	assert.Equal(t, "{\"a\":1} <nil>\n{\"2\":{\"text\":\"b\"}} <nil>\n{\"3\":4} <nil>\n", goCommand(t, "run", "."))
+
+	// 数据库中保存为 json
+	generator, err := gen.NewGenerator(gen.Config{Mode: gen.ModePro, OutputDir: "."})
+	assert.Nil(t, err)
+	schema, err := generator.Schema([]string{"pro/tag.proto"})
+	assert.Nil(t, err)
+	assert.Equal(t, "json", schema.Table("tags").Column("counts").Type)
+	assert.Equal(t, "json", schema.Table("tags").Column("labels").Type)
+
+	// encoding/json 无法序列化 bool 类型的 key
+	_, err = generator.Render([]string{"sdk/tag.proto"})
+	assert.EqualError(t, err, "sdk/tag.proto:14:3: error: field TagModel.toggles: go 代码中的 map 不能使用 bool 类型的 key，encoding/json 无法序列化")
+}
@@ -0,0 +1,73 @@
+package tests
+
+import (
+	"os"
+	"testing"
+
+	"github.com/goal-web/goal-cli/app/gen"
+	"github.com/stretchr/testify/assert"
+)
+
+// 嵌套的类型使用拼接后的名称生成，按照字段的类型组装模型和请求
+const nestedMain = `package main
+
+import (
+	"fmt"
+
+	enums "example.com/app/enums/pro"
+	"example.com/app/models"
+	"example.com/app/models/pro"
+	requests "example.com/app/requests/pro"
+)
+
+func main() {
+	order := models.OrderModel{Status: enums.OrderModelStatusPAID}
+	item := pro.OrderModelItem{Sku: "A1", Status: order.Status}
+	request := requests.GetOrderReq{Item: item, Status: item.Status}
+	fmt.Println(request.Item.Sku, request.Status)
+}
+`
+
+func TestNestedMessages(t *testing.T) {
+	// 模型中直接引用嵌套的枚举，嵌套的消息中由内向外查找外层消息的枚举，请求中使用限定名以及带 package 的全限定名
+	writeModule(t, map[string]string{
+		"pro/order.proto": `package shop;
+message OrderModel {
+  uint64 id = 1;
+  message Item {
+    string sku = 1;
+    Status status = 2;
+  }
+  enum Status {
+    PENDING = 0;
+    PAID = 1;
+  }
+  Status status = 2;
+}
+message GetOrderReq {
+  OrderModel.Item item = 1;
+  .shop.OrderModel.Status status = 2;
+}`,
+	})
+	generateModule(t)
+
+	assert.Nil(t, os.WriteFile("main.go", []byte(nestedMain), 0644))
+	assert.Equal(t, "A1 PAID\n", goCommand(t, "run", "."))
+}
+
+func TestNestedNameConflict(t *testing.T) {
+	writeProtos(t, map[string]string{
+		"pro/order.proto": `
+message Order {
+  message Item {
+    string sku = 1;
+  }
+}
+message OrderItem {
+  string sku = 1;
+}`,
+	})
+
+	_, err := renderSDK(t, gen.Config{}, "pro/order.proto")
+	assert.EqualError(t, err, "pro/order.proto:8:1: error: message OrderItem: 展开后的名称 OrderItem 与 Order.Item 冲突")
+}
@@ -0,0 +1,57 @@
+package tests
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const oneofMain = `package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"example.com/app/models/pro"
+)
+
+func main() {
+	for _, data := range []string{
+		` + "`" + `{"amount":1,"method":{"$case":"card","card":"6222"}}` + "`" + `,
+		` + "`" + `{"amount":1,"method":{"card":"6222","wallet_id":7}}` + "`" + `,
+		` + "`" + `{"amount":1,"method":{"$case":"wallet_id","card":"6222"}}` + "`" + `,
+		` + "`" + `{"amount":1}` + "`" + `,
+	} {
+		var payment pro.Payment
+		if err := json.Unmarshal([]byte(data), &payment); err != nil {
+			fmt.Println(err)
+			continue
+		}
+		encoded, _ := json.Marshal(payment)
+		fmt.Printf("%T %s\n", payment.Method, encoded)
+	}
+}
+`
+
+func TestOneofUnmarshal(t *testing.T) {
+	writeModule(t, map[string]string{
+		"pro/pay.proto": `
+message Payment {
+  uint64 amount = 1;
+  oneof method {
+    string card = 2;
+    uint64 wallet_id = 3;
+  }
+}`,
+	})
+	generateModule(t)
+
+	// 运行生成的代码，同时设置多个值或者 $case 与设置的值不一致时返回错误
+	assert.Nil(t, os.WriteFile("main.go", []byte(oneofMain), 0644))
+	assert.Equal(t, `*pro.PaymentMethodCard {"amount":1,"method":{"$case":"card","card":"6222"}}
+oneof method 只能设置一个值，实际设置了 [card wallet_id]
+oneof method 的 $case 为 wallet_id，但是没有设置对应的值
+<nil> {"amount":1,"method":null}
+`, goCommand(t, "run", "."))
+}
@@ -0,0 +1,164 @@
+package tests
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/goal-web/contracts"
+	"github.com/goal-web/database/drivers"
+	"github.com/goal-web/goal-cli/app/gen"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrimaryKeyStrategy(t *testing.T) {
+	writeProtos(t, map[string]string{
+		"pro/order.proto": `
+message OrderModel {
+  //@pk:uuid
+  uint64 id = 1;
+}
+//@pk:user_id,missing
+message UserRoleModel {
+  uint64 user_id = 1;
+}`,
+	})
+
+	generator, err := gen.NewGenerator(gen.Config{Mode: gen.ModeSDK, OutputDir: "."})
+	assert.Nil(t, err)
+
+	_, err = generator.RenderSDK([]string{"pro/order.proto"})
+	diagnostics, ok := err.(gen.Diagnostics)
+	assert.True(t, ok)
+	assert.Len(t, diagnostics, 2)
+	assert.Contains(t, diagnostics[0].Error(), "@pk:uuid 只能用于 string 类型的字段")
+	assert.Contains(t, diagnostics[1].Error(), "@pk 的字段 missing 不存在")
+}
+
+func TestCompositeKeyModel(t *testing.T) {
+	writeProject(t, map[string]string{
+		"pro/role.proto": `
+//@pk:user_id,role_id
+message UserRoleModel {
+  uint64 user_id = 1;
+  uint64 role_id = 2;
+  string note = 3;
+}`,
+	})
+
+	model := renderFiles(t, gen.Config{}, "pro/role.proto")["UserRole_gen.go"]
+	// 建表语句声明真正的联合主键，不再通过唯一索引约束
+	assert.Contains(t, model, "var UserRoleSchema = []string{\n\t\"CREATE TABLE `user_roles` (\\n  `user_id` BIGINT UNSIGNED not null,\\n  `role_id` BIGINT UNSIGNED not null,\\n  `note` VARCHAR(255) not null,\\n  PRIMARY KEY (`user_id`, `role_id`)\\n)\",\n}")
+	assert.NotContains(t, model, "user_roles_pk")
+	assert.Contains(t, model, "if _, err := executor.Query(\"SELECT 1 FROM user_roles WHERE 1 = 0\"); err != nil {\n\t\t\tfor _, statement := range UserRoleSchema {")
+	// 按照主键定位记录时使用全部主键字段
+	assert.Contains(t, model, "func UserRoleQueryByKey(userid uint64, roleid uint64) *table.Table[UserRoleModel] {\n\tquery := UserRoleQuery()\n\tquery.WhereFields(contracts.Fields{\n\t\t\"user_id\": userid,\n\t\t\"role_id\": roleid,\n\t})")
+	assert.Contains(t, model, "return UserRoleQuery().WhereFields(model.PrimaryKeyFields()).Count() > 0")
+	assert.Contains(t, model, "_, err := UserRoleQuery().WhereFields(model.PrimaryKeyFields()).UpdateE(model._update)")
+	assert.Contains(t, model, "_, err := UserRoleQuery().WhereFields(model.PrimaryKeyFields()).DeleteE()")
+	assert.NotContains(t, model, "Where(\"user_id\", model.GetPrimaryKey())")
+}
+
+func TestSnowflakeKeyModel(t *testing.T) {
+	writeProject(t, map[string]string{
+		"pro/order.proto": `
+message OrderModel {
+  //@pk:snowflake
+  uint64 id = 1;
+}`,
+	})
+
+	model := renderFiles(t, gen.Config{}, "pro/order.proto")["Order_gen.go"]
+	assert.Contains(t, model, "func SetOrderSnowflakeNode(id int64) error {\n\tnode, err := snowflake.NewNode(id)\n\tif err != nil {\n\t\treturn err\n\t}\n\tOrderDefine.PrimaryKeyGenerator = func(model *OrderModel) uint64 {\n\t\treturn uint64(node.Generate().Int64())\n\t}")
+	assert.Contains(t, model, "if err := SetOrderSnowflakeNode(1); err != nil {\n\t\tpanic(err)\n\t}")
+	assert.Contains(t, model, "db:\"id;type:BIGINT UNSIGNED;not null;primary key;\"`")
+}
+
+func TestCompositeKeyAuthenticatable(t *testing.T) {
+	writeProtos(t, map[string]string{
+		"pro/role.proto": `
+//@pk:user_id,role_id
+//@authenticatable
+message UserRoleModel {
+  uint64 user_id = 1;
+  uint64 role_id = 2;
+}`,
+	})
+
+	generator, err := gen.NewGenerator(gen.Config{Mode: gen.ModeSDK, OutputDir: "."})
+	assert.Nil(t, err)
+
+	_, err = generator.RenderSDK([]string{"pro/role.proto"})
+	assert.EqualError(t, err, "pro/role.proto:3:1: error: message UserRoleModel: 联合主键的模型不能使用 @authenticatable，登录需要单个字段的主键")
+}
+
+func TestCompositeKeySchema(t *testing.T) {
+	writeProject(t, map[string]string{
+		"pro/role.proto": `
+//@pk:user_id,role_id
+message UserRoleModel {
+  uint64 user_id = 1;
+  uint64 role_id = 2;
+}`,
+	})
+
+	generator, err := gen.NewGenerator(gen.Config{OutputDir: ".", Dialect: gen.DialectPostgres})
+	assert.Nil(t, err)
+	current, err := generator.Schema([]string{"pro/role.proto"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"user_id", "role_id"}, current.Tables[0].PrimaryKey)
+	assert.Empty(t, current.Tables[0].Indexes)
+
+	diff, err := gen.DiffSchema(&gen.Schema{}, current)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"CREATE TABLE \"user_roles\" (\n  \"user_id\" BIGINT not null,\n  \"role_id\" BIGINT not null,\n  PRIMARY KEY (\"user_id\", \"role_id\")\n)"}, diff.Up)
+
+	// 主键从 user_id 改为联合主键时先删除原来的主键
+	previous := &gen.Schema{Dialect: gen.DialectPostgres, Tables: []*gen.TableSchema{{
+		Name: "user_roles",
+		Columns: []*gen.ColumnSchema{
+			{Name: "user_id", Type: "BIGINT", Constraints: "not null primary key"},
+			{Name: "role_id", Type: "BIGINT", Constraints: "not null"},
+		},
+	}}}
+	diff, err = gen.DiffSchema(previous, current)
+	assert.Nil(t, err)
+	assert.Contains(t, diff.Up, "ALTER TABLE \"user_roles\" DROP CONSTRAINT \"user_roles_pkey\", ADD PRIMARY KEY (\"user_id\", \"role_id\")")
+	assert.Contains(t, diff.Down, "ALTER TABLE \"user_roles\" DROP CONSTRAINT \"user_roles_pkey\", ADD PRIMARY KEY (\"user_id\")")
+}
+
+func TestCompositeKeyDatabase(t *testing.T) {
+	writeProject(t, map[string]string{
+		"pro/role.proto": `
+//@pk:user_id,role_id
+message UserRoleModel {
+  uint64 user_id = 1;
+  uint64 role_id = 2;
+}`,
+	})
+
+	generator, err := gen.NewGenerator(gen.Config{OutputDir: ".", Dialect: gen.DialectSQLite})
+	assert.Nil(t, err)
+	schema, err := generator.Schema([]string{"pro/role.proto"})
+	assert.Nil(t, err)
+
+	connection := drivers.SqliteConnector(contracts.Fields{"database": filepath.Join(t.TempDir(), "test.db")}, nil)
+	migrations, err := gen.DiffSchema(&gen.Schema{}, schema)
+	assert.Nil(t, err)
+	for _, migration := range migrations.Migrations {
+		_, exception := connection.Exec(migration.Up)
+		assert.Nil(t, exception)
+	}
+	inspector, err := gen.NewInspector(connection)
+	assert.Nil(t, err)
+	diff, err := gen.DiffDatabase(schema, inspector)
+	assert.Nil(t, err)
+	assert.False(t, diff.HasDrift())
+
+	// 数据库中只有 user_id 是主键
+	_, exception := connection.Exec("CREATE TABLE legacy_roles (user_id INTEGER NOT NULL PRIMARY KEY, role_id INTEGER NOT NULL)")
+	assert.Nil(t, exception)
+	schema.Tables[0].Name = "legacy_roles"
+	diff, err = gen.DiffDatabase(schema, inspector)
+	assert.Nil(t, err)
+	assert.Equal(t, []*gen.KeyDrift{{Table: "legacy_roles", Expected: "(user_id,role_id)", Actual: "(user_id)"}}, diff.KeyMismatches)
+}
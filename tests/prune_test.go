@@ -0,0 +1,93 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/goal-web/goal-cli/app/gen"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrune(t *testing.T) {
+	writeProject(t, map[string]string{
+		"pro/user.proto": `
+message UserModel {
+  uint64 id = 1;
+}`,
+		"pro/post.proto": `
+message PostModel {
+  uint64 id = 1;
+}
+message GetPostReq {
+  uint64 id = 1;
+}
+//@controller
+service PostService {
+  rpc Get(GetPostReq) returns (PostModel);
+}`,
+	})
+	generate := func(prune bool, protoFiles ...string) error {
+		generator, err := gen.NewGenerator(gen.Config{Mode: gen.ModePro, OutputDir: ".", Prune: prune})
+		assert.Nil(t, err)
+		return generator.Generate(protoFiles)
+	}
+	readKernel := func() string {
+		content, err := os.ReadFile(filepath.Join("controllers", "kernel.go"))
+		assert.Nil(t, err)
+		return string(content)
+	}
+
+	assert.Nil(t, generate(false, "pro/user.proto", "pro/post.proto"))
+	assert.Contains(t, readKernel(), "\"example.com/app/controllers/pro\"")
+	assert.Contains(t, readKernel(), "pro.PostServiceRouter(router)")
+	// 手写的文件不会被删除
+	assert.Nil(t, os.WriteFile(filepath.Join("models", "Post_gen.go"), []byte("package models\n"), 0644))
+
+	// 没有 --prune 时只记录孤立的文件和路由
+	assert.Nil(t, generate(false, "pro/user.proto"))
+	manifest, err := gen.LoadManifest(".")
+	assert.Nil(t, err)
+	assert.Equal(t, &gen.ManifestOrphans{
+		Outputs: []string{"controllers/pro/Post_gen.go", "models/Post_gen.go", "requests/pro/GetPost_gen.go", "services/pro/Post_gen.go"},
+		Routers: []*gen.RouterCollector{{ImportPath: "example.com/app/controllers/pro", UsageName: "svc.PostServiceRouter(router)"}},
+	}, manifest.Orphans)
+	assert.FileExists(t, filepath.Join("services", "pro", "Post_gen.go"))
+	assert.Contains(t, readKernel(), "pro.PostServiceRouter(router)")
+
+	err = generate(true, "pro/user.proto")
+	diagnostics, ok := err.(gen.Diagnostics)
+	assert.True(t, ok)
+	assert.False(t, diagnostics.HasErrors())
+	assert.Contains(t, err.Error(), "models/Post_gen.go: warning: prune: 文件不是由 goal-cli 生成的，已跳过")
+	for _, output := range []string{"controllers/pro/Post_gen.go", "requests/pro/GetPost_gen.go", "services/pro/Post_gen.go"} {
+		assert.NoFileExists(t, output)
+	}
+	assert.FileExists(t, filepath.Join("models", "Post_gen.go"))
+	assert.FileExists(t, filepath.Join("models", "User_gen.go"))
+	// 路由调用以及不再使用的 import 一起删除
+	assert.NotContains(t, readKernel(), "PostServiceRouter")
+	assert.NotContains(t, readKernel(), "example.com/app/controllers/pro")
+	assert.Contains(t, readKernel(), "func Register(router contracts.HttpRouter) {")
+
+	manifest, err = gen.LoadManifest(".")
+	assert.Nil(t, err)
+	assert.Nil(t, manifest.Orphans)
+}
+
+func TestPruneSkipsUnmarkedFiles(t *testing.T) {
+	writeProject(t, map[string]string{})
+	assert.Nil(t, os.MkdirAll("models", os.ModePerm))
+	assert.Nil(t, os.MkdirAll("sdk", os.ModePerm))
+	assert.Nil(t, os.WriteFile(filepath.Join("models", "Old_gen.go"), []byte("// Code generated by goal-cli. DO NOT EDIT.\npackage models\n"), 0644))
+	assert.Nil(t, os.WriteFile(filepath.Join("sdk", "user.ts"), []byte("export interface User {}\n"), 0644))
+
+	// 不是 Go 文件也需要生成标记，没有标记的文件跳过并且不再记录
+	manifest := &gen.Manifest{Orphans: &gen.ManifestOrphans{Outputs: []string{"models/Old_gen.go", "sdk/user.ts"}}}
+	err := gen.Prune(".", manifest)
+	assert.False(t, gen.HasErrors(err))
+	assert.Contains(t, err.Error(), "sdk/user.ts: warning: prune: 文件不是由 goal-cli 生成的，已跳过")
+	assert.NoFileExists(t, filepath.Join("models", "Old_gen.go"))
+	assert.FileExists(t, filepath.Join("sdk", "user.ts"))
+	assert.Nil(t, manifest.Orphans)
+}
@@ -0,0 +1,65 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/goal-web/goal-cli/app/gen"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffSchema(t *testing.T) {
+	previous := &gen.Schema{Tables: []*gen.TableSchema{{
+		Name: "users",
+		Columns: []*gen.ColumnSchema{
+			{Name: "id", Type: "BIGINT UNSIGNED", Constraints: "not null primary key AUTO_INCREMENT"},
+			{Name: "name", Type: "VARCHAR(255)", Constraints: "not null"},
+			{Name: "age", Type: "INT", Constraints: "not null"},
+		},
+		Indexes: []*gen.IndexSchema{{Name: "name_idx", Columns: "(name)"}},
+	}}}
+	current := &gen.Schema{Tables: []*gen.TableSchema{{
+		Name: "users",
+		Columns: []*gen.ColumnSchema{
+			{Name: "id", Type: "BIGINT UNSIGNED", Constraints: "not null primary key AUTO_INCREMENT"},
+			{Name: "nickname", Type: "VARCHAR(255)", Constraints: "not null", RenamedFrom: "name"},
+			{Name: "email", Type: "VARCHAR(255)", Constraints: "not null"},
+		},
+		Indexes: []*gen.IndexSchema{{Name: "name_idx", Columns: "(nickname)"}},
+	}}}
+
+	diff, err := gen.DiffSchema(previous, current)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"DROP INDEX `name_idx` ON `users`",
+		"ALTER TABLE `users` RENAME COLUMN `name` TO `nickname`",
+		"ALTER TABLE `users` ADD COLUMN `email` VARCHAR(255) not null",
+		"ALTER TABLE `users` DROP COLUMN `age`",
+		"CREATE INDEX `name_idx` ON `users` (nickname)",
+	}, diff.Up)
+	assert.Equal(t, []string{
+		"DROP INDEX `name_idx` ON `users`",
+		"ALTER TABLE `users` ADD COLUMN `age` INT not null",
+		"ALTER TABLE `users` DROP COLUMN `email`",
+		"ALTER TABLE `users` RENAME COLUMN `nickname` TO `name`",
+		"CREATE INDEX `name_idx` ON `users` (name)",
+	}, diff.Down)
+
+	// 每条语句与撤销它的语句一一对应，可以分别保存为迁移文件
+	assert.Equal(t, &gen.Migration{
+		Up:   "ALTER TABLE `users` RENAME COLUMN `name` TO `nickname`",
+		Down: "ALTER TABLE `users` RENAME COLUMN `nickname` TO `name`",
+	}, diff.Migrations[1])
+	assert.Equal(t, &gen.Migration{
+		Up:   "ALTER TABLE `users` DROP COLUMN `age`",
+		Down: "ALTER TABLE `users` ADD COLUMN `age` INT not null",
+	}, diff.Migrations[3])
+	assert.Len(t, diff.Migrations, len(diff.Up))
+
+	diff, err = gen.DiffSchema(current, current)
+	assert.Nil(t, err)
+	assert.True(t, diff.Empty())
+
+	// 快照与当前表结构的数据库不一致时不能直接对比
+	_, err = gen.DiffSchema(previous, &gen.Schema{Dialect: gen.DialectPostgres, Tables: current.Tables})
+	assert.EqualError(t, err, "schema.json: error: schema: 快照是按照 mysql 生成的，无法对比生成 postgres 的迁移，切换数据库时请使用新的迁移目录")
+}
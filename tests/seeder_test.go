@@ -0,0 +1,62 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/goal-web/goal-cli/app/console/commands"
+	"github.com/stretchr/testify/assert"
+)
+
+const customerSeeder = `package seeders
+
+import (
+	"fmt"
+
+	"example.com/app/models"
+	"github.com/goal-web/application"
+	"github.com/goal-web/contracts"
+)
+
+func init() {
+	Register("CustomerSeeder", CustomerSeeder)
+}
+
+func CustomerSeeder() contracts.Exception {
+	if err := models.CustomerMigrator()(application.Get("db").(contracts.DBConnection)); err != nil {
+		return err
+	}
+	customers, err := models.CustomerFactory().State(contracts.Fields{"ssn": "110101", "password": "secret"}).Create()
+	if err != nil {
+		return err
+	}
+	customer := models.CustomerQuery().FindOrFail(customers[0].Id)
+	fmt.Println(customer.GetSsn(), customer.CheckPassword("secret"), customer.Password != "secret")
+	return nil
+}
+`
+
+func TestSeederWithCasts(t *testing.T) {
+	writeModule(t, map[string]string{
+		"pro/customer.proto": `
+//@timestamps
+message CustomerModel {
+  uint64 id = 1;
+  //@cast:encrypted
+  string ssn = 2;
+  //@cast:hashed
+  string password = 3;
+  string created_at = 4;
+  string updated_at = 5;
+}`,
+	})
+	writeAppConfig(t)
+
+	generateModule(t, "--dialect=sqlite")
+	runCommand(t, commands.MakeSeeder, "CustomerSeeder")
+	assert.Nil(t, os.WriteFile(filepath.Join("database", "seeders", "CustomerSeeder.go"), []byte(customerSeeder), 0644))
+
+	// 与 db:seed 一样在项目中执行，加密和哈希字段需要 encryption 以及 hashing 服务
+	assert.Contains(t, goCommand(t, "run", "./database/seeders/cmd", "CustomerSeeder"), "Seeding: CustomerSeeder\n110101 true true\n")
+}
@@ -0,0 +1,48 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/goal-web/goal-cli/app/gen"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSoftDeleteColumn(t *testing.T) {
+	writeProtos(t, map[string]string{
+		"pro/post.proto": `
+//@softDelete:removed_at
+message PostModel {
+  uint64 id = 1;
+  string deleted_at = 2;
+}`,
+	})
+
+	generator, err := gen.NewGenerator(gen.Config{Mode: gen.ModeSDK, OutputDir: "."})
+	assert.Nil(t, err)
+
+	_, err = generator.RenderSDK([]string{"pro/post.proto"})
+	assert.EqualError(t, err, "pro/post.proto:3:1: error: message PostModel: @softDelete 需要在模型中声明字段 string removed_at")
+}
+
+func TestSoftDeleteModel(t *testing.T) {
+	writeProject(t, map[string]string{
+		"pro/post.proto": `
+//@softDelete
+message PostModel {
+  uint64 id = 1;
+  string title = 2;
+  string deleted_at = 3;
+}`,
+	})
+
+	model := renderFiles(t, gen.Config{}, "pro/post.proto")["Post_gen.go"]
+	// 默认查询排除软删除的记录，OnlyTrashed 只查询软删除的记录
+	assert.Contains(t, model, "func PostQuery() *table.Table[PostModel] {\n\tquery := PostQueryWithTrashed()\n\tquery.WhereIsNull(fmt.Sprintf(\"%s.deleted_at\", PostDefine.TableName))")
+	assert.Contains(t, model, "func PostQueryOnlyTrashed() *table.Table[PostModel] {\n\tquery := PostQueryWithTrashed()\n\tquery.WhereNotNull(fmt.Sprintf(\"%s.deleted_at\", PostDefine.TableName))")
+	// 写操作以及 Exists 都包括软删除的记录
+	assert.Contains(t, model, "func (model *PostModel) Exists() bool {\n\treturn PostQueryWithTrashed().Where(\"id\", model.GetPrimaryKey()).Count() > 0")
+	assert.Contains(t, model, "deletedAt := carbon.Now().ToDateTimeString()\n\t_, err := PostQueryWithTrashed().Where(\"id\", model.GetPrimaryKey()).UpdateE(contracts.Fields{\"deleted_at\": deletedAt})")
+	assert.Contains(t, model, "func (model *PostModel) Restore() contracts.Exception {\n\t_, err := PostQueryWithTrashed().Where(\"id\", model.GetPrimaryKey()).UpdateE(contracts.Fields{\"deleted_at\": nil})")
+	assert.Contains(t, model, "_, err := PostQueryWithTrashed().Where(\"id\", model.GetPrimaryKey()).DeleteE()")
+	assert.Contains(t, model, "func (model *PostModel) Trashed() bool {\n\treturn model.DeletedAt != \"\"")
+}
@@ -0,0 +1,93 @@
+package tests
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/goal-web/goal-cli/app/gen"
+	"github.com/stretchr/testify/assert"
+)
+
+// 只输出校验规则的 sdk 模板，模拟 ts、openapi 之类的目标
+const rulesTemplate = `{{- define "request" -}}
+{{- range .Model.Fields }}{{ .JSONName }}:{{ range .Rules }} {{ .Name }}={{ .Value }}{{ end }} [{{ .ValidateTag }}]
+{{ end }}{{- end }}
+{{- define "data" }}{{ end }}{{ define "result" }}{{ end }}{{ define "model" }}{{ end }}
+{{- define "enum" }}{{ end }}{{ define "service" }}{{ end }}{{ define "controller" }}{{ end }}`
+
+// 读取生成的请求中的校验规则，并执行 @validate:regex 生成的校验方法
+const validateMain = `package main
+
+import (
+	"fmt"
+	"reflect"
+
+	"example.com/app/requests/pro"
+)
+
+func main() {
+	request := reflect.TypeOf(pro.RegisterRequest{})
+	for i := 0; i < request.NumField(); i++ {
+		fmt.Printf("%s [%s]\n", request.Field(i).Name, request.Field(i).Tag.Get("validate"))
+	}
+	for _, name := range []string{"", "abc,d", "Abc"} {
+		fmt.Printf("%q %v\n", name, (&pro.RegisterRequest{Name: name}).ValidatePatterns() == nil)
+	}
+}
+`
+
+func TestValidateRules(t *testing.T) {
+	writeModule(t, map[string]string{
+		"sdk.tmpl": rulesTemplate,
+		"pro/user.proto": `
+enum Gender { UNKNOWN = 0; MALE = 1; }
+message RegisterRequest {
+  //@validate:required,min=8
+  string password = 1;
+  //@validate:eqfield=password
+  string password_confirm = 2;
+  //@validate:regex=^[a-z,]+$
+  string name = 3;
+  //@validate:enum
+  Gender gender = 4;
+}`,
+	})
+
+	files, err := renderSDK(t, gen.Config{Template: "sdk.tmpl"}, "pro/user.proto")
+	assert.Nil(t, err)
+	assert.Len(t, files, 2) // 请求以及枚举
+	assert.Equal(t, strings.Join([]string{
+		"password: required= min=8 [required,min=8]",
+		"password_confirm: eqfield=password [eqfield=Password]",
+		"name: regex=^[a-z,]+$ []",
+		"gender: enum=0|1 [oneof=0 1]",
+		"",
+	}, "\n"), files["Register_gen.ts"])
+
+	generateModule(t)
+	assert.Nil(t, os.WriteFile("main.go", []byte(validateMain), 0644))
+	assert.Equal(t, strings.Join([]string{
+		"Password [required,min=8]",
+		"PasswordConfirm [eqfield=Password]",
+		"Name []",
+		"Gender [oneof=0 1]",
+		`"" true`,
+		`"abc,d" true`,
+		`"Abc" false`,
+		"",
+	}, "\n"), goCommand(t, "run", "."))
+}
+
+func TestValidateRuleType(t *testing.T) {
+	writeProtos(t, map[string]string{
+		"pro/user.proto": `
+message AgreeRequest {
+  //@validate:min=1
+  bool agree = 1;
+}`,
+	})
+
+	_, err := renderSDK(t, gen.Config{}, "pro/user.proto")
+	assert.EqualError(t, err, "pro/user.proto:4:3: error: field AgreeRequest.agree: @validate min：不能用于 bool 类型的字段")
+}
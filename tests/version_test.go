@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/goal-web/goal-cli/app/gen"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVersionField(t *testing.T) {
+	writeProtos(t, map[string]string{
+		"pro/article.proto": `
+message ArticleModel {
+  uint64 id = 1;
+  //@version
+  string version = 2;
+}`,
+	})
+
+	_, err := renderSDK(t, gen.Config{}, "pro/article.proto")
+	assert.EqualError(t, err, "pro/article.proto:5:3: error: field ArticleModel.version: @version 只能用于 int32、int64、uint32、uint64 类型的字段，实际是 string")
+}
+
+// 两个请求读取同一条记录，先保存的请求让另一个请求的版本号过期
+const versionMain = `package main
+
+import (
+	"fmt"
+
+	config2 "example.com/app/config"
+	"example.com/app/models"
+	"github.com/goal-web/application"
+	"github.com/goal-web/config"
+	"github.com/goal-web/contracts"
+	"github.com/goal-web/database"
+	"github.com/goal-web/events"
+	"github.com/goal-web/supports/exceptions"
+)
+
+func main() {
+	app := application.Singleton(false)
+	app.Singleton("exceptions.handler", func() contracts.ExceptionHandler {
+		return exceptions.DefaultExceptionHandler{}
+	})
+	app.RegisterServices(
+		config.NewService(config.NewToml(config.File("env.toml")), config2.GetConfigProviders()),
+		events.NewService(),
+		database.NewService(true),
+	)
+	app.Start()
+
+	if err := models.ArticleMigrator()(application.Get("db").(contracts.DBConnection)); err != nil {
+		panic(err)
+	}
+	articles, err := models.ArticleFactory().State(contracts.Fields{"title": "a"}).Create()
+	if err != nil {
+		panic(err)
+	}
+	first := models.ArticleQuery().FindOrFail(articles[0].Id)
+	second := models.ArticleQuery().FindOrFail(articles[0].Id)
+
+	first.SetTitle("b")
+	fmt.Println(first.Save(), first.Version)
+	second.SetTitle("c")
+	err = second.Save()
+	fmt.Printf("%T %v\n", err, err)
+	// 调用方带上最新的版本号后可以继续更新，版本号由数据库自增
+	fmt.Println(second.Update(contracts.Fields{"title": "c", "version": first.Version}), second.Version)
+	article := models.ArticleQuery().FindOrFail(articles[0].Id)
+	fmt.Println(article.Title, article.Version)
+}
+`
+
+func TestVersionModel(t *testing.T) {
+	writeModule(t, map[string]string{
+		"pro/article.proto": `
+//@timestamps
+message ArticleModel {
+  uint64 id = 1;
+  string title = 2;
+  //@version
+  uint32 version = 3;
+  string created_at = 4;
+  string updated_at = 5;
+}`,
+	})
+	writeAppConfig(t)
+	generateModule(t, "--dialect=sqlite")
+
+	assert.Nil(t, os.WriteFile("main.go", []byte(versionMain), 0644))
+	assert.Equal(t, strings.Join([]string{
+		"<nil> 1",
+		"*models.ArticleVersionConflictException articles 的记录 1 已经被修改，版本 0 已过期",
+		"<nil> 2",
+		"c 2",
+		"",
+	}, "\n"), goCommand(t, "run", "."))
+}